@@ -33,6 +33,10 @@ type GatewayParametersList struct {
 	Items           []GatewayParameters `json:"items"`
 }
 
+func init() {
+	SchemeBuilder.Register(&GatewayParameters{}, &GatewayParametersList{})
+}
+
 // A GatewayParametersSpec describes the type of environment/platform in which
 // the proxy will be provisioned.
 //
@@ -44,6 +48,8 @@ type GatewayParametersSpec struct {
 	Kube *KubernetesProxyConfig `json:"kube,omitempty"`
 
 	// The proxy will be self-managed and not auto-provisioned.
+	// The externally reachable addresses of a self-managed proxy are declared in
+	// the spec.addresses of its Gateways, and are reported verbatim in their status.
 	//
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:pruning:PreserveUnknownFields
@@ -291,8 +297,27 @@ type EnvoyBootstrap struct {
 	//
 	// +kubebuilder:validation:Optional
 	ComponentLogLevels map[string]string `json:"componentLogLevels,omitempty"`
+
+	// The xDS protocol the proxy uses to fetch its configuration from the
+	// control plane. With "StateOfTheWorld", every change of a resource type
+	// resends all the resources of that type. With "Delta", only the
+	// resources that changed or were removed are sent, which is much cheaper
+	// for gateways with many clusters and endpoints. Defaults to
+	// "StateOfTheWorld".
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=StateOfTheWorld;Delta
+	XdsProtocol *XdsProtocol `json:"xdsProtocol,omitempty"`
 }
 
+// XdsProtocol is the variant of the xDS protocol a proxy speaks with the control plane.
+type XdsProtocol string
+
+const (
+	XdsProtocolStateOfTheWorld XdsProtocol = "StateOfTheWorld"
+	XdsProtocolDelta           XdsProtocol = "Delta"
+)
+
 func (in *EnvoyBootstrap) GetLogLevel() *string {
 	if in == nil {
 		return nil
@@ -307,6 +332,13 @@ func (in *EnvoyBootstrap) GetComponentLogLevels() map[string]string {
 	return in.ComponentLogLevels
 }
 
+func (in *EnvoyBootstrap) GetXdsProtocol() *XdsProtocol {
+	if in == nil {
+		return nil
+	}
+	return in.XdsProtocol
+}
+
 // Configuration for the container running Gloo SDS.
 type SdsContainer struct {
 	// The SDS container image. See
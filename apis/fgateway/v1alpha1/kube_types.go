@@ -127,6 +127,18 @@ func (in *Service) GetExtraAnnotations() map[string]string {
 }
 
 type ServiceAccount struct {
+	// Whether to create a ServiceAccount for the proxy. When false, the proxy runs as the ServiceAccount named
+	// Name, and the ServiceAccount created before is deleted. Defaults to true.
+	//
+	// +kubebuilder:validation:Optional
+	Create *bool `json:"create,omitempty"`
+
+	// The name of an existing ServiceAccount the proxy runs as when Create is false. Defaults to the default
+	// ServiceAccount of the namespace of the Gateway.
+	//
+	// +kubebuilder:validation:Optional
+	Name *string `json:"name,omitempty"`
+
 	// Additional labels to add to the ServiceAccount object metadata.
 	//
 	// +kubebuilder:validation:Optional
@@ -138,6 +150,20 @@ type ServiceAccount struct {
 	ExtraAnnotations map[string]string `json:"extraAnnotations,omitempty"`
 }
 
+func (in *ServiceAccount) GetCreate() *bool {
+	if in == nil {
+		return nil
+	}
+	return in.Create
+}
+
+func (in *ServiceAccount) GetName() *string {
+	if in == nil {
+		return nil
+	}
+	return in.Name
+}
+
 func (in *ServiceAccount) GetExtraLabels() map[string]string {
 	if in == nil {
 		return nil
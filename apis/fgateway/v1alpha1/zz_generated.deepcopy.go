@@ -143,6 +143,11 @@ func (in *EnvoyBootstrap) DeepCopyInto(out *EnvoyBootstrap) {
 			(*out)[key] = val
 		}
 	}
+	if in.XdsProtocol != nil {
+		in, out := &in.XdsProtocol, &out.XdsProtocol
+		*out = new(XdsProtocol)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvoyBootstrap.
@@ -706,6 +711,16 @@ func (in *Service) DeepCopy() *Service {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceAccount) DeepCopyInto(out *ServiceAccount) {
 	*out = *in
+	if in.Create != nil {
+		in, out := &in.Create, &out.Create
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Name != nil {
+		in, out := &in.Name, &out.Name
+		*out = new(string)
+		**out = **in
+	}
 	if in.ExtraLabels != nil {
 		in, out := &in.ExtraLabels, &out.ExtraLabels
 		*out = make(map[string]string, len(*in))
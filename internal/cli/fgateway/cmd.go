@@ -12,7 +12,10 @@ import (
 )
 
 func NewCmd() *cobra.Command {
-	var fgatewayVersion bool
+	var (
+		fgatewayVersion bool
+		logLevels       string
+	)
 	rootCmd := &cobra.Command{
 		Use:   "fgateway",
 		Short: "Runs the fgateway controller",
@@ -24,12 +27,15 @@ func NewCmd() *cobra.Command {
 			ctx := context.Background()
 			// probe server
 			probes.StartLivenessProbeServer(ctx)
-			if err := fgateway.Run(ctx); err != nil {
+			if err := fgateway.Run(ctx, logLevels); err != nil {
 				return errors.Errorf("failed to run fgateway: %v", err)
 			}
 			return nil
 		},
 	}
 	rootCmd.Flags().BoolVarP(&fgatewayVersion, "version", "v", false, "Print fgateway version")
+	rootCmd.Flags().StringVar(&logLevels, "log-level", "",
+		"Comma separated log levels of components, such as xds=debug,deployer=warn. "+
+			"The components are default, controller-runtime, krt, xds and deployer")
 	return rootCmd
 }
@@ -0,0 +1,183 @@
+package admin
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	envoytypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/samber/lo"
+	"github.com/solo-io/go-utils/contextutils"
+	"google.golang.org/protobuf/encoding/protojson"
+	"istio.io/istio/pkg/kube/krt"
+)
+
+const (
+	// DefaultAddress is the address the admin server binds to, the loopback interface, as it is not authenticated
+	DefaultAddress = "127.0.0.1"
+	// DefaultPort is the port the admin server listens on
+	DefaultPort = 9095
+)
+
+// Options are the state the admin server exposes. Its endpoints are only served for the state that is set.
+type Options struct {
+	// Address is the address the admin server binds to, DefaultAddress when empty
+	Address string
+	Port    int
+	// KrtDebugger dumps the krt collections registered to it
+	KrtDebugger *krt.DebugHandler
+	// Cache holds the xds snapshots of the connected proxies
+	Cache envoycache.SnapshotCache
+	// Streams lists the xds streams of the connected proxies
+	Streams krtcollections.ConnectedStreams
+	// LogLevel gets and changes the log levels, through GET and PUT requests
+	LogLevel http.Handler
+}
+
+// endpoint is a path of the admin server and what it serves
+type endpoint struct {
+	path        string
+	description string
+	handler     http.Handler
+}
+
+// StartServer starts the admin server in the background, shutting it down when ctx is done
+func StartServer(ctx context.Context, o Options) {
+	logger := contextutils.LoggerFrom(ctx)
+	server := &http.Server{
+		Addr:              net.JoinHostPort(cmp.Or(o.Address, DefaultAddress), strconv.Itoa(o.Port)),
+		Handler:           NewServeMux(o),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logger.Infof("admin server starting at %s", server.Addr)
+		err := server.ListenAndServe()
+		if err != nil {
+			if errors.Is(err, http.ErrServerClosed) {
+				logger.Info("admin server closed")
+			} else {
+				logger.Warnf("admin server closed with unexpected error: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Warnf("admin server shutdown returned error: %v", err)
+		}
+	}()
+}
+
+// NewServeMux returns the handler of the admin server, which lists its endpoints on /
+func NewServeMux(o Options) *http.ServeMux {
+	var endpoints []endpoint
+	if lo.IsNotNil(o.KrtDebugger) {
+		endpoints = append(endpoints, endpoint{"/debug/krt", "the krt collections, their inputs and outputs", jsonHandler(func(*http.Request) any {
+			return o.KrtDebugger
+		})})
+	}
+	if lo.IsNotNil(o.Cache) {
+		endpoints = append(endpoints, endpoint{"/debug/snapshots", "the xds snapshot of every cache key, or of the one of the key query parameter", jsonHandler(func(r *http.Request) any {
+			return snapshots(o.Cache, r.URL.Query().Get("key"))
+		})})
+	}
+	if o.Streams != nil {
+		endpoints = append(endpoints, endpoint{"/debug/xds/clients", "the xds streams of the connected proxies", jsonHandler(func(*http.Request) any {
+			return o.Streams()
+		})})
+	}
+	if o.LogLevel != nil {
+		endpoints = append(endpoints, endpoint{"/logging", "the log level of every component, changed by a PUT of levels by component such as {\"xds\": \"debug\"}", o.LogLevel})
+	}
+
+	mux := http.NewServeMux()
+	for _, e := range endpoints {
+		mux.Handle(e.path, e.handler)
+	}
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, e := range endpoints {
+			fmt.Fprintf(w, "%s: %s\n", e.path, e.description)
+		}
+	})
+	return mux
+}
+
+// jsonHandler serves the indented JSON of the value returned by get
+func jsonHandler(get func(r *http.Request) any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := json.MarshalIndent(get(r), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out)
+	})
+}
+
+// snapshotResources are the resources of a type of a snapshot, by name. The secrets are only listed by name, as
+// they hold private keys.
+type snapshotResources struct {
+	Version   string                     `json:"version"`
+	Resources map[string]json.RawMessage `json:"resources,omitempty"`
+	Names     []string                   `json:"names,omitempty"`
+}
+
+// snapshots returns the resources of the snapshots of the cache by key, then type url. The keys are the ones of the
+// proxies that connected, so key narrows them down to a single one. The secrets are redacted.
+func snapshots(cache envoycache.SnapshotCache, key string) map[string]map[string]snapshotResources {
+	keys := cache.GetStatusKeys()
+	if key != "" {
+		keys = []string{key}
+	}
+	ret := map[string]map[string]snapshotResources{}
+	for _, k := range keys {
+		snapshot, err := cache.GetSnapshot(k)
+		if err != nil {
+			// the proxy has not received any snapshot yet
+			continue
+		}
+		byType := map[string]snapshotResources{}
+		for typ := envoytypes.ResponseType(0); typ < envoytypes.UnknownType; typ++ {
+			typeURL, err := envoycache.GetResponseTypeURL(typ)
+			if err != nil {
+				continue
+			}
+			resources := snapshot.GetResources(typeURL)
+			if len(resources) == 0 {
+				continue
+			}
+			out := snapshotResources{Version: snapshot.GetVersion(typeURL)}
+			if typ == envoytypes.Secret {
+				out.Names = slices.Sorted(maps.Keys(resources))
+				byType[typeURL] = out
+				continue
+			}
+			out.Resources = map[string]json.RawMessage{}
+			for name, r := range resources {
+				b, err := protojson.Marshal(r)
+				if err != nil {
+					b, _ = json.Marshal(err.Error())
+				}
+				out.Resources[name] = b
+			}
+			byType[typeURL] = out
+		}
+		ret[k] = byType
+	}
+	return ret
+}
@@ -3,59 +3,109 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
 	"github.com/fleezesd/fgateway/internal/fgateway/deployer"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/logutil"
 	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
 const (
 	// field name used for indexing
 	GatewayParamsField = "gateway-params"
+	// GatewayCertificateRefsField indexes the Gateways by the namespace/name of the Secrets of their certificateRefs
+	GatewayCertificateRefsField = "gateway-certificate-refs"
+
+	gatewayParametersKind = "GatewayParameters"
 )
 
 type GatewayConfig struct {
 	Mgr manager.Manager
 
 	OurGateway             func(gw *apiv1.Gateway) bool
+	OurGatewayClass        func(gwc *apiv1.GatewayClass) bool
 	ControllerName         string
 	Dev                    bool
 	AutoProvision          bool
 	EnableIstioIntegration bool
 	ControlPlane           *deployer.ControlPlaneInfo
 	Aws                    *deployer.AwsInfo
+	ConflictPolicies       map[schema.GroupKind]deployer.ConflictPolicy
+	// Nacks is the configuration the proxies rejected, which is reported in the Programmed condition of their
+	// Gateways, and in the Accepted condition of the routes that produced it, when set
+	Nacks *krtcollections.Nacks
+	// Logging filters the logs of the deployer by the level of its scope
+	Logging *logutil.Registry
+	// Secrets is the krt collection of the Secrets of the cluster, through which the certificateRefs of the Gateways
+	// and the caCertificateRefs of the BackendTLSPolicies are resolved and watched, so that the manager does not cache
+	// every Secret a second time
+	Secrets krt.Collection[*corev1.Secret]
+	// ConfigMaps is the krt collection of the ConfigMaps of the cluster, through which the caCertificateRefs of the
+	// BackendTLSPolicies are resolved and watched, for the same reason
+	ConfigMaps krt.Collection[*corev1.ConfigMap]
+	// Gateways is the krt collection of the IR the Gateways are translated to, holding the proxy selectors they serve
+	Gateways krt.Collection[ir.GatewayIR]
 }
 
 type controllerBuilder struct {
 	cfg        GatewayConfig
 	reconciler *controllerReconciler
+	routeTypes []routeType
 }
 
 func NewBaseGatewayController(ctx context.Context, cfg GatewayConfig) error {
 	log := log.FromContext(ctx)
 	log.V(5).Info("starting controller", "controllerName", cfg.ControllerName)
 
+	routeTypes, err := installedRouteTypes(cfg.Mgr)
+	if err != nil {
+		return err
+	}
 	controllerBuilder := &controllerBuilder{
 		cfg: cfg,
 		reconciler: &controllerReconciler{
 			cli:    cfg.Mgr.GetClient(),
 			scheme: cfg.Mgr.GetScheme(),
 		},
+		routeTypes: routeTypes,
 	}
 	return run(ctx,
 		controllerBuilder.watchGatewayClass,
+		controllerBuilder.watchGateway,
+		controllerBuilder.watchRoutes,
+		controllerBuilder.watchBackendTLSPolicies,
 		controllerBuilder.addGatewayParamsIndex,
+		controllerBuilder.addGatewayCertificateRefsIndex,
 	)
 }
 
@@ -72,19 +122,46 @@ func run(ctx context.Context, funcs ...func(ctx context.Context) error) error {
 func (c *controllerBuilder) watchGatewayClass(ctx context.Context) error {
 	// make controller manager and with event filter
 	return ctrl.NewControllerManagedBy(c.cfg.Mgr).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
-		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
-			if gatewayClass, ok := object.(*apiv1.GatewayClass); ok {
-				return gatewayClass.Spec.ControllerName == apiv1.GatewayController(c.cfg.ControllerName)
-			}
-			return false
-		})).
-		For(&apiv1.GatewayClass{}).
+		For(&apiv1.GatewayClass{}, builder.WithPredicates(
+			predicate.GenerationChangedPredicate{},
+			predicate.NewPredicateFuncs(c.isOurGatewayClass),
+		)).
+		// re-validate the GatewayClasses referencing a GatewayParameters when it is created or deleted
+		Watches(&v1alpha1.GatewayParameters{}, handler.EnqueueRequestsFromMapFunc(c.gatewayClassesOfParameters),
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc: func(event.UpdateEvent) bool { return false },
+			})).
 		Complete(reconcile.Func(c.reconciler.ReconcileGatewayClass))
 }
 
+// isOurGatewayClass reports whether an object is a GatewayClass handled by this controller, by its controllerName
+// or as one of the extra class names
+func (c *controllerBuilder) isOurGatewayClass(obj client.Object) bool {
+	gwc, ok := obj.(*apiv1.GatewayClass)
+	return ok && c.cfg.OurGatewayClass(gwc)
+}
+
+// gatewayClassesOfParameters returns the requests of our GatewayClasses whose parametersRef is the GatewayParameters
+func (c *controllerBuilder) gatewayClassesOfParameters(ctx context.Context, obj client.Object) []reconcile.Request {
+	var gwcList apiv1.GatewayClassList
+	if err := c.reconciler.cli.List(ctx, &gwcList); err != nil {
+		log.FromContext(ctx).Error(err, "could not list GatewayClasses", "gwpNamespace", obj.GetNamespace(), "gwpName", obj.GetName())
+		return []reconcile.Request{}
+	}
+
+	var reqs []reconcile.Request
+	for _, gwc := range gwcList.Items {
+		ref := gwc.Spec.ParametersRef
+		if !c.cfg.OurGatewayClass(&gwc) || ref == nil ||
+			ref.Name != obj.GetName() || ref.Namespace == nil || string(*ref.Namespace) != obj.GetNamespace() {
+			continue
+		}
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKey{Name: gwc.Name}})
+	}
+	return reqs
+}
+
 func (c *controllerBuilder) watchGateway(ctx context.Context) error {
-	// todo: add watch gateway logic
 	log := log.FromContext(ctx)
 
 	log.Info("creating deployer",
@@ -98,6 +175,8 @@ func (c *controllerBuilder) watchGateway(ctx context.Context) error {
 		IstioIntegrationEnabled: c.cfg.EnableIstioIntegration,
 		ControlPlane:            c.cfg.ControlPlane,
 		Aws:                     c.cfg.Aws,
+		ConflictPolicies:        c.cfg.ConflictPolicies,
+		Logging:                 c.cfg.Logging,
 	})
 	if err != nil {
 		return err
@@ -144,6 +223,90 @@ func (c *controllerBuilder) watchGateway(ctx context.Context) error {
 		},
 	))
 
+	// watch for gatewayclasses, gateways may be created, or seen before the gatewayclass cache synced, before their
+	// class. The class of the event is matched rather than the one of the cache, which may not have it yet.
+	buildr.Watches(&apiv1.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(
+		func(ctx context.Context, obj client.Object) []reconcile.Request {
+			gwc, ok := obj.(*apiv1.GatewayClass)
+			if !ok || !c.cfg.OurGatewayClass(gwc) {
+				return []reconcile.Request{}
+			}
+			var gwList apiv1.GatewayList
+			if err := cli.List(ctx, &gwList); err != nil {
+				log.Error(err, "could not list Gateways", "gatewayClass", obj.GetName())
+				return []reconcile.Request{}
+			}
+			var reqs []reconcile.Request
+			for _, gw := range gwList.Items {
+				if string(gw.Spec.GatewayClassName) == gwc.Name {
+					reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gw)})
+				}
+			}
+			return reqs
+		},
+	), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	// watch for the secrets of certificateRefs, which are part of the listener status
+	buildr.WatchesRawSource(krtSource(c.cfg.Secrets, c.gatewaysOfSecret))
+
+	// watch for the ReferenceGrants allowing the certificateRefs to other namespaces
+	buildr.Watches(&apiv1beta1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(
+		func(ctx context.Context, obj client.Object) []reconcile.Request {
+			grant, ok := obj.(*apiv1beta1.ReferenceGrant)
+			if !ok {
+				return []reconcile.Request{}
+			}
+			var reqs []reconcile.Request
+			for _, from := range grant.Spec.From {
+				if from.Group != apiv1.GroupName || from.Kind != "Gateway" {
+					continue
+				}
+				var gwList apiv1.GatewayList
+				if err := cli.List(ctx, &gwList, client.InNamespace(string(from.Namespace))); err != nil {
+					log.Error(err, "could not list Gateways", "namespace", from.Namespace)
+					continue
+				}
+				for _, gw := range gwList.Items {
+					if c.cfg.OurGateway(&gw) {
+						reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gw)})
+					}
+				}
+			}
+			return reqs
+		},
+	), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	// watch for routes attaching to or detaching from our gateways to keep the listener status up to date
+	for _, rt := range c.routeTypes {
+		buildr.Watches(rt.newObject(), handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				var reqs []reconcile.Request
+				for _, ref := range rt.info(obj).parentRefs {
+					if ptr.Deref(ref.Group, apiv1.GroupName) != apiv1.GroupName || ptr.Deref(ref.Kind, "Gateway") != "Gateway" {
+						continue
+					}
+					key := client.ObjectKey{
+						Namespace: string(ptr.Deref(ref.Namespace, apiv1.Namespace(obj.GetNamespace()))),
+						Name:      string(ref.Name),
+					}
+					var gw apiv1.Gateway
+					if err := cli.Get(ctx, key, &gw); err != nil || !c.cfg.OurGateway(&gw) {
+						continue
+					}
+					reqs = append(reqs, reconcile.Request{NamespacedName: key})
+				}
+				return reqs
+			},
+		), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+
+	// watch for the configuration the proxies reject and accept again, which is part of the programmed condition
+	if c.cfg.Nacks != nil {
+		buildr.WatchesRawSource(nacksSource(c.cfg.Nacks, func(_ context.Context, gw types.NamespacedName) []reconcile.Request {
+			return []reconcile.Request{{NamespacedName: gw}}
+		}))
+	}
+
 	for _, gvk := range gvks {
 		obj, err := c.cfg.Mgr.GetScheme().New(gvk)
 		if err != nil {
@@ -168,6 +331,9 @@ func (c *controllerBuilder) watchGateway(ctx context.Context) error {
 		scheme:        c.cfg.Mgr.GetScheme(),
 		autoProvision: c.cfg.AutoProvision,
 		deployer:      deployer,
+		routeTypes:    c.routeTypes,
+		nacks:         c.cfg.Nacks,
+		secrets:       c.cfg.Secrets,
 	}
 	err = buildr.Complete(gwReconciler)
 	if err != nil {
@@ -176,6 +342,317 @@ func (c *controllerBuilder) watchGateway(ctx context.Context) error {
 	return nil
 }
 
+// nacksSource enqueues the requests mapped from the Gateways whose proxies reject their configuration, or accept it
+// again. The xds server must not wait for the controller, so a rejection only marks its Gateway as pending, and the
+// pending Gateways are mapped once by the source.
+func nacksSource(nacks *krtcollections.Nacks, mapFunc func(ctx context.Context, gw types.NamespacedName) []reconcile.Request) source.Source {
+	return source.Func(func(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+		var mu sync.Mutex
+		pending := sets.New[types.NamespacedName]()
+		notify := make(chan struct{}, 1)
+		nacks.Subscribe(func(role string) {
+			namespace, name, ok := xds.ParseGatewayProxyRole(role)
+			if !ok || ctx.Err() != nil {
+				return
+			}
+			mu.Lock()
+			pending.Insert(types.NamespacedName{Namespace: namespace, Name: name})
+			mu.Unlock()
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		})
+		go func() {
+			for {
+				select {
+				case <-notify:
+				case <-ctx.Done():
+					return
+				}
+				mu.Lock()
+				gws := pending
+				pending = sets.New[types.NamespacedName]()
+				mu.Unlock()
+				for gw := range gws {
+					for _, req := range mapFunc(ctx, gw) {
+						queue.Add(req)
+					}
+				}
+			}
+		}()
+		return nil
+	})
+}
+
+// krtSource enqueues the requests mapped from the objects of a krt collection when they change, rather than watching
+// them through the manager, which would cache every Secret or ConfigMap of the cluster a second time
+func krtSource[T client.Object](objs krt.Collection[T], mapFunc handler.MapFunc) source.Source {
+	return source.Func(func(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+		objs.Register(func(e krt.Event[T]) {
+			if ctx.Err() != nil {
+				return
+			}
+			for _, req := range mapFunc(ctx, e.Latest()) {
+				queue.Add(req)
+			}
+		})
+		return nil
+	})
+}
+
+// proxySelectorsSource enqueues the requests mapped from a Gateway when the proxy selectors it serves change in its
+// IR, which are the ones of its routes of any kind
+func proxySelectorsSource(gateways krt.Collection[ir.GatewayIR], mapFunc func(ctx context.Context, gw types.NamespacedName) []reconcile.Request) source.Source {
+	return source.Func(func(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+		gateways.Register(func(e krt.Event[ir.GatewayIR]) {
+			if ctx.Err() != nil {
+				return
+			}
+			// every variant holds the selectors of the Gateway, the one matching no selector is always translated
+			gwIR := e.Latest()
+			if gwIR.Variant != 0 {
+				return
+			}
+			if e.Old != nil && e.New != nil && slices.Equal(e.Old.ProxySelectors, e.New.ProxySelectors) {
+				return
+			}
+			namespace, name, ok := xds.ParseGatewayProxyRole(gwIR.Role)
+			if !ok {
+				return
+			}
+			for _, req := range mapFunc(ctx, types.NamespacedName{Namespace: namespace, Name: name}) {
+				queue.Add(req)
+			}
+		})
+		return nil
+	})
+}
+
+func (c *controllerBuilder) watchRoutes(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	for _, rt := range routeTypes {
+		if !slices.ContainsFunc(c.routeTypes, func(installed routeType) bool { return installed.kind == rt.kind }) {
+			log.Info("route CRD is not installed, not watching it", "kind", rt.kind.Kind)
+			continue
+		}
+		if err := c.watchRouteStatus(ctx, rt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchRouteStatus reconciles the status of the routes of a kind when they change, when the Gateways they
+// reference change, when the Services they refer to change, when the ReferenceGrants of other namespaces change, and
+// when the other routes of their Gateways change, as they share the proxy selectors of the Gateways, and when the
+// proxies of their Gateways reject or accept their configuration
+func (c *controllerBuilder) watchRouteStatus(ctx context.Context, rt routeType) error {
+	log := log.FromContext(ctx)
+	cli := c.cfg.Mgr.GetClient()
+
+	enqueueRoutes := func(ctx context.Context, match func(route client.Object) bool, opts ...client.ListOption) []reconcile.Request {
+		routes, err := rt.list(ctx, cli, opts...)
+		if err != nil {
+			log.Error(err, "could not list routes", "kind", rt.kind.Kind)
+			return []reconcile.Request{}
+		}
+		var reqs []reconcile.Request
+		for _, route := range routes {
+			if match(route) {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(route)})
+			}
+		}
+		return reqs
+	}
+
+	gatewayRoutes := func(ctx context.Context, gw *apiv1.Gateway) []reconcile.Request {
+		return enqueueRoutes(ctx, func(route client.Object) bool {
+			return slices.ContainsFunc(rt.info(route).parentRefs, func(ref apiv1.ParentReference) bool {
+				return translator.ParentRefSelectsGateway(ref, route.GetNamespace(), gw)
+			})
+		})
+	}
+	enqueueGatewayRoutes := handler.EnqueueRequestsFromMapFunc(
+		func(ctx context.Context, obj client.Object) []reconcile.Request {
+			gw, ok := obj.(*apiv1.Gateway)
+			if !ok {
+				return []reconcile.Request{}
+			}
+			return gatewayRoutes(ctx, gw)
+		},
+	)
+
+	buildr := ctrl.NewControllerManagedBy(c.cfg.Mgr).
+		Named(strings.ToLower(string(rt.kind.Kind))+"-status").
+		// the proxy selector annotation is part of the accepted condition
+		For(rt.newObject(), builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+		))).
+		Watches(&apiv1.Gateway{}, enqueueGatewayRoutes, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				// routes may refer to the Services of other namespaces when a ReferenceGrant allows it
+				return enqueueRoutes(ctx, func(route client.Object) bool {
+					return slices.ContainsFunc(rt.info(route).backendRefs, func(ref apiv1.BackendObjectReference) bool {
+						return string(ref.Name) == obj.GetName() &&
+							string(ptr.Deref(ref.Namespace, apiv1.Namespace(route.GetNamespace()))) == obj.GetNamespace()
+					})
+				})
+			},
+		)).
+		// the ReferenceGrants allow the backendRefs to other namespaces
+		Watches(&apiv1beta1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				grant, ok := obj.(*apiv1beta1.ReferenceGrant)
+				if !ok {
+					return []reconcile.Request{}
+				}
+				var reqs []reconcile.Request
+				for _, from := range grant.Spec.From {
+					if from.Group == ptr.Deref(rt.kind.Group, apiv1.GroupName) && from.Kind == rt.kind.Kind {
+						reqs = append(reqs, enqueueRoutes(ctx, func(client.Object) bool { return true }, client.InNamespace(string(from.Namespace)))...)
+					}
+				}
+				return reqs
+			},
+		), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	// the routes of a Gateway share its proxy selectors, of which only the ones of the oldest routes are served
+	if c.cfg.Gateways != nil {
+		buildr.WatchesRawSource(proxySelectorsSource(c.cfg.Gateways, func(ctx context.Context, gw types.NamespacedName) []reconcile.Request {
+			return enqueueRoutes(ctx, func(route client.Object) bool {
+				if _, ok := route.GetAnnotations()[wellknown.ProxySelectorAnnotation]; !ok {
+					return false
+				}
+				return slices.Contains(translator.ParentGateways(route.GetNamespace(), rt.info(route).parentRefs), gw)
+			})
+		}))
+	}
+	// the configuration the proxies of a Gateway reject is part of the accepted condition of its routes
+	if c.cfg.Nacks != nil {
+		buildr.WatchesRawSource(nacksSource(c.cfg.Nacks, func(ctx context.Context, gw types.NamespacedName) []reconcile.Request {
+			return gatewayRoutes(ctx, &apiv1.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: gw.Namespace, Name: gw.Name}})
+		}))
+	}
+	return buildr.Complete(&routeStatusReconciler{
+		cli:            cli,
+		controllerName: c.cfg.ControllerName,
+		ourGateway:     c.cfg.OurGateway,
+		routeType:      rt,
+		nacks:          c.cfg.Nacks,
+		secrets:        c.cfg.Secrets,
+		gateways:       c.cfg.Gateways,
+	})
+}
+
+// watchBackendTLSPolicies reconciles the status of the BackendTLSPolicies when they change, when the ConfigMaps and
+// Secrets of their CA certificates change, when the Services they target change, and when the routes and Gateways
+// they apply through change. The policies are not watched when their CRD is not installed.
+func (c *controllerBuilder) watchBackendTLSPolicies(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	installed, err := crdInstalled(c.cfg.Mgr, &apiv1alpha3.BackendTLSPolicy{})
+	if err != nil {
+		return err
+	}
+	if !installed {
+		log.Info("BackendTLSPolicy CRD is not installed, not watching it")
+		return nil
+	}
+	cli := c.cfg.Mgr.GetClient()
+
+	enqueuePolicies := func(ctx context.Context, namespace string, match func(policy *apiv1alpha3.BackendTLSPolicy) bool) []reconcile.Request {
+		var policies apiv1alpha3.BackendTLSPolicyList
+		if err := cli.List(ctx, &policies, client.InNamespace(namespace)); err != nil {
+			log.Error(err, "could not list BackendTLSPolicies", "namespace", namespace)
+			return []reconcile.Request{}
+		}
+		var reqs []reconcile.Request
+		for i := range policies.Items {
+			if match(&policies.Items[i]) {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policies.Items[i])})
+			}
+		}
+		return reqs
+	}
+	caCertificateRequests := func(kind apiv1.Kind) handler.MapFunc {
+		return func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return enqueuePolicies(ctx, obj.GetNamespace(), func(policy *apiv1alpha3.BackendTLSPolicy) bool {
+				return policyReferencesCACertificate(policy, kind, obj)
+			})
+		}
+	}
+
+	buildr := ctrl.NewControllerManagedBy(c.cfg.Mgr).
+		Named("backendtlspolicy-status").
+		For(&apiv1alpha3.BackendTLSPolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WatchesRawSource(krtSource(c.cfg.ConfigMaps, caCertificateRequests("ConfigMap"))).
+		WatchesRawSource(krtSource(c.cfg.Secrets, caCertificateRequests("Secret"))).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return enqueuePolicies(ctx, obj.GetNamespace(), func(policy *apiv1alpha3.BackendTLSPolicy) bool {
+					return slices.ContainsFunc(policy.Spec.TargetRefs, func(t apiv1alpha2.LocalPolicyTargetReferenceWithSectionName) bool {
+						return t.Group == "" && t.Kind == "Service" && string(t.Name) == obj.GetName()
+					})
+				})
+			},
+		)).
+		// a new Gateway may be the ancestor of the policies of the routes attached to it
+		Watches(&apiv1.Gateway{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return enqueuePolicies(ctx, "", func(*apiv1alpha3.BackendTLSPolicy) bool { return true })
+			},
+		), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	for _, rt := range c.routeTypes {
+		buildr.Watches(rt.newObject(), handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				backendRefs := rt.info(obj).backendRefs
+				namespaces := sets.New[string]()
+				for _, ref := range backendRefs {
+					namespaces.Insert(string(ptr.Deref(ref.Namespace, apiv1.Namespace(obj.GetNamespace()))))
+				}
+				var reqs []reconcile.Request
+				for ns := range namespaces {
+					reqs = append(reqs, enqueuePolicies(ctx, ns, func(policy *apiv1alpha3.BackendTLSPolicy) bool {
+						return slices.ContainsFunc(backendRefs, func(ref apiv1.BackendObjectReference) bool {
+							return policyTargetsBackend(policy, obj.GetNamespace(), ref)
+						})
+					})...)
+				}
+				return reqs
+			},
+		), builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	return buildr.Complete(&backendTLSPolicyStatusReconciler{
+		cli:            cli,
+		controllerName: c.cfg.ControllerName,
+		ourGateway:     c.cfg.OurGateway,
+		routeTypes:     c.routeTypes,
+		secrets:        c.cfg.Secrets,
+		configMaps:     c.cfg.ConfigMaps,
+	})
+}
+
+// gatewaysOfSecret returns the requests of our Gateways with a certificateRef to the Secret, looked up by the
+// certificateRefs index rather than by listing every Gateway for every Secret event
+func (c *controllerBuilder) gatewaysOfSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	var gwList apiv1.GatewayList
+	err := c.reconciler.cli.List(ctx, &gwList, client.MatchingFieldsSelector{
+		Selector: fields.OneTermEqualSelector(GatewayCertificateRefsField, client.ObjectKeyFromObject(secret).String()),
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "could not list Gateways referencing Secret", "secretNamespace", secret.GetNamespace(), "secretName", secret.GetName())
+		return []reconcile.Request{}
+	}
+	var reqs []reconcile.Request
+	for _, gw := range gwList.Items {
+		if c.cfg.OurGateway(&gw) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gw)})
+		}
+	}
+	return reqs
+}
+
 func shouldIgnoreStatusChild(gvk schema.GroupVersionKind) bool {
 	// avoid triggering on pod changes that update deployment status
 	return gvk.Kind == "Deployment"
@@ -186,6 +663,35 @@ func (c *controllerBuilder) addGatewayParamsIndex(ctx context.Context) error {
 	return c.cfg.Mgr.GetFieldIndexer().IndexField(ctx, &apiv1.Gateway{}, GatewayParamsField, gatewayToParams)
 }
 
+func (c *controllerBuilder) addGatewayCertificateRefsIndex(ctx context.Context) error {
+	return c.cfg.Mgr.GetFieldIndexer().IndexField(ctx, &apiv1.Gateway{}, GatewayCertificateRefsField, gatewayToCertificateRefs)
+}
+
+// gatewayToCertificateRefs returns the namespace/name of the Secrets of the certificateRefs of the listeners of a
+// Gateway
+func gatewayToCertificateRefs(obj client.Object) []string {
+	gw, ok := obj.(*apiv1.Gateway)
+	if !ok {
+		panic(fmt.Sprintf("wrong type %T provided to indexer, expected Gateway", obj))
+	}
+	var refs []string
+	for _, l := range gw.Spec.Listeners {
+		if l.TLS == nil {
+			continue
+		}
+		for _, ref := range l.TLS.CertificateRefs {
+			if ptr.Deref(ref.Group, "") != "" || ptr.Deref(ref.Kind, "Secret") != "Secret" {
+				continue
+			}
+			key := types.NamespacedName{Namespace: string(ptr.Deref(ref.Namespace, apiv1.Namespace(gw.Namespace))), Name: string(ref.Name)}
+			if !slices.Contains(refs, key.String()) {
+				refs = append(refs, key.String())
+			}
+		}
+	}
+	return refs
+}
+
 func gatewayToParams[T client.IndexerFunc](obj client.Object) []string {
 	gw, ok := obj.(*apiv1.Gateway)
 	if !ok {
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"cmp"
+	"slices"
+
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/pkg/features"
+)
+
+// implementedFeatures is the set of Gateway API features implemented by the controller. It is published in the
+// status of the GatewayClasses we manage, and must be extended as support for new features lands.
+var implementedFeatures = []features.FeatureName{
+	features.SupportGateway,
+	features.SupportReferenceGrant,
+	features.SupportHTTPRoute,
+	features.SupportHTTPRouteQueryParamMatching,
+	features.SupportHTTPRouteMethodMatching,
+	features.SupportHTTPRouteResponseHeaderModification,
+	features.SupportHTTPRoutePortRedirect,
+	features.SupportHTTPRouteSchemeRedirect,
+	features.SupportHTTPRoutePathRedirect,
+	features.SupportHTTPRouteHostRewrite,
+	features.SupportHTTPRoutePathRewrite,
+	features.SupportHTTPRouteRequestMirror,
+	features.SupportHTTPRouteRequestMultipleMirrors,
+	features.SupportHTTPRouteParentRefPort,
+	features.SupportGRPCRoute,
+	features.SupportTLSRoute,
+	features.SupportUDPRoute,
+}
+
+// supportedFeatures returns the implemented features in the sorted form the GatewayClass status expects
+func supportedFeatures() []apiv1.SupportedFeature {
+	ret := make([]apiv1.SupportedFeature, 0, len(implementedFeatures))
+	for _, f := range implementedFeatures {
+		ret = append(ret, apiv1.SupportedFeature{Name: apiv1.FeatureName(f)})
+	}
+	slices.SortFunc(ret, func(a, b apiv1.SupportedFeature) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return ret
+}
@@ -2,9 +2,13 @@ package controller
 
 import (
 	"context"
-	"slices"
+	"errors"
+	"time"
 
 	"github.com/fleezesd/fgateway/internal/fgateway/deployer"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"istio.io/istio/pkg/kube/krt"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -17,6 +21,9 @@ import (
 
 const (
 	GatewayAutoDeployAnnotationKey = "gateway.fgateway.dev/auto-deploy"
+
+	// conflictRequeueInterval is how long to wait before re-applying objects that backed off on a field ownership conflict
+	conflictRequeueInterval = time.Minute
 )
 
 type gatewayReconciler struct {
@@ -24,6 +31,11 @@ type gatewayReconciler struct {
 	autoProvision bool
 	scheme        *runtime.Scheme
 	deployer      *deployer.Deployer
+	routeTypes    []routeType
+	// nacks is the configuration rejected by the proxies, nil when it is not tracked
+	nacks *krtcollections.Nacks
+	// secrets are the Secrets of the certificateRefs of the listeners
+	secrets krt.Collection[*corev1.Secret]
 }
 
 func (r *gatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -33,7 +45,7 @@ func (r *gatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	ns := req.Namespace
 
 	var namespace corev1.Namespace
-	if err := r.cli.Get(ctx, types.NamespacedName{Namespace: ns}, &namespace); err != nil {
+	if err := r.cli.Get(ctx, types.NamespacedName{Name: ns}, &namespace); err != nil {
 		log.Error(err, "failed to get namespace")
 		return ctrl.Result{}, err
 	}
@@ -55,57 +67,74 @@ func (r *gatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	log.Info("reconciling gateway")
-	objs, err := r.deployer.GetObjsToDeploy(ctx, &gw)
+	objs, selfManaged, err := r.deployer.GetObjsToDeploy(ctx, &gw)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+
+	// server-side apply the rendered proxy objects
 	result := ctrl.Result{}
-	for _, obj := range objs {
-		if svc, ok := obj.(*corev1.Service); ok {
-			err := updateStatus(ctx, r.cli, &gw, &svc.ObjectMeta)
-			if err != nil {
-				log.Error(err, "failed to update status")
-				result.Requeue = true
+	status := gatewayStatus{addresses: gw.Status.Addresses}
+	if r.nacks != nil {
+		status.rejected = r.nacks.Get(xds.GatewayProxyRole(gw.Namespace, gw.Name))
+	}
+	if err := r.deployer.DeployObjs(ctx, objs); err != nil {
+		var conflictErr *deployer.ApplyConflictError
+		if !errors.As(err, &conflictErr) {
+			return ctrl.Result{}, err
+		}
+		// another field manager owns fields we render; retrying immediately won't help
+		log.Info("skipped applying objects due to field ownership conflicts", "error", conflictErr.Error())
+		result.RequeueAfter = conflictRequeueInterval
+		status.notProgrammed = conflictErr
+	}
+
+	// remove objects deployed for this gateway that are no longer rendered
+	if err := r.deployer.PruneObjs(ctx, &gw, objs); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if selfManaged {
+		// nothing is provisioned, the addresses the user declared are the ones the proxy is reachable at
+		status.addresses = specAddresses(&gw)
+	} else {
+		status.requestedIP, status.unusableAddresses = deployer.RequestedServiceAddress(&gw)
+		for _, obj := range objs {
+			if svc, ok := obj.(*corev1.Service); ok {
+				addresses, err := getServiceAddresses(ctx, r.cli, &gw, &svc.ObjectMeta)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				status.addresses = addresses
 			}
 		}
 	}
 
-	// todo: deployer deploy objs later
-	return ctrl.Result{}, nil
+	if err := r.updateGatewayStatus(ctx, &gw, status); err != nil {
+		log.Error(err, "failed to update status")
+		result.Requeue = true
+	}
+	return result, nil
 }
 
-func updateStatus(ctx context.Context, cli client.Client, gw *apiv1.Gateway, svcmd *metav1.ObjectMeta) error {
+// getServiceAddresses returns the addresses of the proxy service deployed for the gateway
+func getServiceAddresses(ctx context.Context, cli client.Client, gw *apiv1.Gateway, svcmd *metav1.ObjectMeta) ([]apiv1.GatewayStatusAddress, error) {
 	svcnns := client.ObjectKey{
 		Namespace: svcmd.Namespace,
 		Name:      svcmd.Name,
 	}
 	var svc corev1.Service
 	if err := cli.Get(ctx, svcnns, &svc); err != nil {
-		return client.IgnoreNotFound(err)
+		return nil, client.IgnoreNotFound(err)
 	}
 
 	// make sure we own this service
 	controller := metav1.GetControllerOf(&svc)
-	if controller == nil {
-		return nil
-	}
-
-	if gw.UID != controller.UID {
-		return nil
+	if controller == nil || gw.UID != controller.UID {
+		return nil, nil
 	}
 
-	// update gateway addresses in the status
-	desiredAddresses := getDesiredAddresses(&svc)
-	actualAddresses := gw.Status.Addresses
-	if slices.Equal(desiredAddresses, actualAddresses) {
-		return nil
-	}
-
-	gw.Status.Addresses = desiredAddresses
-	if err := cli.Status().Patch(ctx, gw, client.Merge); err != nil {
-		return err
-	}
-	return nil
+	return getDesiredAddresses(&svc), nil
 }
 
 func getDesiredAddresses(svc *corev1.Service) []apiv1.GatewayStatusAddress {
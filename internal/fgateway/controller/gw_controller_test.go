@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/deployer"
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"istio.io/istio/pkg/kube/krt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestReconcileGateway(t *testing.T) {
+	tests := []struct {
+		name          string
+		autoProvision bool
+		nsAnnotations map[string]string
+		wantDeployed  bool
+	}{
+		{name: "auto provisioning", autoProvision: true, wantDeployed: true},
+		{
+			name:          "namespace enabled for auto deploy",
+			nsAnnotations: map[string]string{GatewayAutoDeployAnnotationKey: "true"},
+			wantDeployed:  true,
+		},
+		{name: "namespace not enabled for auto deploy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: tt.nsAnnotations}}
+			gwc := &apiv1.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "fgateway"},
+				Spec: apiv1.GatewayClassSpec{
+					ControllerName: "fgateway.dev/controller",
+					ParametersRef: &apiv1.ParametersReference{
+						Group:     apiv1.Group(v1alpha1.GroupVersion.Group),
+						Kind:      gatewayParametersKind,
+						Name:      "default",
+						Namespace: ptr.To[apiv1.Namespace]("system"),
+					},
+				},
+			}
+			gwp := &v1alpha1.GatewayParameters{ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "default"}}
+			gw := &apiv1.Gateway{
+				TypeMeta:   metav1.TypeMeta{APIVersion: apiv1.GroupVersion.String(), Kind: "Gateway"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw", UID: "gw-uid", Generation: 1},
+				Spec: apiv1.GatewaySpec{
+					GatewayClassName: "fgateway",
+					Listeners:        []apiv1.Listener{{Name: "http", Port: 8080, Protocol: apiv1.HTTPProtocolType}},
+				},
+			}
+			cli := fake.NewClientBuilder().
+				WithScheme(DefaultScheme()).
+				WithObjects(ns, gwc, gwp, gw).
+				WithStatusSubresource(gw).
+				WithInterceptorFuncs(interceptor.Funcs{Patch: applyAsCreate}).
+				Build()
+			d, err := deployer.NewDeployer(cli, &deployer.Inputs{
+				ControllerName: "fgateway.dev/controller",
+				ControlPlane:   &deployer.ControlPlaneInfo{XdsHost: "fgateway.system.svc", XdsPort: 9000},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &gatewayReconciler{cli: cli, scheme: cli.Scheme(), autoProvision: tt.autoProvision, deployer: d}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gw)}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+
+			var deployments appsv1.DeploymentList
+			var services corev1.ServiceList
+			if err := cli.List(context.Background(), &deployments, client.InNamespace("default")); err != nil {
+				t.Fatal(err)
+			}
+			if err := cli.List(context.Background(), &services, client.InNamespace("default")); err != nil {
+				t.Fatal(err)
+			}
+			if deployed := len(deployments.Items) == 1 && len(services.Items) == 1; deployed != tt.wantDeployed {
+				t.Fatalf("deployed %d deployments and %d services, want a proxy deployed %v",
+					len(deployments.Items), len(services.Items), tt.wantDeployed)
+			}
+
+			var got apiv1.Gateway
+			if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantDeployed {
+				if len(got.Status.Conditions) != 0 {
+					t.Errorf("conditions = %v, want the status left alone", got.Status.Conditions)
+				}
+				return
+			}
+			for _, typ := range []apiv1.GatewayConditionType{apiv1.GatewayConditionAccepted, apiv1.GatewayConditionProgrammed} {
+				if cond := meta.FindStatusCondition(got.Status.Conditions, string(typ)); cond == nil || cond.Status != metav1.ConditionTrue {
+					t.Errorf("%s condition = %v, want true", typ, cond)
+				}
+			}
+			if len(got.Status.Addresses) != 1 || got.Status.Addresses[0].Value != "10.0.0.1" {
+				t.Errorf("addresses = %v, want the address of the proxy service", got.Status.Addresses)
+			}
+		})
+	}
+}
+
+// applyAsCreate creates the objects the deployer server-side applies, as the fake client does not support apply
+// patches, assigning an address to services like the api server and the load balancer controller do
+func applyAsCreate(ctx context.Context, cli client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != client.Apply.Type() {
+		return cli.Patch(ctx, obj, patch, opts...)
+	}
+	if svc, ok := obj.(*corev1.Service); ok {
+		svc.Spec.ClusterIP = "10.0.0.1"
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+	}
+	err := cli.Create(ctx, obj)
+	if apierrors.IsAlreadyExists(err) {
+		return cli.Update(ctx, obj)
+	}
+	return err
+}
+
+func TestGatewaySecretsFromKrt(t *testing.T) {
+	secret := tlsSecret(t, "default", "cert")
+	gw := &apiv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"},
+		Spec: apiv1.GatewaySpec{
+			GatewayClassName: "fgateway",
+			Listeners: []apiv1.Listener{{
+				Name:     "https",
+				Port:     8443,
+				Protocol: apiv1.HTTPSProtocolType,
+				TLS:      &apiv1.GatewayTLSConfig{CertificateRefs: []apiv1.SecretObjectReference{{Name: "cert"}}},
+			}},
+		},
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	secrets := krt.NewStaticCollection([]*corev1.Secret{secret}, krt.WithStop(stop))
+
+	// the Secret is only in the krt collection, not in the cache of the manager
+	cli := fake.NewClientBuilder().WithScheme(DefaultScheme()).WithObjects(gw).Build()
+	var getErr error
+	reports := translator.ValidateListeners(gw, clientCertificateResolver(context.Background(), cli, secrets, &getErr))
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	if len(reports) != 1 || !reports[0].Valid || len(reports[0].Certificates) != 1 {
+		t.Fatalf("reports = %+v, want the certificate of the listener resolved", reports)
+	}
+
+	// the Gateways referencing a Secret are enqueued when it changes in the collection, found by the certificateRefs
+	// index
+	other := &apiv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "gw"},
+		Spec: apiv1.GatewaySpec{
+			GatewayClassName: "fgateway",
+			Listeners: []apiv1.Listener{{
+				Name:     "https",
+				Port:     8443,
+				Protocol: apiv1.HTTPSProtocolType,
+				TLS:      &apiv1.GatewayTLSConfig{CertificateRefs: []apiv1.SecretObjectReference{{Name: "cert"}}},
+			}},
+		},
+	}
+	indexed := fake.NewClientBuilder().
+		WithScheme(DefaultScheme()).
+		WithObjects(gw, other).
+		WithIndex(&apiv1.Gateway{}, GatewayCertificateRefsField, gatewayToCertificateRefs).
+		Build()
+	c := &controllerBuilder{
+		cfg:        GatewayConfig{Secrets: secrets, OurGateway: func(*apiv1.Gateway) bool { return true }},
+		reconciler: &controllerReconciler{cli: indexed},
+	}
+	src := krtSource(secrets, c.gatewaysOfSecret)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	if err := src.Start(context.Background(), queue); err != nil {
+		t.Fatal(err)
+	}
+	secrets.UpdateObject(secret)
+	if req, _ := queue.Get(); req.NamespacedName != client.ObjectKeyFromObject(gw) {
+		t.Errorf("request = %v, want the gateway referencing the secret", req)
+	}
+	if n := queue.Len(); n != 0 {
+		t.Errorf("%d more requests, want only the gateway referencing the secret", n)
+	}
+}
+
+// tlsSecret returns a TLS Secret holding a self-signed certificate
+func tlsSecret(t *testing.T, namespace, name string) *corev1.Secret {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		},
+	}
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"github.com/pkg/errors"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// gatewayStatus is what the reconciler learned about a Gateway while deploying its proxy
+type gatewayStatus struct {
+	addresses []apiv1.GatewayStatusAddress
+	// requestedIP is the address from Gateway.spec.addresses the proxy service was provisioned with
+	requestedIP string
+	// unusableAddresses are the addresses from Gateway.spec.addresses that could not be requested
+	unusableAddresses []apiv1.GatewayAddress
+	// notProgrammed is set when the proxy objects could not be applied
+	notProgrammed error
+	// rejected is the configuration the proxies of the Gateway rejected
+	rejected []krtcollections.Nack
+}
+
+// updateGatewayStatus writes the addresses, the Accepted and Programmed conditions and the listener statuses of the
+// Gateway, patching it only if its status changed
+func (r *gatewayReconciler) updateGatewayStatus(ctx context.Context, gw *apiv1.Gateway, st gatewayStatus) error {
+	var getErr error
+	reports := translator.ValidateListeners(gw, clientCertificateResolver(ctx, r.cli, r.secrets, &getErr))
+	if getErr != nil {
+		return getErr
+	}
+	attached, err := r.countAttachedRoutes(ctx, gw, reports)
+	if err != nil {
+		return err
+	}
+
+	original := gw.DeepCopy()
+	gw.Status.Addresses = st.addresses
+	meta.SetStatusCondition(&gw.Status.Conditions, acceptedCondition(gw, reports))
+	meta.SetStatusCondition(&gw.Status.Conditions, programmedCondition(gw, st))
+	gw.Status.Listeners = listenerStatuses(original.Status.Listeners, reports, attached)
+
+	if equality.Semantic.DeepEqual(original.Status, gw.Status) {
+		return nil
+	}
+	return r.cli.Status().Patch(ctx, gw, client.MergeFrom(original))
+}
+
+// clientCertificateResolver looks the Secrets of certificateRefs up in the krt collection of the Secrets, and the
+// ReferenceGrants through the client. The first error is stored in getErr, in which case the resolution must be
+// discarded.
+func clientCertificateResolver(ctx context.Context, cli client.Client, secrets krt.Collection[*corev1.Secret], getErr *error) translator.CertificateResolver {
+	return translator.CertificateResolver{
+		GetSecret: krtGetter(secrets),
+		Grants:    clientReferenceGrants(ctx, cli, getErr),
+	}
+}
+
+// krtGetter gets an object from a krt collection of Secrets or ConfigMaps, which holds none when it is nil
+func krtGetter[T client.Object](objs krt.Collection[T]) func(namespace, name string) T {
+	return func(namespace, name string) T {
+		var zero T
+		if objs == nil {
+			return zero
+		}
+		return ptr.Deref(objs.GetKey(types.NamespacedName{Namespace: namespace, Name: name}.String()), zero)
+	}
+}
+
+// clientReferenceGrants lists the ReferenceGrants of a namespace through the client, storing the first error in getErr
+func clientReferenceGrants(ctx context.Context, cli client.Client, getErr *error) translator.ReferenceGrants {
+	return translator.ReferenceGrants{
+		List: func(namespace string) []*apiv1beta1.ReferenceGrant {
+			var grants apiv1beta1.ReferenceGrantList
+			if err := cli.List(ctx, &grants, client.InNamespace(namespace)); err != nil {
+				if *getErr == nil {
+					*getErr = err
+				}
+				return nil
+			}
+			ret := make([]*apiv1beta1.ReferenceGrant, 0, len(grants.Items))
+			for i := range grants.Items {
+				ret = append(ret, &grants.Items[i])
+			}
+			return ret
+		},
+	}
+}
+
+func acceptedCondition(gw *apiv1.Gateway, reports []translator.ListenerReport) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               string(apiv1.GatewayConditionAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(apiv1.GatewayReasonAccepted),
+		Message:            "Gateway is accepted",
+		ObservedGeneration: gw.Generation,
+	}
+	invalid := 0
+	for _, report := range reports {
+		if !report.Valid {
+			invalid++
+		}
+	}
+	switch {
+	case invalid == 0:
+	case invalid == len(reports):
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.GatewayReasonListenersNotValid)
+		cond.Message = "Gateway has no valid listeners"
+	default:
+		cond.Reason = string(apiv1.GatewayReasonListenersNotValid)
+		cond.Message = "Gateway has invalid listeners"
+	}
+	return cond
+}
+
+func programmedCondition(gw *apiv1.Gateway, st gatewayStatus) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               string(apiv1.GatewayConditionProgrammed),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(apiv1.GatewayReasonProgrammed),
+		Message:            "Gateway is programmed",
+		ObservedGeneration: gw.Generation,
+	}
+	switch {
+	case st.notProgrammed != nil:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.GatewayReasonPending)
+		cond.Message = st.notProgrammed.Error()
+	case len(st.rejected) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.GatewayReasonInvalid)
+		cond.Message = rejectedMessage(st.rejected)
+	case len(st.unusableAddresses) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.GatewayReasonAddressNotUsable)
+		cond.Message = fmt.Sprintf("Requested addresses can not be assigned: %s", formatAddresses(st.unusableAddresses))
+	case st.requestedIP != "" && !slices.ContainsFunc(st.addresses, func(a apiv1.GatewayStatusAddress) bool {
+		return a.Value == st.requestedIP
+	}):
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.GatewayReasonAddressNotAssigned)
+		cond.Message = fmt.Sprintf("Requested address %s has not been assigned to the Gateway", st.requestedIP)
+	case len(st.addresses) == 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.GatewayReasonAddressNotAssigned)
+		cond.Message = "No address has been assigned to the Gateway yet"
+	}
+	return cond
+}
+
+// maxRejectedMessageLength bounds the length of the error of a proxy in the programmed condition
+const maxRejectedMessageLength = 1024
+
+// rejectedMessage describes the first configuration the proxies of a Gateway rejected
+func rejectedMessage(rejected []krtcollections.Nack) string {
+	nack := rejected[0]
+	msg := nack.Message
+	if len(msg) > maxRejectedMessageLength {
+		msg = msg[:maxRejectedMessageLength] + "..."
+	}
+	ret := fmt.Sprintf("Proxy %s rejected the %s configuration", nack.NodeID, nack.TypeURL)
+	if len(nack.ResourceNames) > 0 {
+		ret += fmt.Sprintf(" of %s", strings.Join(nack.ResourceNames, ", "))
+	}
+	ret += ": " + msg
+	if len(rejected) > 1 {
+		ret += fmt.Sprintf(" (and %d more rejections)", len(rejected)-1)
+	}
+	return ret
+}
+
+// specAddresses returns the addresses declared in Gateway.spec.addresses as status addresses
+func specAddresses(gw *apiv1.Gateway) []apiv1.GatewayStatusAddress {
+	var ret []apiv1.GatewayStatusAddress
+	for _, addr := range gw.Spec.Addresses {
+		ret = append(ret, apiv1.GatewayStatusAddress{
+			Type:  ptr.To(ptr.Deref(addr.Type, apiv1.IPAddressType)),
+			Value: addr.Value,
+		})
+	}
+	return ret
+}
+
+func formatAddresses(addrs []apiv1.GatewayAddress) string {
+	values := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		values = append(values, fmt.Sprintf("%s (%s)", addr.Value, ptr.Deref(addr.Type, apiv1.IPAddressType)))
+	}
+	return strings.Join(values, ", ")
+}
+
+// listenerStatuses builds the listener statuses from the validation reports, keeping the transition
+// times of the conditions that did not change
+func listenerStatuses(existing []apiv1.ListenerStatus, reports []translator.ListenerReport, attached map[apiv1.SectionName]int32) []apiv1.ListenerStatus {
+	statuses := make([]apiv1.ListenerStatus, 0, len(reports))
+	for _, report := range reports {
+		var conditions []metav1.Condition
+		for _, ls := range existing {
+			if ls.Name == report.Name {
+				conditions = ls.Conditions
+				break
+			}
+		}
+		conditions = append([]metav1.Condition(nil), conditions...)
+		for _, cond := range report.Conditions {
+			meta.SetStatusCondition(&conditions, cond)
+		}
+		statuses = append(statuses, apiv1.ListenerStatus{
+			Name:           report.Name,
+			SupportedKinds: report.SupportedKinds,
+			AttachedRoutes: attached[report.Name],
+			Conditions:     conditions,
+		})
+	}
+	return statuses
+}
+
+// countAttachedRoutes counts, per listener, the routes that attach to it through their parentRefs
+func (r *gatewayReconciler) countAttachedRoutes(ctx context.Context, gw *apiv1.Gateway, reports []translator.ListenerReport) (map[apiv1.SectionName]int32, error) {
+	nsLabels := map[string]map[string]string{}
+	namespaceLabels := func(name string) (map[string]string, error) {
+		if l, ok := nsLabels[name]; ok {
+			return l, nil
+		}
+		var ns corev1.Namespace
+		if err := r.cli.Get(ctx, client.ObjectKey{Name: name}, &ns); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		nsLabels[name] = ns.Labels
+		return ns.Labels, nil
+	}
+
+	attached := map[apiv1.SectionName]int32{}
+	for _, rt := range r.routeTypes {
+		routes, err := rt.list(ctx, r.cli)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %ss", rt.kind.Kind)
+		}
+		for _, route := range routes {
+			info := rt.info(route)
+			routeNsLabels, err := namespaceLabels(route.GetNamespace())
+			if err != nil {
+				return nil, err
+			}
+			// a route attaches at most once to a listener
+			listeners := sets.New[int]()
+			for _, ref := range info.parentRefs {
+				if !translator.ParentRefSelectsGateway(ref, route.GetNamespace(), gw) {
+					continue
+				}
+				idxs, _ := translator.ParentRefListeners(gw, reports, ref, rt.kind, route.GetNamespace(), routeNsLabels, info.hostnames)
+				listeners.Insert(idxs...)
+			}
+			for idx := range listeners {
+				attached[gw.Spec.Listeners[idx].Name]++
+			}
+		}
+	}
+	return attached, nil
+}
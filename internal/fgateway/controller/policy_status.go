@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+)
+
+// maxPolicyAncestors is the maximum number of ancestors the status of a policy holds
+const maxPolicyAncestors = 16
+
+// backendTLSPolicyStatusReconciler reports, for every one of our Gateways routing to the Services targeted by a
+// BackendTLSPolicy, whether the policy is applied to the connections of its proxies to the backends
+type backendTLSPolicyStatusReconciler struct {
+	cli            client.Client
+	controllerName string
+	ourGateway     func(gw *apiv1.Gateway) bool
+	routeTypes     []routeType
+	// secrets are the Secrets of the caCertificateRefs of the policies
+	secrets krt.Collection[*corev1.Secret]
+	// configMaps are the ConfigMaps of the caCertificateRefs of the policies
+	configMaps krt.Collection[*corev1.ConfigMap]
+}
+
+func (r *backendTLSPolicyStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("backendTLSPolicy", req.NamespacedName)
+	log.V(1).Info("reconciling backend TLS policy status")
+
+	var policy apiv1alpha3.BackendTLSPolicy
+	if err := r.cli.Get(ctx, req.NamespacedName, &policy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := policy.DeepCopy()
+
+	ancestors, err := r.ancestors(ctx, &policy)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var accepted metav1.Condition
+	if len(ancestors) > 0 {
+		if accepted, err = r.acceptedCondition(ctx, &policy); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// keep the statuses written by other controllers, ours are all recomputed
+	statuses := make([]apiv1alpha2.PolicyAncestorStatus, 0, len(policy.Status.Ancestors)+len(ancestors))
+	for _, s := range policy.Status.Ancestors {
+		if string(s.ControllerName) != r.controllerName {
+			statuses = append(statuses, s)
+		}
+	}
+	for _, ref := range ancestors {
+		if len(statuses) == maxPolicyAncestors {
+			break
+		}
+		// keep the transition times of the conditions that did not change
+		var conditions []metav1.Condition
+		for _, s := range original.Status.Ancestors {
+			if string(s.ControllerName) == r.controllerName && equality.Semantic.DeepEqual(s.AncestorRef, ref) {
+				conditions = append(conditions, s.Conditions...)
+				break
+			}
+		}
+		meta.SetStatusCondition(&conditions, accepted)
+		statuses = append(statuses, apiv1alpha2.PolicyAncestorStatus{
+			AncestorRef:    ref,
+			ControllerName: apiv1.GatewayController(r.controllerName),
+			Conditions:     conditions,
+		})
+	}
+	policy.Status.Ancestors = statuses
+
+	if equality.Semantic.DeepEqual(original.Status, policy.Status) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.cli.Status().Patch(ctx, &policy, client.MergeFrom(original)); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// ancestors returns our Gateways with a route to one of the Services targeted by the policy, sorted by
+// namespace and name
+func (r *backendTLSPolicyStatusReconciler) ancestors(ctx context.Context, policy *apiv1alpha3.BackendTLSPolicy) ([]apiv1.ParentReference, error) {
+	var ret []apiv1.ParentReference
+	for _, rt := range r.routeTypes {
+		routes, err := rt.list(ctx, r.cli)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			info := rt.info(route)
+			if !slices.ContainsFunc(info.backendRefs, func(ref apiv1.BackendObjectReference) bool {
+				return policyTargetsBackend(policy, route.GetNamespace(), ref)
+			}) {
+				continue
+			}
+			for _, ref := range info.parentRefs {
+				if ptr.Deref(ref.Group, apiv1.GroupName) != apiv1.GroupName || ptr.Deref(ref.Kind, "Gateway") != "Gateway" {
+					continue
+				}
+				ancestor := apiv1.ParentReference{
+					Group:     ptr.To[apiv1.Group](apiv1.GroupName),
+					Kind:      ptr.To[apiv1.Kind]("Gateway"),
+					Namespace: ptr.To(ptr.Deref(ref.Namespace, apiv1.Namespace(route.GetNamespace()))),
+					Name:      ref.Name,
+				}
+				if slices.ContainsFunc(ret, func(a apiv1.ParentReference) bool { return equality.Semantic.DeepEqual(a, ancestor) }) {
+					continue
+				}
+				var gw apiv1.Gateway
+				if err := r.cli.Get(ctx, client.ObjectKey{Namespace: string(*ancestor.Namespace), Name: string(ancestor.Name)}, &gw); err != nil {
+					if apierrors.IsNotFound(err) {
+						continue
+					}
+					return nil, err
+				}
+				if r.ourGateway(&gw) {
+					ret = append(ret, ancestor)
+				}
+			}
+		}
+	}
+	slices.SortFunc(ret, func(a, b apiv1.ParentReference) int {
+		return cmp.Or(cmp.Compare(*a.Namespace, *b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return ret, nil
+}
+
+// acceptedCondition reports whether the policy applies to the ports it targets: its target Services must exist,
+// its CA certificates must resolve, and no other policy must take precedence on the ports it targets
+func (r *backendTLSPolicyStatusReconciler) acceptedCondition(ctx context.Context, policy *apiv1alpha3.BackendTLSPolicy) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               string(apiv1alpha2.PolicyConditionAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(apiv1alpha2.PolicyReasonAccepted),
+		Message:            "Policy is accepted",
+		ObservedGeneration: policy.Generation,
+	}
+
+	var getErr error
+	resolver := translator.BackendTLSResolver{
+		GetConfigMap: krtGetter(r.configMaps),
+		GetSecret:    krtGetter(r.secrets),
+	}
+	_, err := resolver.Resolve(policy)
+	if getErr != nil {
+		return cond, getErr
+	}
+	var policyErr *translator.BackendTLSPolicyError
+	if errors.As(err, &policyErr) {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(policyErr.Reason)
+		cond.Message = policyErr.Message
+		return cond, nil
+	}
+
+	var policies apiv1alpha3.BackendTLSPolicyList
+	if err := r.cli.List(ctx, &policies, client.InNamespace(policy.Namespace)); err != nil {
+		return cond, err
+	}
+	candidates := make([]*apiv1alpha3.BackendTLSPolicy, 0, len(policies.Items))
+	for i := range policies.Items {
+		candidates = append(candidates, &policies.Items[i])
+	}
+	found := false
+	for _, ref := range policy.Spec.TargetRefs {
+		if ref.Group != "" || ref.Kind != "Service" {
+			continue
+		}
+		var svc corev1.Service
+		if err := r.cli.Get(ctx, client.ObjectKey{Namespace: policy.Namespace, Name: string(ref.Name)}, &svc); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return cond, err
+		}
+		found = true
+		for _, port := range svc.Spec.Ports {
+			if ref.SectionName != nil && string(*ref.SectionName) != port.Name {
+				continue
+			}
+			if winner := translator.PolicyForPort(candidates, &svc, port); winner != nil && winner.Name != policy.Name {
+				cond.Status = metav1.ConditionFalse
+				cond.Reason = string(apiv1alpha2.PolicyReasonConflicted)
+				cond.Message = fmt.Sprintf("BackendTLSPolicy %s takes precedence on port %d of Service %s", winner.Name, port.Port, svc.Name)
+				return cond, nil
+			}
+		}
+	}
+	if !found {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1alpha2.PolicyReasonTargetNotFound)
+		cond.Message = "None of the target Services of the policy exist"
+	}
+	return cond, nil
+}
+
+// policyTargetsBackend reports whether a backendRef of a route in routeNamespace refers to a Service targeted by
+// the policy
+func policyTargetsBackend(policy *apiv1alpha3.BackendTLSPolicy, routeNamespace string, ref apiv1.BackendObjectReference) bool {
+	if ptr.Deref(ref.Group, "") != "" || ptr.Deref(ref.Kind, "Service") != "Service" ||
+		string(ptr.Deref(ref.Namespace, apiv1.Namespace(routeNamespace))) != policy.Namespace {
+		return false
+	}
+	return slices.ContainsFunc(policy.Spec.TargetRefs, func(t apiv1alpha2.LocalPolicyTargetReferenceWithSectionName) bool {
+		return t.Group == "" && t.Kind == "Service" && t.Name == ref.Name
+	})
+}
+
+// policyReferencesCACertificate reports whether the policy has a caCertificateRef to the ConfigMap or Secret
+func policyReferencesCACertificate(policy *apiv1alpha3.BackendTLSPolicy, kind apiv1.Kind, obj client.Object) bool {
+	return policy.Namespace == obj.GetNamespace() && slices.ContainsFunc(policy.Spec.Validation.CACertificateRefs, func(ref apiv1.LocalObjectReference) bool {
+		return ref.Group == "" && ref.Kind == kind && string(ref.Name) == obj.GetName()
+	})
+}
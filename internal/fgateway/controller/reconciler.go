@@ -2,10 +2,19 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 type controllerReconciler struct {
@@ -13,6 +22,72 @@ type controllerReconciler struct {
 	scheme *runtime.Scheme
 }
 
+// invalidParametersError is returned when the parametersRef of a GatewayClass can not be resolved
+// to a GatewayParameters. Its message is surfaced in the Accepted condition.
+type invalidParametersError struct {
+	msg string
+}
+
+func (e *invalidParametersError) Error() string {
+	return e.msg
+}
+
 func (r *controllerReconciler) ReconcileGatewayClass(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("gwc", req.NamespacedName)
+	log.V(1).Info("reconciling gatewayclass")
+
+	var gwc apiv1.GatewayClass
+	if err := r.cli.Get(ctx, req.NamespacedName, &gwc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	accepted := metav1.Condition{
+		Type:               string(apiv1.GatewayClassConditionStatusAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(apiv1.GatewayClassReasonAccepted),
+		Message:            "GatewayClass is accepted",
+		ObservedGeneration: gwc.Generation,
+	}
+	if err := r.validateParametersRef(ctx, gwc.Spec.ParametersRef); err != nil {
+		var invalidErr *invalidParametersError
+		if !errors.As(err, &invalidErr) {
+			return ctrl.Result{}, err
+		}
+		log.Info("gatewayclass has invalid parameters", "reason", invalidErr.msg)
+		accepted.Status = metav1.ConditionFalse
+		accepted.Reason = string(apiv1.GatewayClassReasonInvalidParameters)
+		accepted.Message = invalidErr.msg
+	}
+
+	original := gwc.DeepCopy()
+	meta.SetStatusCondition(&gwc.Status.Conditions, accepted)
+	gwc.Status.SupportedFeatures = supportedFeatures()
+	if equality.Semantic.DeepEqual(original.Status, gwc.Status) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.cli.Status().Patch(ctx, &gwc, client.MergeFrom(original)); err != nil {
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
+
+// validateParametersRef checks that the parametersRef of a GatewayClass, if set, points to an existing GatewayParameters
+func (r *controllerReconciler) validateParametersRef(ctx context.Context, ref *apiv1.ParametersReference) error {
+	if ref == nil {
+		return nil
+	}
+	if string(ref.Group) != v1alpha1.GroupVersion.Group || string(ref.Kind) != gatewayParametersKind {
+		return &invalidParametersError{msg: fmt.Sprintf("parametersRef must refer to a %s.%s, got %s.%s",
+			gatewayParametersKind, v1alpha1.GroupVersion.Group, ref.Kind, ref.Group)}
+	}
+	if ref.Namespace == nil || *ref.Namespace == "" {
+		return &invalidParametersError{msg: fmt.Sprintf("parametersRef to %s %s must set a namespace", gatewayParametersKind, ref.Name)}
+	}
+
+	var gwp v1alpha1.GatewayParameters
+	err := r.cli.Get(ctx, client.ObjectKey{Namespace: string(*ref.Namespace), Name: ref.Name}, &gwp)
+	if apierrors.IsNotFound(err) {
+		return &invalidParametersError{msg: fmt.Sprintf("%s %s/%s not found", gatewayParametersKind, *ref.Namespace, ref.Name)}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestReconcileGatewayClass(t *testing.T) {
+	gwp := &v1alpha1.GatewayParameters{ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "params"}}
+	parametersRef := func(group, kind, namespace, name string) *apiv1.ParametersReference {
+		ref := &apiv1.ParametersReference{Group: apiv1.Group(group), Kind: apiv1.Kind(kind), Name: name}
+		if namespace != "" {
+			ref.Namespace = ptr.To(apiv1.Namespace(namespace))
+		}
+		return ref
+	}
+	group := v1alpha1.GroupVersion.Group
+	tests := []struct {
+		name       string
+		ref        *apiv1.ParametersReference
+		wantStatus metav1.ConditionStatus
+		wantReason apiv1.GatewayClassConditionReason
+	}{
+		{name: "no parameters", wantStatus: metav1.ConditionTrue, wantReason: apiv1.GatewayClassReasonAccepted},
+		{
+			name:       "parameters",
+			ref:        parametersRef(group, gatewayParametersKind, "system", "params"),
+			wantStatus: metav1.ConditionTrue,
+			wantReason: apiv1.GatewayClassReasonAccepted,
+		},
+		{
+			name:       "another kind",
+			ref:        parametersRef("", "ConfigMap", "system", "params"),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: apiv1.GatewayClassReasonInvalidParameters,
+		},
+		{
+			name:       "no namespace",
+			ref:        parametersRef(group, gatewayParametersKind, "", "params"),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: apiv1.GatewayClassReasonInvalidParameters,
+		},
+		{
+			name:       "missing parameters",
+			ref:        parametersRef(group, gatewayParametersKind, "system", "missing"),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: apiv1.GatewayClassReasonInvalidParameters,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gwc := &apiv1.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "fgateway", Generation: 3},
+				Spec:       apiv1.GatewayClassSpec{ControllerName: "fgateway.dev/controller", ParametersRef: tt.ref},
+			}
+			cli := fake.NewClientBuilder().
+				WithScheme(DefaultScheme()).
+				WithObjects(gwc, gwp).
+				WithStatusSubresource(gwc).
+				Build()
+			r := &controllerReconciler{cli: cli, scheme: cli.Scheme()}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gwc)}
+			if _, err := r.ReconcileGatewayClass(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+
+			var got apiv1.GatewayClass
+			if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			accepted := meta.FindStatusCondition(got.Status.Conditions, string(apiv1.GatewayClassConditionStatusAccepted))
+			if accepted == nil || accepted.Status != tt.wantStatus || accepted.Reason != string(tt.wantReason) || accepted.ObservedGeneration != 3 {
+				t.Errorf("accepted condition = %+v, want %s with reason %s", accepted, tt.wantStatus, tt.wantReason)
+			}
+			if !slices.IsSortedFunc(got.Status.SupportedFeatures, func(a, b apiv1.SupportedFeature) int {
+				return cmp.Compare(a.Name, b.Name)
+			}) || len(got.Status.SupportedFeatures) != len(implementedFeatures) {
+				t.Errorf("supported features = %v, want the sorted implemented features", got.Status.SupportedFeatures)
+			}
+
+			// an up to date status is not patched again
+			resourceVersion := got.ResourceVersion
+			if _, err := r.ReconcileGatewayClass(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ResourceVersion != resourceVersion {
+				t.Errorf("status patched again without change")
+			}
+		})
+	}
+}
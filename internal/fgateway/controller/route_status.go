@@ -0,0 +1,535 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// routeInfo is the part of a route, whatever its kind, its status is computed from
+type routeInfo struct {
+	parentRefs  []apiv1.ParentReference
+	hostnames   []apiv1.Hostname
+	backendRefs []apiv1.BackendObjectReference
+	// filterTypes are the types of the filters of the rules of the route
+	filterTypes []apiv1.HTTPRouteFilterType
+	// extensionRefs are the references of the ExtensionRef filters of the rules of the route
+	extensionRefs []apiv1.LocalObjectReference
+	// routeNames are the names of the envoy routes translated from the matches of the rules of HTTP and gRPC routes
+	routeNames []string
+	// status points into the route object, so that it can be updated in place
+	status *apiv1.RouteStatus
+}
+
+// addFilter records a filter of a rule of the route. The backends of the mirror filters count as backendRefs.
+func (i *routeInfo) addFilter(t apiv1.HTTPRouteFilterType, mirror *apiv1.HTTPRequestMirrorFilter, extensionRef *apiv1.LocalObjectReference) {
+	i.filterTypes = append(i.filterTypes, t)
+	if mirror != nil {
+		i.backendRefs = append(i.backendRefs, mirror.BackendRef)
+	}
+	if extensionRef != nil {
+		i.extensionRefs = append(i.extensionRefs, *extensionRef)
+	}
+}
+
+// routeType describes a route kind to the route status reconciler and to the attached routes count of the Gateways
+type routeType struct {
+	kind      apiv1.RouteGroupKind
+	newObject func() client.Object
+	list      func(ctx context.Context, cli client.Client, opts ...client.ListOption) ([]client.Object, error)
+	info      func(obj client.Object) routeInfo
+	// experimental kinds are only served when their CRD is installed in the cluster
+	experimental bool
+	// backendProtocol is the protocol of the Service ports the backendRefs refer to, TCP when empty
+	backendProtocol corev1.Protocol
+}
+
+// routeTypes are the route kinds attaching to our Gateways
+var routeTypes = []routeType{
+	httpRouteType,
+	grpcRouteType,
+	tcpRouteType,
+	tlsRouteType,
+	udpRouteType,
+}
+
+var httpRouteType = routeType{
+	kind:      translator.HTTPRouteKind,
+	newObject: func() client.Object { return &apiv1.HTTPRoute{} },
+	list: func(ctx context.Context, cli client.Client, opts ...client.ListOption) ([]client.Object, error) {
+		var routes apiv1.HTTPRouteList
+		if err := cli.List(ctx, &routes, opts...); err != nil {
+			return nil, err
+		}
+		ret := make([]client.Object, 0, len(routes.Items))
+		for i := range routes.Items {
+			ret = append(ret, &routes.Items[i])
+		}
+		return ret, nil
+	},
+	info: func(obj client.Object) routeInfo {
+		route := obj.(*apiv1.HTTPRoute)
+		info := routeInfo{
+			parentRefs: route.Spec.ParentRefs,
+			hostnames:  route.Spec.Hostnames,
+			status:     &route.Status.RouteStatus,
+		}
+		for i, rule := range route.Spec.Rules {
+			for j := range max(len(rule.Matches), 1) {
+				info.routeNames = append(info.routeNames, translator.RouteResourceName(route.Namespace, route.Name, i, j))
+			}
+			for _, ref := range rule.BackendRefs {
+				info.backendRefs = append(info.backendRefs, ref.BackendObjectReference)
+			}
+			for _, f := range rule.Filters {
+				info.addFilter(f.Type, f.RequestMirror, f.ExtensionRef)
+			}
+		}
+		return info
+	},
+}
+
+var grpcRouteType = routeType{
+	kind:      translator.GRPCRouteKind,
+	newObject: func() client.Object { return &apiv1.GRPCRoute{} },
+	list: func(ctx context.Context, cli client.Client, opts ...client.ListOption) ([]client.Object, error) {
+		var routes apiv1.GRPCRouteList
+		if err := cli.List(ctx, &routes, opts...); err != nil {
+			return nil, err
+		}
+		ret := make([]client.Object, 0, len(routes.Items))
+		for i := range routes.Items {
+			ret = append(ret, &routes.Items[i])
+		}
+		return ret, nil
+	},
+	info: func(obj client.Object) routeInfo {
+		route := obj.(*apiv1.GRPCRoute)
+		info := routeInfo{
+			parentRefs: route.Spec.ParentRefs,
+			hostnames:  route.Spec.Hostnames,
+			status:     &route.Status.RouteStatus,
+		}
+		for i, rule := range route.Spec.Rules {
+			for j := range max(len(rule.Matches), 1) {
+				info.routeNames = append(info.routeNames, translator.RouteResourceName(route.Namespace, route.Name, i, j))
+			}
+			for _, ref := range rule.BackendRefs {
+				info.backendRefs = append(info.backendRefs, ref.BackendObjectReference)
+			}
+			for _, f := range rule.Filters {
+				info.addFilter(apiv1.HTTPRouteFilterType(f.Type), f.RequestMirror, f.ExtensionRef)
+			}
+		}
+		return info
+	},
+}
+
+var tcpRouteType = routeType{
+	kind:      translator.TCPRouteKind,
+	newObject: func() client.Object { return &apiv1alpha2.TCPRoute{} },
+	list: func(ctx context.Context, cli client.Client, opts ...client.ListOption) ([]client.Object, error) {
+		var routes apiv1alpha2.TCPRouteList
+		if err := cli.List(ctx, &routes, opts...); err != nil {
+			return nil, err
+		}
+		ret := make([]client.Object, 0, len(routes.Items))
+		for i := range routes.Items {
+			ret = append(ret, &routes.Items[i])
+		}
+		return ret, nil
+	},
+	info: func(obj client.Object) routeInfo {
+		route := obj.(*apiv1alpha2.TCPRoute)
+		var backendRefs []apiv1.BackendObjectReference
+		for _, rule := range route.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				backendRefs = append(backendRefs, ref.BackendObjectReference)
+			}
+		}
+		return routeInfo{
+			parentRefs:  route.Spec.ParentRefs,
+			backendRefs: backendRefs,
+			status:      &route.Status.RouteStatus,
+		}
+	},
+	experimental: true,
+}
+
+var tlsRouteType = routeType{
+	kind:      translator.TLSRouteKind,
+	newObject: func() client.Object { return &apiv1alpha2.TLSRoute{} },
+	list: func(ctx context.Context, cli client.Client, opts ...client.ListOption) ([]client.Object, error) {
+		var routes apiv1alpha2.TLSRouteList
+		if err := cli.List(ctx, &routes, opts...); err != nil {
+			return nil, err
+		}
+		ret := make([]client.Object, 0, len(routes.Items))
+		for i := range routes.Items {
+			ret = append(ret, &routes.Items[i])
+		}
+		return ret, nil
+	},
+	info: func(obj client.Object) routeInfo {
+		route := obj.(*apiv1alpha2.TLSRoute)
+		var backendRefs []apiv1.BackendObjectReference
+		for _, rule := range route.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				backendRefs = append(backendRefs, ref.BackendObjectReference)
+			}
+		}
+		return routeInfo{
+			parentRefs:  route.Spec.ParentRefs,
+			hostnames:   route.Spec.Hostnames,
+			backendRefs: backendRefs,
+			status:      &route.Status.RouteStatus,
+		}
+	},
+	experimental: true,
+}
+
+var udpRouteType = routeType{
+	kind:      translator.UDPRouteKind,
+	newObject: func() client.Object { return &apiv1alpha2.UDPRoute{} },
+	list: func(ctx context.Context, cli client.Client, opts ...client.ListOption) ([]client.Object, error) {
+		var routes apiv1alpha2.UDPRouteList
+		if err := cli.List(ctx, &routes, opts...); err != nil {
+			return nil, err
+		}
+		ret := make([]client.Object, 0, len(routes.Items))
+		for i := range routes.Items {
+			ret = append(ret, &routes.Items[i])
+		}
+		return ret, nil
+	},
+	info: func(obj client.Object) routeInfo {
+		route := obj.(*apiv1alpha2.UDPRoute)
+		var backendRefs []apiv1.BackendObjectReference
+		for _, rule := range route.Spec.Rules {
+			for _, ref := range rule.BackendRefs {
+				backendRefs = append(backendRefs, ref.BackendObjectReference)
+			}
+		}
+		return routeInfo{
+			parentRefs:  route.Spec.ParentRefs,
+			backendRefs: backendRefs,
+			status:      &route.Status.RouteStatus,
+		}
+	},
+	experimental:    true,
+	backendProtocol: corev1.ProtocolUDP,
+}
+
+// installedRouteTypes returns the route kinds the controllers can watch, leaving out the experimental kinds whose
+// CRD is not installed. Installing such a CRD takes a restart to be picked up.
+func installedRouteTypes(mgr manager.Manager) ([]routeType, error) {
+	var ret []routeType
+	for _, rt := range routeTypes {
+		if rt.experimental {
+			installed, err := crdInstalled(mgr, rt.newObject())
+			if err != nil {
+				return nil, err
+			}
+			if !installed {
+				continue
+			}
+		}
+		ret = append(ret, rt)
+	}
+	return ret, nil
+}
+
+// crdInstalled reports whether the CRD of an object is installed in the cluster
+func crdInstalled(mgr manager.Manager, obj client.Object) (bool, error) {
+	gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+	if err != nil {
+		return false, err
+	}
+	if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// routeStatusReconciler reports, for every parentRef of a route to one of our Gateways, whether the route is
+// accepted by the Gateway and whether its backendRefs resolve
+type routeStatusReconciler struct {
+	cli            client.Client
+	controllerName string
+	ourGateway     func(gw *apiv1.Gateway) bool
+	routeType      routeType
+	// nacks is the configuration rejected by the proxies, nil when it is not tracked
+	nacks *krtcollections.Nacks
+	// secrets are the Secrets of the certificateRefs of the listeners of the Gateways
+	secrets krt.Collection[*corev1.Secret]
+	// gateways is the IR of the Gateways, holding the proxy selectors they serve, nil when it is not known
+	gateways krt.Collection[ir.GatewayIR]
+}
+
+// routeReasonRejected is the reason of the accepted condition of the routes whose configuration the proxies of
+// the Gateway rejected
+const routeReasonRejected apiv1.RouteConditionReason = "Rejected"
+
+func (r *routeStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("route", req.NamespacedName, "kind", r.routeType.kind.Kind)
+	log.V(1).Info("reconciling route status")
+
+	obj := r.routeType.newObject()
+	if err := r.cli.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	original := obj.DeepCopyObject().(client.Object)
+	info := r.routeType.info(obj)
+
+	parents, err := r.parentStatuses(ctx, obj, info)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// keep the statuses written by other controllers, ours are all recomputed
+	merged := make([]apiv1.RouteParentStatus, 0, len(info.status.Parents)+len(parents))
+	for _, p := range info.status.Parents {
+		if string(p.ControllerName) != r.controllerName {
+			merged = append(merged, p)
+		}
+	}
+	info.status.Parents = append(merged, parents...)
+
+	if equality.Semantic.DeepEqual(r.routeType.info(original).status, info.status) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.cli.Status().Patch(ctx, obj, client.MergeFrom(original)); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// parentStatuses computes the status of the route for each of its parentRefs to one of our Gateways
+func (r *routeStatusReconciler) parentStatuses(ctx context.Context, obj client.Object, info routeInfo) ([]apiv1.RouteParentStatus, error) {
+	var nsLabels map[string]string
+	var ns corev1.Namespace
+	if err := r.cli.Get(ctx, client.ObjectKey{Name: obj.GetNamespace()}, &ns); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	} else {
+		nsLabels = ns.Labels
+	}
+
+	var resolved *metav1.Condition
+	var ret []apiv1.RouteParentStatus
+	for _, ref := range info.parentRefs {
+		if ptr.Deref(ref.Group, apiv1.GroupName) != apiv1.GroupName || ptr.Deref(ref.Kind, "Gateway") != "Gateway" {
+			continue
+		}
+		var gw apiv1.Gateway
+		key := client.ObjectKey{Namespace: string(ptr.Deref(ref.Namespace, apiv1.Namespace(obj.GetNamespace()))), Name: string(ref.Name)}
+		if err := r.cli.Get(ctx, key, &gw); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !r.ourGateway(&gw) {
+			continue
+		}
+
+		if resolved == nil {
+			cond, err := r.resolvedRefsCondition(ctx, obj, info)
+			if err != nil {
+				return nil, err
+			}
+			resolved = &cond
+		}
+		var getErr error
+		reports := translator.ValidateListeners(&gw, clientCertificateResolver(ctx, r.cli, r.secrets, &getErr))
+		if getErr != nil {
+			return nil, getErr
+		}
+		listeners, reason := translator.ParentRefListeners(&gw, reports, ref, r.routeType.kind, obj.GetNamespace(), nsLabels, info.hostnames)
+		accepted := metav1.Condition{
+			Type:               string(apiv1.RouteConditionAccepted),
+			Status:             metav1.ConditionTrue,
+			Reason:             string(reason),
+			Message:            "Route is accepted",
+			ObservedGeneration: obj.GetGeneration(),
+		}
+		if reason != apiv1.RouteReasonAccepted {
+			accepted.Status = metav1.ConditionFalse
+			accepted.Message = fmt.Sprintf("Route is not accepted by Gateway %s", key)
+		}
+		if reason == apiv1.RouteReasonAccepted {
+			if t := unsupportedFilter(info.filterTypes); t != "" {
+				accepted.Status = metav1.ConditionFalse
+				accepted.Reason = string(apiv1.RouteReasonUnsupportedValue)
+				accepted.Message = fmt.Sprintf("filter type %s is not supported", t)
+			}
+		}
+		if accepted.Status == metav1.ConditionTrue {
+			r.checkProxySelector(obj, &gw, &accepted)
+		}
+		if accepted.Status == metav1.ConditionTrue {
+			if rejected := r.rejected(obj, info, &gw, listeners); len(rejected) > 0 {
+				accepted.Status = metav1.ConditionFalse
+				accepted.Reason = string(routeReasonRejected)
+				accepted.Message = rejectedMessage(rejected)
+			}
+		}
+
+		// keep the transition times of the conditions that did not change
+		var conditions []metav1.Condition
+		for _, p := range info.status.Parents {
+			if string(p.ControllerName) == r.controllerName && equality.Semantic.DeepEqual(p.ParentRef, ref) {
+				conditions = append(conditions, p.Conditions...)
+				break
+			}
+		}
+		meta.SetStatusCondition(&conditions, accepted)
+		meta.SetStatusCondition(&conditions, *resolved)
+		ret = append(ret, apiv1.RouteParentStatus{
+			ParentRef:      ref,
+			ControllerName: apiv1.GatewayController(r.controllerName),
+			Conditions:     conditions,
+		})
+	}
+	return ret, nil
+}
+
+// checkProxySelector rejects the route in the accepted condition when its proxy selector makes it served by none of
+// the proxies of the Gateway. The selectors of the Gateway are the ones of all its routes, whatever their kind, as
+// translated in its IR: a Gateway not translated yet is checked again once it is.
+func (r *routeStatusReconciler) checkProxySelector(obj client.Object, gw *apiv1.Gateway, accepted *metav1.Condition) {
+	reject := func(err error) {
+		accepted.Status = metav1.ConditionFalse
+		accepted.Reason = string(apiv1.RouteReasonUnsupportedValue)
+		accepted.Message = err.Error()
+	}
+	selector, err := translator.ProxySelector(obj)
+	if err != nil {
+		reject(err)
+		return
+	}
+	if selector.Empty() || r.gateways == nil {
+		return
+	}
+	gwIR := r.gateways.GetKey(xds.GatewayProxyRole(gw.Namespace, gw.Name))
+	if gwIR == nil {
+		return
+	}
+	if err := translator.ProxySelectorError(obj, gwIR.ProxySelectors); err != nil {
+		reject(err)
+	}
+}
+
+// rejected returns the configuration the proxies of the Gateway rejected whose error names what the route produced:
+// its envoy routes, the virtual hosts serving it on the listeners it attaches to, and the clusters of its
+// backendRefs. A rejection naming none of them, such as one of a whole listener, is only reported on the Gateway, as
+// it is not told apart from the one of the sibling routes.
+func (r *routeStatusReconciler) rejected(obj client.Object, info routeInfo, gw *apiv1.Gateway, listeners []int) []krtcollections.Nack {
+	if r.nacks == nil {
+		return nil
+	}
+	nacks := r.nacks.Get(xds.GatewayProxyRole(gw.Namespace, gw.Name))
+	if len(nacks) == 0 {
+		return nil
+	}
+	resources := sets.New(info.routeNames...)
+	if len(info.routeNames) > 0 {
+		for _, i := range listeners {
+			resources.Insert(translator.VirtualHostNames(gw.Spec.Listeners[i], info.hostnames)...)
+		}
+	}
+	for _, ref := range info.backendRefs {
+		if ptr.Deref(ref.Group, "") != "" || ptr.Deref(ref.Kind, "Service") != "Service" || ref.Port == nil {
+			continue
+		}
+		namespace := string(ptr.Deref(ref.Namespace, apiv1.Namespace(obj.GetNamespace())))
+		resources.Insert(translator.ClusterName(namespace, string(ref.Name), int32(*ref.Port), cmp.Or(r.routeType.backendProtocol, corev1.ProtocolTCP)))
+	}
+	return slices.DeleteFunc(nacks, func(nack krtcollections.Nack) bool {
+		return !slices.ContainsFunc(sets.List(resources), nack.Mentions)
+	})
+}
+
+// unsupportedFilter returns the first filter type the translator does not apply, other than ExtensionRef whose
+// references are reported in the resolved refs condition, or an empty string
+func unsupportedFilter(types []apiv1.HTTPRouteFilterType) apiv1.HTTPRouteFilterType {
+	for _, t := range types {
+		if t != apiv1.HTTPRouteFilterExtensionRef && !translator.FilterSupported(t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// resolvedRefsCondition reports the first backendRef of the route that can not be resolved, if any, then the first
+// ExtensionRef filter, as they refer to no supported kind
+func (r *routeStatusReconciler) resolvedRefsCondition(ctx context.Context, obj client.Object, info routeInfo) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               string(apiv1.RouteConditionResolvedRefs),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(apiv1.RouteReasonResolvedRefs),
+		Message:            "All backend references are resolved",
+		ObservedGeneration: obj.GetGeneration(),
+	}
+
+	var getErr error
+	resolver := translator.BackendResolver{
+		GetService: func(namespace, name string) *corev1.Service {
+			var svc corev1.Service
+			if err := r.cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &svc); err != nil {
+				if !apierrors.IsNotFound(err) && getErr == nil {
+					getErr = err
+				}
+				return nil
+			}
+			return &svc
+		},
+		Grants: clientReferenceGrants(ctx, r.cli, &getErr),
+	}
+	for _, ref := range info.backendRefs {
+		_, err := resolver.ResolveProtocol(ref, r.routeType.kind, obj.GetNamespace(), cmp.Or(r.routeType.backendProtocol, corev1.ProtocolTCP))
+		if getErr != nil {
+			return cond, getErr
+		}
+		var refErr *translator.BackendRefError
+		if errors.As(err, &refErr) {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = string(refErr.Reason)
+			cond.Message = refErr.Message
+			return cond, nil
+		}
+	}
+	if len(info.extensionRefs) > 0 {
+		ref := info.extensionRefs[0]
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = string(apiv1.RouteReasonInvalidKind)
+		cond.Message = fmt.Sprintf("ExtensionRef filter to %s %s in group %q can not be resolved, no extension kind is supported",
+			ref.Kind, ref.Name, ref.Group)
+	}
+	return cond, nil
+}
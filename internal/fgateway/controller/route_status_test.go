@@ -0,0 +1,405 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"github.com/samber/lo"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"istio.io/istio/pkg/kube/krt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestRouteStatusProxySelectors(t *testing.T) {
+	gw := &apiv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"},
+		Spec: apiv1.GatewaySpec{
+			GatewayClassName: "fgateway",
+			Listeners:        []apiv1.Listener{{Name: "http", Port: 8080, Protocol: apiv1.HTTPProtocolType}},
+		},
+	}
+	route := func(name, selector string) *apiv1.HTTPRoute {
+		r := &apiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: apiv1.HTTPRouteSpec{
+				CommonRouteSpec: apiv1.CommonRouteSpec{ParentRefs: []apiv1.ParentReference{{Name: "gw"}}},
+			},
+		}
+		if selector != "" {
+			r.Annotations = map[string]string{wellknown.ProxySelectorAnnotation: selector}
+		}
+		return r
+	}
+	objs := []client.Object{gw, route("all", ""), route("invalid", "track in canary")}
+	for i := range 5 {
+		objs = append(objs, route(fmt.Sprintf("track-%d", i), fmt.Sprintf("track=%d", i)))
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(DefaultScheme()).
+		WithObjects(objs...).
+		WithStatusSubresource(&apiv1.HTTPRoute{}).
+		Build()
+	// the Gateway serves the selectors of its 4 oldest routes, leaving the last one out
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	role := xds.GatewayProxyRole("default", "gw")
+	gateways := krt.NewStaticCollection([]ir.GatewayIR{
+		{Role: role, ProxySelectors: []string{"track=0", "track=1", "track=2", "track=3"}},
+	}, krt.WithStop(stop))
+	r := &routeStatusReconciler{
+		cli:            cli,
+		controllerName: "fgateway.dev/controller",
+		ourGateway:     func(*apiv1.Gateway) bool { return true },
+		routeType:      httpRouteType,
+		gateways:       gateways,
+	}
+
+	tests := []struct {
+		route      string
+		wantStatus metav1.ConditionStatus
+		wantReason apiv1.RouteConditionReason
+		// wantMessage is a part of the message of the condition
+		wantMessage string
+	}{
+		{route: "all", wantStatus: metav1.ConditionTrue, wantReason: apiv1.RouteReasonAccepted},
+		{route: "track-0", wantStatus: metav1.ConditionTrue, wantReason: apiv1.RouteReasonAccepted},
+		{route: "track-3", wantStatus: metav1.ConditionTrue, wantReason: apiv1.RouteReasonAccepted},
+		{
+			route:       "track-4",
+			wantStatus:  metav1.ConditionFalse,
+			wantReason:  apiv1.RouteReasonUnsupportedValue,
+			wantMessage: "at most 4 distinct proxy selectors",
+		},
+		{route: "invalid", wantStatus: metav1.ConditionFalse, wantReason: apiv1.RouteReasonUnsupportedValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: tt.route}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			var got apiv1.HTTPRoute
+			if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Status.Parents) != 1 {
+				t.Fatalf("parents = %v, want the status of the gateway", got.Status.Parents)
+			}
+			cond := meta.FindStatusCondition(got.Status.Parents[0].Conditions, string(apiv1.RouteConditionAccepted))
+			if cond == nil || cond.Status != tt.wantStatus || cond.Reason != string(tt.wantReason) {
+				t.Fatalf("accepted condition = %v, want %s with reason %s", cond, tt.wantStatus, tt.wantReason)
+			}
+			if !strings.Contains(cond.Message, tt.wantMessage) {
+				t.Errorf("accepted message = %q, want it to contain %q", cond.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestProxySelectorsSource(t *testing.T) {
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	role := xds.GatewayProxyRole("default", "gw")
+	gateways := krt.NewStaticCollection([]ir.GatewayIR{{Role: role, ProxySelectors: []string{"track=a"}}}, krt.WithStop(stop))
+	// the events are delivered in order, the Gateway being mapped only once its selectors change
+	var mu sync.Mutex
+	var mapped []types.NamespacedName
+	src := proxySelectorsSource(gateways, func(_ context.Context, gw types.NamespacedName) []reconcile.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		mapped = append(mapped, gw)
+		return []reconcile.Request{{NamespacedName: gw}}
+	})
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	if err := src.Start(context.Background(), queue); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := queue.Get()
+	queue.Done(req)
+
+	// a change of the configuration keeping the selectors, or of another variant, enqueues nothing
+	gateways.UpdateObject(ir.GatewayIR{Role: role, ProxySelectors: []string{"track=a"}, Clusters: []*envoy_config_cluster_v3.Cluster{{Name: "web"}}})
+	gateways.UpdateObject(ir.GatewayIR{Role: role, ProxySelectors: []string{"track=a", "track=b"}, Variant: 1})
+	gateways.UpdateObject(ir.GatewayIR{Role: role, ProxySelectors: []string{"track=a", "track=b"}})
+	queue.Get()
+	mu.Lock()
+	defer mu.Unlock()
+	gw := types.NamespacedName{Namespace: "default", Name: "gw"}
+	if want := []types.NamespacedName{gw, gw}; !slices.Equal(mapped, want) {
+		t.Errorf("mapped %v, want the gateway when it is added and when its selectors change", mapped)
+	}
+}
+
+func TestNacksSource(t *testing.T) {
+	nacks := krtcollections.NewNacks()
+	callbacks, _, _ := krtcollections.NewUniquelyConnectedClients(nacks)
+	// the mapping of a Gateway waits for the test to receive it
+	calls := make(chan types.NamespacedName)
+	src := nacksSource(nacks, func(_ context.Context, gw types.NamespacedName) []reconcile.Request {
+		calls <- gw
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	if err := src.Start(ctx, queue); err != nil {
+		t.Fatal(err)
+	}
+
+	const clusterType = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	node := &envoy_config_core_v3.Node{
+		Id: "gw-a.default",
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+			xds.RoleKey: structpb.NewStringValue(xds.GatewayProxyRole("default", "gw")),
+		}},
+	}
+	nack := func(nonce string, rejected bool) {
+		req := &envoy_service_discovery_v3.DeltaDiscoveryRequest{Node: node, TypeUrl: clusterType, ResponseNonce: nonce}
+		if rejected {
+			req.ErrorDetail = &status.Status{Message: "invalid"}
+		}
+		_ = callbacks.OnStreamDeltaRequest(1, req)
+	}
+	gw := types.NamespacedName{Namespace: "default", Name: "gw"}
+	nack("1", true)
+	if got := <-calls; got != gw {
+		t.Fatalf("mapped %v, want %v", got, gw)
+	}
+
+	// the proxies do not wait for the Gateway being mapped, whose changes meanwhile are mapped once
+	for i := range 10 {
+		nack(fmt.Sprint(i+2), i%2 == 1)
+	}
+	if got := <-calls; got != gw {
+		t.Fatalf("mapped %v, want %v", got, gw)
+	}
+	select {
+	case got := <-calls:
+		t.Errorf("mapped %v again, want the pending changes mapped once", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRouteStatusRejected(t *testing.T) {
+	gw := &apiv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"},
+		Spec: apiv1.GatewaySpec{
+			GatewayClassName: "fgateway",
+			Listeners:        []apiv1.Listener{{Name: "http", Port: 8080, Protocol: apiv1.HTTPProtocolType}},
+		},
+	}
+	route := func(name, backend string) *apiv1.HTTPRoute {
+		return &apiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: apiv1.HTTPRouteSpec{
+				CommonRouteSpec: apiv1.CommonRouteSpec{ParentRefs: []apiv1.ParentReference{{Name: "gw"}}},
+				Rules: []apiv1.HTTPRouteRule{{BackendRefs: []apiv1.HTTPBackendRef{{BackendRef: apiv1.BackendRef{
+					BackendObjectReference: apiv1.BackendObjectReference{Name: apiv1.ObjectName(backend), Port: ptr.To[apiv1.PortNumber](80)},
+				}}}}},
+			},
+		}
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(DefaultScheme()).
+		WithObjects(gw, route("web", "web"), route("other", "other")).
+		WithStatusSubresource(&apiv1.HTTPRoute{}).
+		Build()
+
+	nacks := krtcollections.NewNacks()
+	callbacks, _, _ := krtcollections.NewUniquelyConnectedClients(nacks)
+	const (
+		clusterType = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+		routeType   = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+	)
+	node := &envoy_config_core_v3.Node{
+		Id: "gw-a.default",
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+			xds.RoleKey: structpb.NewStringValue(xds.GatewayProxyRole("default", "gw")),
+		}},
+	}
+	// the clients are not tracked without their collection, the rejections are
+	_ = callbacks.OnStreamDeltaRequest(1, &envoy_service_discovery_v3.DeltaDiscoveryRequest{Node: node, TypeUrl: clusterType})
+	_ = callbacks.OnStreamDeltaRequest(1, &envoy_service_discovery_v3.DeltaDiscoveryRequest{TypeUrl: routeType})
+
+	r := &routeStatusReconciler{
+		cli:            cli,
+		controllerName: "fgateway.dev/controller",
+		ourGateway:     func(*apiv1.Gateway) bool { return true },
+		routeType:      httpRouteType,
+		nacks:          nacks,
+	}
+	for _, tt := range []struct {
+		name     string
+		typeURL  string
+		sent     []string
+		message  string
+		rejected []string
+	}{
+		{
+			name:     "cluster of a backend",
+			typeURL:  clusterType,
+			sent:     []string{"kube_default_other_80", "kube_default_web_80"},
+			message:  "cluster kube_default_web_80: invalid transport socket",
+			rejected: []string{"web"},
+		},
+		{
+			name:    "cluster error naming no cluster",
+			typeURL: clusterType,
+			sent:    []string{"kube_default_other_80", "kube_default_web_80"},
+			message: "invalid transport socket",
+		},
+		{
+			name:     "envoy route of a rule",
+			typeURL:  routeType,
+			sent:     []string{"listener~8080"},
+			message:  "route default/other~0~0: invalid regex",
+			rejected: []string{"other"},
+		},
+		{
+			name:     "virtual host shared by the routes",
+			typeURL:  routeType,
+			sent:     []string{"listener~8080"},
+			message:  "virtual host listener~8080~wildcard: duplicate route names",
+			rejected: []string{"other", "web"},
+		},
+		// the Gateway reports the rejection of the route configuration of the listener
+		{name: "whole listener", typeURL: routeType, sent: []string{"listener~8080"}, message: "listener~8080: invalid"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := lo.Map(tt.sent, func(name string, _ int) *envoy_service_discovery_v3.Resource {
+				return &envoy_service_discovery_v3.Resource{Name: name}
+			})
+			callbacks.OnStreamDeltaResponse(1, nil, &envoy_service_discovery_v3.DeltaDiscoveryResponse{TypeUrl: tt.typeURL, Nonce: tt.name, Resources: resources})
+			_ = callbacks.OnStreamDeltaRequest(1, &envoy_service_discovery_v3.DeltaDiscoveryRequest{
+				TypeUrl:       tt.typeURL,
+				ResponseNonce: tt.name,
+				ErrorDetail:   &status.Status{Message: tt.message},
+			})
+			// the proxy accepts the configuration of the type once the route status is checked
+			defer func() {
+				_ = callbacks.OnStreamDeltaRequest(1, &envoy_service_discovery_v3.DeltaDiscoveryRequest{TypeUrl: tt.typeURL, ResponseNonce: tt.name + "-accepted"})
+			}()
+
+			for _, name := range []string{"other", "web"} {
+				req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: name}}
+				if _, err := r.Reconcile(context.Background(), req); err != nil {
+					t.Fatal(err)
+				}
+				var got apiv1.HTTPRoute
+				if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+					t.Fatal(err)
+				}
+				if len(got.Status.Parents) != 1 {
+					t.Fatalf("parents = %v, want the status of the gateway", got.Status.Parents)
+				}
+				wantStatus, wantReason := metav1.ConditionTrue, apiv1.RouteReasonAccepted
+				if slices.Contains(tt.rejected, name) {
+					wantStatus, wantReason = metav1.ConditionFalse, routeReasonRejected
+				}
+				cond := meta.FindStatusCondition(got.Status.Parents[0].Conditions, string(apiv1.RouteConditionAccepted))
+				if cond == nil || cond.Status != wantStatus || cond.Reason != string(wantReason) {
+					t.Errorf("accepted condition of %s = %v, want %s with reason %s", name, cond, wantStatus, wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteStatusFilters(t *testing.T) {
+	gw := &apiv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"},
+		Spec: apiv1.GatewaySpec{
+			GatewayClassName: "fgateway",
+			Listeners:        []apiv1.Listener{{Name: "http", Port: 8080, Protocol: apiv1.HTTPProtocolType}},
+		},
+	}
+	route := func(name string, filter apiv1.HTTPRouteFilter) *apiv1.HTTPRoute {
+		return &apiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: apiv1.HTTPRouteSpec{
+				CommonRouteSpec: apiv1.CommonRouteSpec{ParentRefs: []apiv1.ParentReference{{Name: "gw"}}},
+				Rules:           []apiv1.HTTPRouteRule{{Filters: []apiv1.HTTPRouteFilter{filter}}},
+			},
+		}
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(DefaultScheme()).
+		WithObjects(gw,
+			route("headers", apiv1.HTTPRouteFilter{
+				Type:                  apiv1.HTTPRouteFilterRequestHeaderModifier,
+				RequestHeaderModifier: &apiv1.HTTPHeaderFilter{Remove: []string{"x-debug"}},
+			}),
+			route("cors", apiv1.HTTPRouteFilter{Type: "CORS"}),
+			route("extension", apiv1.HTTPRouteFilter{
+				Type:         apiv1.HTTPRouteFilterExtensionRef,
+				ExtensionRef: &apiv1.LocalObjectReference{Group: "example.com", Kind: "Auth", Name: "auth"},
+			}),
+		).
+		WithStatusSubresource(&apiv1.HTTPRoute{}).
+		Build()
+	r := &routeStatusReconciler{
+		cli:            cli,
+		controllerName: "fgateway.dev/controller",
+		ourGateway:     func(*apiv1.Gateway) bool { return true },
+		routeType:      httpRouteType,
+	}
+
+	tests := []struct {
+		route        string
+		wantAccepted metav1.ConditionStatus
+		wantReason   apiv1.RouteConditionReason
+		wantResolved metav1.ConditionStatus
+	}{
+		{route: "headers", wantAccepted: metav1.ConditionTrue, wantReason: apiv1.RouteReasonAccepted, wantResolved: metav1.ConditionTrue},
+		{route: "cors", wantAccepted: metav1.ConditionFalse, wantReason: apiv1.RouteReasonUnsupportedValue, wantResolved: metav1.ConditionTrue},
+		{route: "extension", wantAccepted: metav1.ConditionTrue, wantReason: apiv1.RouteReasonAccepted, wantResolved: metav1.ConditionFalse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: tt.route}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			var got apiv1.HTTPRoute
+			if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Status.Parents) != 1 {
+				t.Fatalf("parents = %v, want the status of the gateway", got.Status.Parents)
+			}
+			conds := got.Status.Parents[0].Conditions
+			accepted := meta.FindStatusCondition(conds, string(apiv1.RouteConditionAccepted))
+			if accepted == nil || accepted.Status != tt.wantAccepted || accepted.Reason != string(tt.wantReason) {
+				t.Errorf("accepted condition = %v, want %s with reason %s", accepted, tt.wantAccepted, tt.wantReason)
+			}
+			resolved := meta.FindStatusCondition(conds, string(apiv1.RouteConditionResolvedRefs))
+			if resolved == nil || resolved.Status != tt.wantResolved {
+				t.Errorf("resolved refs condition = %v, want %s", resolved, tt.wantResolved)
+			}
+		})
+	}
+}
@@ -1,9 +1,31 @@
 package controller
 
-import "k8s.io/apimachinery/pkg/runtime"
+import (
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
 
 // SchemeBuilder contains all the Schemes for registering the CRDs with which fgateway interacts.
-var SchemeBuilder = runtime.SchemeBuilder{}
+var SchemeBuilder = runtime.SchemeBuilder{
+	// k8s gateway api resources
+	apiv1.AddToScheme,
+	apiv1beta1.AddToScheme,
+	apiv1alpha2.AddToScheme,
+	apiv1alpha3.AddToScheme,
+
+	// k8s core resources
+	corev1.AddToScheme,
+	appsv1.AddToScheme,
+
+	// fgateway resources
+	v1alpha1.AddToScheme,
+}
 
 // DefaultScheme returns a scheme with all the types registered for fgateway
 func DefaultScheme() *runtime.Scheme {
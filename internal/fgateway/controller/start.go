@@ -2,25 +2,31 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/fleezesd/fgateway/internal/fgateway/deployer"
 	"github.com/fleezesd/fgateway/internal/fgateway/extension/settings"
 	"github.com/fleezesd/fgateway/internal/fgateway/ir"
 	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/proxysyncer"
 	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/logutil"
 	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
 	"github.com/solo-io/go-utils/contextutils"
 	"go.uber.org/zap"
 	istiokube "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/krt"
-	istiolog "istio.io/istio/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
@@ -34,15 +40,23 @@ const (
 var setupLog = ctrl.Log.WithName("setup")
 
 type StartOptions struct {
-	Cache       envoycache.Cache
+	Cache       envoycache.SnapshotCache
 	KrtDebugger *krt.DebugHandler
+	// Nacks is the configuration the proxies rejected, recorded by the xds callbacks
+	Nacks *krtcollections.Nacks
+	// Logging holds the log level of every component
+	Logging *logutil.Registry
 
 	XdsHost string
 	XdsPort int32
+
+	// ExtraGatewayClasses are served in addition to the GatewayClasses with our controllerName
+	ExtraGatewayClasses []string
 }
 
 type StartConfig struct {
 	Dev        bool
+	Settings   settings.Settings
 	StartOpts  *StartOptions
 	RestConfig *rest.Config
 	Client     istiokube.Client
@@ -58,25 +72,16 @@ type StartConfig struct {
 }
 
 type ControllerBuilder struct {
-	// todo: proxy Syncer
+	proxySyncer  *proxysyncer.ProxySyncer
 	cfg          StartConfig
 	mgr          ctrl.Manager
 	isOurGateway func(gw *apiv1.Gateway) bool
-	settings     settings.Settings
+	// isOurGatewayClass reports whether the Gateways of a GatewayClass are ours
+	isOurGatewayClass func(gwc *apiv1.GatewayClass) bool
+	settings          settings.Settings
 }
 
 func NewControllerBuilder(ctx context.Context, cfg StartConfig) (*ControllerBuilder, error) {
-	// setup log
-	var opts []ctrlzap.Opts
-	loggingOptions := istiolog.DefaultOptions()
-	if cfg.Dev {
-		setupLog.Info("starting log in dev mode")
-		opts = append(opts, ctrlzap.UseDevMode(true))
-		loggingOptions.SetDefaultOutputLevel(istiolog.OverrideScopeName, istiolog.DebugLevel)
-	}
-	ctrl.SetLogger(ctrlzap.New(opts...))
-	istiolog.Configure(loggingOptions)
-
 	// setup scheme
 	scheme := DefaultScheme()
 
@@ -104,15 +109,68 @@ func NewControllerBuilder(ctx context.Context, cfg StartConfig) (*ControllerBuil
 
 	mgr.AddHealthzCheck("ping-ready", healthz.Ping)
 
-	// todo: add extentions & proxy syncer
+	// todo: add extentions
+
+	classNames := append([]string{wellknown.GatewayClassName}, cfg.StartOpts.ExtraGatewayClasses...)
+	proxySyncer := proxysyncer.NewProxySyncer(
+		wellknown.GatewayControllerName,
+		classNames,
+		cfg.StartOpts.Cache,
+		cfg.Client,
+		cfg.UniqueClients,
+		cfg.AugmentedPods,
+		cfg.KrtOptions,
+	)
+	proxySyncer.Init(ctx)
+	if err := mgr.Add(proxySyncer); err != nil {
+		setupLog.Error(err, "unable to add proxy syncer runnable")
+		return nil, err
+	}
 
 	setupLog.Info("starting controoller builder")
+	isOurGateway, isOurGatewayClass := gatewayClassMatcher(ctx, mgr.GetClient(), wellknown.GatewayControllerName, classNames)
 	return &ControllerBuilder{
-		cfg: cfg,
-		mgr: mgr,
+		proxySyncer:       proxySyncer,
+		cfg:               cfg,
+		mgr:               mgr,
+		settings:          cfg.Settings,
+		isOurGateway:      isOurGateway,
+		isOurGatewayClass: isOurGatewayClass,
 	}, nil
 }
 
+// gatewayClassMatcher returns funcs reporting whether a Gateway and a GatewayClass are handled by this controller:
+// the GatewayClass either has our controllerName, or is one of the configured class names. The GatewayClass of a
+// Gateway is read from the manager's cache. A Gateway whose GatewayClass can not be read is not ours until the
+// GatewayClass watch sees the class and enqueues its Gateways again, so errors other than not found are logged.
+func gatewayClassMatcher(
+	ctx context.Context,
+	cli client.Reader,
+	controllerName string,
+	classNames []string,
+) (func(gw *apiv1.Gateway) bool, func(gwc *apiv1.GatewayClass) bool) {
+	names := sets.New(classNames...)
+	isOurGatewayClass := func(gwc *apiv1.GatewayClass) bool {
+		return names.Has(gwc.Name) || string(gwc.Spec.ControllerName) == controllerName
+	}
+	isOurGateway := func(gw *apiv1.Gateway) bool {
+		className := string(gw.Spec.GatewayClassName)
+		if names.Has(className) {
+			return true
+		}
+		var gwc apiv1.GatewayClass
+		if err := cli.Get(ctx, client.ObjectKey{Name: className}, &gwc); err != nil {
+			if !apierrors.IsNotFound(err) {
+				ctrl.LoggerFrom(ctx).Error(err, "could not get the GatewayClass of Gateway",
+					"gateway", client.ObjectKeyFromObject(gw), "gatewayClass", className)
+			}
+			return false
+		}
+		return isOurGatewayClass(&gwc)
+	}
+	return isOurGateway, isOurGatewayClass
+}
+
 // Start starts the controller.
 func (c *ControllerBuilder) Start(ctx context.Context) error {
 	logger := contextutils.LoggerFrom(ctx).Desugar()
@@ -127,16 +185,29 @@ func (c *ControllerBuilder) Start(ctx context.Context) error {
 
 	// todo: fix extend plugin & aws info
 
+	conflictPolicies, err := conflictPoliciesFromSettings(c.settings)
+	if err != nil {
+		return err
+	}
+
 	gwCfg := GatewayConfig{
-		Mgr:            c.mgr,
-		OurGateway:     c.isOurGateway,
-		ControllerName: wellknown.GatewayControllerName,
+		Mgr:             c.mgr,
+		OurGateway:      c.isOurGateway,
+		OurGatewayClass: c.isOurGatewayClass,
+		ControllerName:  wellknown.GatewayControllerName,
 		// controller will be responsible for provisioning dynamic infrastructure for the Gateway API.
-		AutoProvision: AutoProvision,
+		AutoProvision:          AutoProvision,
+		EnableIstioIntegration: c.settings.EnableIstioIntegration,
 		ControlPlane: &deployer.ControlPlaneInfo{
 			XdsHost: xdsHost,
 			XdsPort: xdsPort,
 		},
+		ConflictPolicies: conflictPolicies,
+		Nacks:            c.cfg.StartOpts.Nacks,
+		Logging:          c.cfg.StartOpts.Logging,
+		Secrets:          c.proxySyncer.Secrets(),
+		ConfigMaps:       c.proxySyncer.ConfigMaps(),
+		Gateways:         c.proxySyncer.Gateways(),
 	}
 	if err := NewBaseGatewayController(ctx, gwCfg); err != nil {
 		setupLog.Error(err, "unable to create controller")
@@ -144,3 +215,16 @@ func (c *ControllerBuilder) Start(ctx context.Context) error {
 	}
 	return c.mgr.Start(ctx)
 }
+
+// conflictPoliciesFromSettings builds the deployer's per-kind conflict policies from the configured back off kinds
+func conflictPoliciesFromSettings(st settings.Settings) (map[schema.GroupKind]deployer.ConflictPolicy, error) {
+	policies := make(map[schema.GroupKind]deployer.ConflictPolicy, len(st.ConflictBackOffKinds))
+	for _, kind := range st.ConflictBackOffKinds {
+		gk := schema.ParseGroupKind(strings.TrimSpace(kind))
+		if gk.Kind == "" {
+			return nil, fmt.Errorf("invalid conflict back off kind %q, expected Kind.group", kind)
+		}
+		policies[gk] = deployer.ConflictPolicyBackOff
+	}
+	return policies, nil
+}
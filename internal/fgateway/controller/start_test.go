@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/deployer"
+	"github.com/fleezesd/fgateway/internal/fgateway/extension/settings"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	apiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestGatewayClassMatcher(t *testing.T) {
+	classes := []client.Object{
+		&apiv1.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "ours"},
+			Spec:       apiv1.GatewayClassSpec{ControllerName: "fgateway.dev/controller"},
+		},
+		&apiv1.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "theirs"},
+			Spec:       apiv1.GatewayClassSpec{ControllerName: "example.com/controller"},
+		},
+	}
+	tests := []struct {
+		name      string
+		className string
+		getErr    error
+		want      bool
+	}{
+		{name: "class with our controllerName", className: "ours", want: true},
+		{name: "class of another controller", className: "theirs"},
+		{name: "extra class name of another controller", className: "extra", want: true},
+		{name: "missing class", className: "missing"},
+		{name: "extra class name is not read", className: "extra", getErr: errors.New("cache not synced"), want: true},
+		{name: "error reading the class", className: "ours", getErr: errors.New("cache not synced")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(DefaultScheme()).WithObjects(classes...)
+			if tt.getErr != nil {
+				builder = builder.WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+						return tt.getErr
+					},
+				})
+			}
+			isOurGateway, _ := gatewayClassMatcher(context.Background(), builder.Build(), "fgateway.dev/controller", []string{"extra"})
+			gw := &apiv1.Gateway{Spec: apiv1.GatewaySpec{GatewayClassName: apiv1.ObjectName(tt.className)}}
+			if got := isOurGateway(gw); got != tt.want {
+				t.Errorf("isOurGateway() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatewayClassMatcherClasses(t *testing.T) {
+	_, isOurGatewayClass := gatewayClassMatcher(context.Background(), fake.NewClientBuilder().Build(), "fgateway.dev/controller", []string{"extra"})
+	tests := []struct {
+		name string
+		gwc  *apiv1.GatewayClass
+		want bool
+	}{
+		{
+			name: "our controllerName",
+			gwc:  &apiv1.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "any"}, Spec: apiv1.GatewayClassSpec{ControllerName: "fgateway.dev/controller"}},
+			want: true,
+		},
+		{
+			name: "extra class name",
+			gwc:  &apiv1.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "extra"}, Spec: apiv1.GatewayClassSpec{ControllerName: "example.com/controller"}},
+			want: true,
+		},
+		{
+			name: "another controller",
+			gwc:  &apiv1.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "theirs"}, Spec: apiv1.GatewayClassSpec{ControllerName: "example.com/controller"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOurGatewayClass(tt.gwc); got != tt.want {
+				t.Errorf("isOurGatewayClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatewayClassWatch(t *testing.T) {
+	ref := &apiv1.ParametersReference{
+		Group:     apiv1.Group(v1alpha1.GroupVersion.Group),
+		Kind:      gatewayParametersKind,
+		Name:      "params",
+		Namespace: ptr.To[apiv1.Namespace]("system"),
+	}
+	gwc := func(name, controllerName string) *apiv1.GatewayClass {
+		return &apiv1.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       apiv1.GatewayClassSpec{ControllerName: apiv1.GatewayController(controllerName), ParametersRef: ref},
+		}
+	}
+	gwp := &v1alpha1.GatewayParameters{ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "params"}}
+	cli := fake.NewClientBuilder().
+		WithScheme(DefaultScheme()).
+		WithObjects(gwp, gwc("ours", "fgateway.dev/controller"), gwc("extra", "example.com/controller"), gwc("theirs", "example.com/controller")).
+		WithStatusSubresource(&apiv1.GatewayClass{}).
+		Build()
+	_, isOurGatewayClass := gatewayClassMatcher(context.Background(), cli, "fgateway.dev/controller", []string{"extra"})
+	c := &controllerBuilder{
+		cfg:        GatewayConfig{OurGatewayClass: isOurGatewayClass},
+		reconciler: &controllerReconciler{cli: cli, scheme: cli.Scheme()},
+	}
+
+	for _, tt := range []struct {
+		gwc  *apiv1.GatewayClass
+		want bool
+	}{
+		{gwc: gwc("ours", "fgateway.dev/controller"), want: true},
+		{gwc: gwc("extra", "example.com/controller"), want: true},
+		{gwc: gwc("theirs", "example.com/controller")},
+	} {
+		if got := c.isOurGatewayClass(tt.gwc); got != tt.want {
+			t.Errorf("isOurGatewayClass(%s) = %v, want %v", tt.gwc.Name, got, tt.want)
+		}
+	}
+	var reqs []string
+	for _, req := range c.gatewayClassesOfParameters(context.Background(), gwp) {
+		reqs = append(reqs, req.Name)
+	}
+	slices.Sort(reqs)
+	if want := []string{"extra", "ours"}; !slices.Equal(reqs, want) {
+		t.Errorf("requests for the parameters = %v, want %v", reqs, want)
+	}
+
+	// the extra class is reconciled like the ones of our controllerName
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Name: "extra"}}
+	if _, err := c.reconciler.ReconcileGatewayClass(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	var got apiv1.GatewayClass
+	if err := cli.Get(context.Background(), req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	if cond := meta.FindStatusCondition(got.Status.Conditions, string(apiv1.GatewayClassConditionStatusAccepted)); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("accepted condition = %v, want true", cond)
+	}
+	if len(got.Status.SupportedFeatures) == 0 {
+		t.Error("supported features are not reported")
+	}
+}
+
+func TestConflictPoliciesFromSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		kinds   []string
+		want    map[schema.GroupKind]deployer.ConflictPolicy
+		wantErr bool
+	}{
+		{name: "none", want: map[schema.GroupKind]deployer.ConflictPolicy{}},
+		{
+			name:  "core and grouped kinds",
+			kinds: []string{"Service", " Deployment.apps "},
+			want: map[schema.GroupKind]deployer.ConflictPolicy{
+				{Kind: "Service"}:                   deployer.ConflictPolicyBackOff,
+				{Group: "apps", Kind: "Deployment"}: deployer.ConflictPolicyBackOff,
+			},
+		},
+		{name: "no kind", kinds: []string{".apps"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conflictPoliciesFromSettings(settings.Settings{ConflictBackOffKinds: tt.kinds})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("conflictPoliciesFromSettings() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("conflictPoliciesFromSettings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
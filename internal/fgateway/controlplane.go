@@ -9,7 +9,7 @@ import (
 	envoy_service_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
 	envoy_service_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
 	envoy_service_route_v3 "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
-	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	envoy_service_secret_v3 "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
 	xdsserver "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/fleezesd/fgateway/pkg/xds"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -24,7 +24,7 @@ func NewControlPlane(
 	ctx context.Context,
 	bindAddr net.Addr,
 	callbacks xdsserver.Callbacks,
-) (envoycache.SnapshotCache, error) {
+) (*xds.ClientCache, error) {
 	lis, err := net.Listen(bindAddr.Network(), bindAddr.String())
 	if err != nil {
 		return nil, err
@@ -36,7 +36,7 @@ func NewControlPlaneWithListener(
 	ctx context.Context,
 	lis net.Listener,
 	callbacks xdsserver.Callbacks,
-) (envoycache.SnapshotCache, error) {
+) (*xds.ClientCache, error) {
 	logger := contextutils.LoggerFrom(ctx).Desugar()
 	serverOpts := []grpc.ServerOption{
 		grpc.StreamInterceptor(
@@ -52,8 +52,9 @@ func NewControlPlaneWithListener(
 	}
 	grpcServer := grpc.NewServer(serverOpts...)
 
-	// snapshotCache maintains a single versioned snapshot of responses per node
-	snapshotCache := envoycache.NewSnapshotCache(true, xds.NewNodeRoleHasher(), logger.Sugar()) // ads(Aggregated Discovery Service)
+	// the cache versions every resource of a client on its own, so that a new snapshot only sends the resources that
+	// changed, to the state of the world and delta streams the proxies pick with their bootstrap
+	snapshotCache := xds.NewClientCache(xds.NewNodeRoleHasher(), logger.Sugar())
 
 	xdsServer := xdsserver.NewServer(ctx, snapshotCache, callbacks)
 	reflection.Register(grpcServer) // reflection register for grpc
@@ -63,6 +64,7 @@ func NewControlPlaneWithListener(
 	envoy_service_cluster_v3.RegisterClusterDiscoveryServiceServer(grpcServer, xdsServer)      // CDS cluster discovery
 	envoy_service_route_v3.RegisterRouteDiscoveryServiceServer(grpcServer, xdsServer)          // RDS route discovery
 	envoy_service_listener_v3.RegisterListenerDiscoveryServiceServer(grpcServer, xdsServer)    // LDS listener discovery
+	envoy_service_secret_v3.RegisterSecretDiscoveryServiceServer(grpcServer, xdsServer)        // SDS secret discovery
 	envoy_service_discovery_v3.RegisterAggregatedDiscoveryServiceServer(grpcServer, xdsServer) // ADS aggregated discovery
 
 	go grpcServer.Serve(lis)
@@ -0,0 +1,47 @@
+package deployer
+
+import (
+	"net/netip"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// RequestedServiceAddress returns the IP address requested in Gateway.spec.addresses that the proxy service
+// is provisioned with, and the requested addresses that can not be honoured. A service can only be asked for
+// a single IP, so hostnames, named addresses, invalid IPs and any IP after the first one are unusable.
+func RequestedServiceAddress(gw *api.Gateway) (string, []api.GatewayAddress) {
+	var ip string
+	var unusable []api.GatewayAddress
+	for _, addr := range gw.Spec.Addresses {
+		if ptr.Deref(addr.Type, api.IPAddressType) != api.IPAddressType || ip != "" {
+			unusable = append(unusable, addr)
+			continue
+		}
+		if _, err := netip.ParseAddr(addr.Value); err != nil {
+			unusable = append(unusable, addr)
+			continue
+		}
+		ip = addr.Value
+	}
+	return ip, unusable
+}
+
+// applyRequestedAddress sets the IP requested by the Gateway on the proxy service: as its cluster IP for
+// ClusterIP services, as its load balancer IP otherwise
+func applyRequestedAddress(gw *api.Gateway, vals *helmGateway) {
+	ip, _ := RequestedServiceAddress(gw)
+	if ip == "" {
+		return
+	}
+	if vals.Service == nil {
+		vals.Service = &helmService{}
+	}
+	if lo.FromPtr(vals.Service.Type) == string(corev1.ServiceTypeClusterIP) {
+		vals.Service.ClusterIP = &ip
+	} else {
+		vals.Service.LoadBalancerIP = &ip
+	}
+}
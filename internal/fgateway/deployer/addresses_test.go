@@ -0,0 +1,94 @@
+package deployer
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestRequestedServiceAddress(t *testing.T) {
+	ip := func(v string) api.GatewayAddress { return api.GatewayAddress{Value: v} }
+	typed := func(typ api.AddressType, v string) api.GatewayAddress {
+		return api.GatewayAddress{Type: ptr.To(typ), Value: v}
+	}
+	tests := []struct {
+		name         string
+		addresses    []api.GatewayAddress
+		wantIP       string
+		wantUnusable []string
+	}{
+		{name: "no address"},
+		{name: "untyped ip", addresses: []api.GatewayAddress{ip("10.0.0.1")}, wantIP: "10.0.0.1"},
+		{name: "ipv6", addresses: []api.GatewayAddress{typed(api.IPAddressType, "fd00::1")}, wantIP: "fd00::1"},
+		{
+			name:         "only the first ip is usable",
+			addresses:    []api.GatewayAddress{ip("10.0.0.1"), ip("10.0.0.2")},
+			wantIP:       "10.0.0.1",
+			wantUnusable: []string{"10.0.0.2"},
+		},
+		{
+			name:         "invalid ip is skipped",
+			addresses:    []api.GatewayAddress{ip("not-an-ip"), ip("10.0.0.2")},
+			wantIP:       "10.0.0.2",
+			wantUnusable: []string{"not-an-ip"},
+		},
+		{
+			name: "hostname and named addresses are unusable",
+			addresses: []api.GatewayAddress{
+				typed(api.HostnameAddressType, "gw.example.com"),
+				typed(api.NamedAddressType, "pool-a"),
+			},
+			wantUnusable: []string{"gw.example.com", "pool-a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := &api.Gateway{Spec: api.GatewaySpec{Addresses: tt.addresses}}
+			gotIP, gotUnusable := RequestedServiceAddress(gw)
+			if gotIP != tt.wantIP {
+				t.Errorf("ip = %q, want %q", gotIP, tt.wantIP)
+			}
+			var unusable []string
+			for _, addr := range gotUnusable {
+				unusable = append(unusable, addr.Value)
+			}
+			if !slices.Equal(unusable, tt.wantUnusable) {
+				t.Errorf("unusable = %v, want %v", unusable, tt.wantUnusable)
+			}
+		})
+	}
+}
+
+func TestApplyRequestedAddress(t *testing.T) {
+	gw := &api.Gateway{Spec: api.GatewaySpec{Addresses: []api.GatewayAddress{{Value: "10.0.0.1"}}}}
+	tests := []struct {
+		name             string
+		service          *helmService
+		wantClusterIP    string
+		wantLoadBalancer string
+	}{
+		{name: "chart default type", wantLoadBalancer: "10.0.0.1"},
+		{name: "load balancer", service: &helmService{Type: ptr.To("LoadBalancer")}, wantLoadBalancer: "10.0.0.1"},
+		{name: "cluster ip", service: &helmService{Type: ptr.To("ClusterIP")}, wantClusterIP: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vals := &helmGateway{Service: tt.service}
+			applyRequestedAddress(gw, vals)
+			if got := ptr.Deref(vals.Service.ClusterIP, ""); got != tt.wantClusterIP {
+				t.Errorf("clusterIP = %q, want %q", got, tt.wantClusterIP)
+			}
+			if got := ptr.Deref(vals.Service.LoadBalancerIP, ""); got != tt.wantLoadBalancer {
+				t.Errorf("loadBalancerIP = %q, want %q", got, tt.wantLoadBalancer)
+			}
+		})
+	}
+
+	vals := &helmGateway{}
+	applyRequestedAddress(&api.Gateway{}, vals)
+	if vals.Service != nil {
+		t.Errorf("service = %+v, want no service values without a requested address", vals.Service)
+	}
+}
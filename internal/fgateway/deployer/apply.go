@@ -0,0 +1,115 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ConflictPolicy decides what happens when a server-side apply of a deployed object
+// conflicts with fields owned by another field manager (e.g. an HPA owning spec.replicas).
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyForce takes ownership of the conflicting fields.
+	ConflictPolicyForce ConflictPolicy = "Force"
+	// ConflictPolicyBackOff leaves the object untouched and reports the conflict.
+	ConflictPolicyBackOff ConflictPolicy = "BackOff"
+)
+
+// ApplyConflictError is returned by DeployObjs when one or more objects were not applied
+// because their conflict policy is ConflictPolicyBackOff.
+type ApplyConflictError struct {
+	Conflicts []ApplyConflict
+}
+
+// ApplyConflict describes a single object that was skipped due to a field ownership conflict.
+type ApplyConflict struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	Message          string
+}
+
+func (e *ApplyConflictError) Error() string {
+	msgs := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		msgs = append(msgs, fmt.Sprintf("%s %s.%s: %s", c.GroupVersionKind.Kind, c.Namespace, c.Name, c.Message))
+	}
+	return "backed off applying objects with field ownership conflicts: " + strings.Join(msgs, "; ")
+}
+
+// FieldManager returns the server-side apply field manager used for all deployed objects
+func (d *Deployer) FieldManager() string {
+	return fmt.Sprintf("%s/deployer", d.inputs.ControllerName)
+}
+
+// conflictPolicy returns the conflict policy configured for the given kind
+func (d *Deployer) conflictPolicy(gvk schema.GroupVersionKind) ConflictPolicy {
+	if policy, ok := d.inputs.ConflictPolicies[gvk.GroupKind()]; ok {
+		return policy
+	}
+	return ConflictPolicyForce
+}
+
+// DeployObjs server-side applies the given objects under the deployer's field manager.
+// Only the fields set on the rendered objects are owned by the deployer, so fields owned by other
+// managers (users, HPAs, VPAs) are left alone. Field ownership conflicts are resolved according to
+// the per-kind ConflictPolicy; objects that back off are reported through an *ApplyConflictError
+// after all other objects have been applied.
+func (d *Deployer) DeployObjs(ctx context.Context, objs []client.Object) error {
+	logger := d.logger(ctx)
+
+	var conflicts []ApplyConflict
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, d.cli.Scheme())
+		if err != nil {
+			return err
+		}
+		// apply configurations must carry their type and must not carry server-populated metadata
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+
+		policy := d.conflictPolicy(gvk)
+		logger.V(1).Info("applying object",
+			"kind", gvk.Kind,
+			"namespace", obj.GetNamespace(),
+			"name", obj.GetName(),
+			"conflictPolicy", policy)
+
+		opts := []client.PatchOption{client.FieldOwner(d.FieldManager())}
+		if policy == ConflictPolicyForce {
+			opts = append(opts, client.ForceOwnership)
+		}
+		err = d.cli.Patch(ctx, obj, client.Apply, opts...)
+		if apierrors.IsConflict(err) && policy == ConflictPolicyBackOff {
+			logger.Info("backing off applying object with field ownership conflict",
+				"kind", gvk.Kind,
+				"namespace", obj.GetNamespace(),
+				"name", obj.GetName(),
+				"conflict", err.Error())
+			conflicts = append(conflicts, ApplyConflict{
+				GroupVersionKind: gvk,
+				Namespace:        obj.GetNamespace(),
+				Name:             obj.GetName(),
+				Message:          err.Error(),
+			})
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply object %s %s.%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &ApplyConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package deployer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestDeployObjs(t *testing.T) {
+	deploymentKind := schema.GroupKind{Group: appsv1.GroupName, Kind: "Deployment"}
+	objects := func() []client.Object {
+		return []client.Object{
+			&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "proxy", ResourceVersion: "1"}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "proxy", ResourceVersion: "1"}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "proxy", ResourceVersion: "1"}},
+		}
+	}
+	conflict := apierrors.NewConflict(schema.GroupResource{Group: appsv1.GroupName, Resource: "deployments"}, "proxy",
+		errors.New("spec.replicas is owned by hpa"))
+	tests := []struct {
+		name     string
+		policies map[schema.GroupKind]ConflictPolicy
+		// patchErrs are the errors of the patches of kinds
+		patchErrs map[string]error
+		// wantApplied are the kinds of the objects patched without error, in order
+		wantApplied []string
+		// wantForced are the kinds of the objects patched with ForceOwnership
+		wantForced   []string
+		wantConflict []string
+		wantErr      bool
+	}{
+		{
+			name:        "ownership is forced by default",
+			wantApplied: []string{"ServiceAccount", "Deployment", "Service"},
+			wantForced:  []string{"ServiceAccount", "Deployment", "Service"},
+		},
+		{
+			name:        "kinds backing off are not forced",
+			policies:    map[schema.GroupKind]ConflictPolicy{deploymentKind: ConflictPolicyBackOff},
+			wantApplied: []string{"ServiceAccount", "Deployment", "Service"},
+			wantForced:  []string{"ServiceAccount", "Service"},
+		},
+		{
+			name:         "conflicts of kinds backing off are reported after the other objects are applied",
+			policies:     map[schema.GroupKind]ConflictPolicy{deploymentKind: ConflictPolicyBackOff},
+			patchErrs:    map[string]error{"Deployment": conflict},
+			wantApplied:  []string{"ServiceAccount", "Service"},
+			wantForced:   []string{"ServiceAccount", "Service"},
+			wantConflict: []string{"Deployment"},
+			wantErr:      true,
+		},
+		{
+			name:        "conflicts of forced kinds fail",
+			patchErrs:   map[string]error{"Deployment": conflict},
+			wantApplied: []string{"ServiceAccount"},
+			wantForced:  []string{"ServiceAccount", "Deployment"},
+			wantErr:     true,
+		},
+		{
+			name:        "errors other than conflicts fail kinds backing off",
+			policies:    map[schema.GroupKind]ConflictPolicy{deploymentKind: ConflictPolicyBackOff},
+			patchErrs:   map[string]error{"Deployment": apierrors.NewForbidden(schema.GroupResource{}, "proxy", errors.New("denied"))},
+			wantApplied: []string{"ServiceAccount"},
+			wantForced:  []string{"ServiceAccount"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied, forced []string
+			cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(_ context.Context, _ client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					kind := obj.GetObjectKind().GroupVersionKind().Kind
+					po := &client.PatchOptions{}
+					po.ApplyOptions(opts)
+					if patch.Type() != client.Apply.Type() || po.FieldManager != "fgateway.dev/controller/deployer" {
+						t.Errorf("%s patched with %s by %q, want a server-side apply of the deployer", kind, patch.Type(), po.FieldManager)
+					}
+					if obj.GetResourceVersion() != "" {
+						t.Errorf("%s applied with resourceVersion %q", kind, obj.GetResourceVersion())
+					}
+					if po.Force != nil && *po.Force {
+						forced = append(forced, kind)
+					}
+					if err := tt.patchErrs[kind]; err != nil {
+						return err
+					}
+					applied = append(applied, kind)
+					return nil
+				},
+			}).Build()
+			d := &Deployer{cli: cli, inputs: &Inputs{ControllerName: "fgateway.dev/controller", ConflictPolicies: tt.policies}}
+
+			err := d.DeployObjs(context.Background(), objects())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeployObjs() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(applied, tt.wantApplied) {
+				t.Errorf("applied %v, want %v", applied, tt.wantApplied)
+			}
+			if !slices.Equal(forced, tt.wantForced) {
+				t.Errorf("forced %v, want %v", forced, tt.wantForced)
+			}
+			var conflictErr *ApplyConflictError
+			var conflicts []string
+			if errors.As(err, &conflictErr) {
+				for _, c := range conflictErr.Conflicts {
+					conflicts = append(conflicts, c.GroupVersionKind.Kind)
+				}
+			}
+			if !slices.Equal(conflicts, tt.wantConflict) {
+				t.Errorf("conflicts %v, want %v", conflicts, tt.wantConflict)
+			}
+		})
+	}
+}
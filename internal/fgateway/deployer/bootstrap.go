@@ -0,0 +1,293 @@
+package deployer
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	envoy_config_bootstrap_v3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_router_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_upstreams_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	// envoyConfigPath is where the proxy ConfigMap holding the bootstrap is mounted in the envoy container
+	envoyConfigPath = "/etc/envoy/envoy.json"
+
+	// envoyNodeID is the node id every proxy announces to the control plane. The pod name and
+	// namespace are expanded by kubernetes from the downward api env vars of the envoy container,
+	// and are used by the xds callbacks to look up the pod of a connecting client.
+	envoyNodeID = "$(POD_NAME).$(POD_NAMESPACE)"
+
+	defaultEnvoyLogLevel = "info"
+
+	envoyAdminAddress = "127.0.0.1"
+	envoyAdminPort    = 19000
+	envoyStatsPort    = 9091
+	sdsPort           = 8234
+
+	xdsClusterName          = "xds_cluster"
+	adminClusterName        = "admin_port_cluster"
+	sdsClusterName          = "gateway_proxy_sds"
+	prometheusListenerName  = "prometheus_listener"
+	defaultMetricsRewrite   = "/stats/prometheus"
+	defaultStatsRoute       = "/stats"
+	bootstrapConnectTimeout = 5 * time.Second
+)
+
+// bootstrapInputs is everything needed to generate the bootstrap of a gateway proxy
+type bootstrapInputs struct {
+	gateway   *api.Gateway
+	xdsHost   string
+	xdsPort   int32
+	bootstrap *v1alpha1.EnvoyBootstrap
+	stats     *v1alpha1.StatsConfig
+	// sds is set when the proxy runs the sds sidecar
+	sds bool
+}
+
+// getBootstrapValues generates the envoy bootstrap config and the command line arguments of the envoy container
+func getBootstrapValues(in bootstrapInputs) (*helmBootstrap, error) {
+	args, err := envoyArgs(in.bootstrap)
+	if err != nil {
+		return nil, err
+	}
+	bootstrap, err := buildBootstrap(in)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := protojson.Marshal(bootstrap)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal envoy bootstrap")
+	}
+	// protojson output is deliberately unstable, re-indent it so the same bootstrap always renders
+	// the same ConfigMap and does not roll the proxy pods
+	var config bytes.Buffer
+	if err := json.Indent(&config, raw, "", "  "); err != nil {
+		return nil, errors.Wrap(err, "failed to format envoy bootstrap")
+	}
+	return &helmBootstrap{
+		Config: lo.ToPtr(config.String()),
+		Args:   args,
+	}, nil
+}
+
+// envoyArgs returns the envoy command line. The node id has no place in the static config since it
+// depends on the pod, so it is passed with --service-node which takes precedence over node.id.
+func envoyArgs(bootstrap *v1alpha1.EnvoyBootstrap) ([]string, error) {
+	logLevel := lo.FromPtr(bootstrap.GetLogLevel())
+	if logLevel == "" {
+		logLevel = defaultEnvoyLogLevel
+	}
+	args := []string{
+		"--disable-hot-restart",
+		"--service-node", envoyNodeID,
+		"--log-level", logLevel,
+	}
+	compLogLevels, err := ComponentLogLevelsToString(bootstrap.GetComponentLogLevels())
+	if err != nil {
+		return nil, err
+	}
+	if compLogLevels != "" {
+		args = append(args, "--component-log-level", compLogLevels)
+	}
+	return append(args, "--config-path", envoyConfigPath), nil
+}
+
+// buildBootstrap builds the static envoy config: the node identity, the admin interface, ADS through the
+// xds cluster in the state of the world or delta variant and, if enabled, the prometheus listener and the sds cluster
+func buildBootstrap(in bootstrapInputs) (*envoy_config_bootstrap_v3.Bootstrap, error) {
+	gw := in.gateway
+	clusters := []*envoy_config_cluster_v3.Cluster{
+		singleEndpointCluster(xdsClusterName, envoy_config_cluster_v3.Cluster_STRICT_DNS, in.xdsHost, uint32(in.xdsPort), true),
+		singleEndpointCluster(adminClusterName, envoy_config_cluster_v3.Cluster_STATIC, envoyAdminAddress, envoyAdminPort, false),
+	}
+	if in.sds {
+		clusters = append(clusters, singleEndpointCluster(sdsClusterName, envoy_config_cluster_v3.Cluster_STATIC, "127.0.0.1", sdsPort, true))
+	}
+
+	var listeners []*envoy_config_listener_v3.Listener
+	if lo.FromPtr(in.stats.GetEnabled()) {
+		l, err := prometheusListener(in.stats)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	// delta xds is negotiated per stream by the api type of the ads config source
+	adsAPIType := envoy_config_core_v3.ApiConfigSource_GRPC
+	if lo.FromPtr(in.bootstrap.GetXdsProtocol()) == v1alpha1.XdsProtocolDelta {
+		adsAPIType = envoy_config_core_v3.ApiConfigSource_DELTA_GRPC
+	}
+	ads := &envoy_config_core_v3.ConfigSource{
+		ResourceApiVersion:    envoy_config_core_v3.ApiVersion_V3,
+		ConfigSourceSpecifier: &envoy_config_core_v3.ConfigSource_Ads{Ads: &envoy_config_core_v3.AggregatedConfigSource{}},
+	}
+	return &envoy_config_bootstrap_v3.Bootstrap{
+		Node: &envoy_config_core_v3.Node{
+			Cluster: gw.GetName() + "." + gw.GetNamespace(),
+			Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+				xds.RoleKey: structpb.NewStringValue(xds.GatewayProxyRole(gw.GetNamespace(), gw.GetName())),
+			}},
+		},
+		Admin: &envoy_config_bootstrap_v3.Admin{
+			Address: socketAddress(envoyAdminAddress, envoyAdminPort),
+		},
+		DynamicResources: &envoy_config_bootstrap_v3.Bootstrap_DynamicResources{
+			AdsConfig: &envoy_config_core_v3.ApiConfigSource{
+				ApiType:             adsAPIType,
+				TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+				RateLimitSettings:   &envoy_config_core_v3.RateLimitSettings{},
+				GrpcServices: []*envoy_config_core_v3.GrpcService{{
+					TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+						EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{ClusterName: xdsClusterName},
+					},
+				}},
+			},
+			CdsConfig: ads,
+			LdsConfig: proto.Clone(ads).(*envoy_config_core_v3.ConfigSource),
+		},
+		StaticResources: &envoy_config_bootstrap_v3.Bootstrap_StaticResources{
+			Listeners: listeners,
+			Clusters:  clusters,
+		},
+	}, nil
+}
+
+// prometheusListener exposes the readiness and metrics endpoints of the admin interface
+func prometheusListener(stats *v1alpha1.StatsConfig) (*envoy_config_listener_v3.Listener, error) {
+	metricsRewrite := lo.FromPtr(stats.GetRoutePrefixRewrite())
+	if metricsRewrite == "" {
+		metricsRewrite = defaultMetricsRewrite
+	}
+	routes := []*envoy_config_route_v3.Route{
+		adminRoute(&envoy_config_route_v3.RouteMatch{
+			PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{Path: "/ready"},
+		}, ""),
+		adminRoute(&envoy_config_route_v3.RouteMatch{
+			PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{Prefix: "/metrics"},
+		}, metricsRewrite),
+	}
+	if lo.FromPtr(stats.GetEnableStatsRoute()) {
+		statsRewrite := lo.FromPtr(stats.GetStatsRoutePrefixRewrite())
+		if statsRewrite == "" {
+			statsRewrite = defaultStatsRoute
+		}
+		routes = append(routes, adminRoute(&envoy_config_route_v3.RouteMatch{
+			PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{Prefix: defaultStatsRoute},
+		}, statsRewrite))
+	}
+
+	router, err := anypb.New(&envoy_extensions_filters_http_router_v3.Router{})
+	if err != nil {
+		return nil, err
+	}
+	hcm, err := anypb.New(&envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{
+		StatPrefix: "prometheus",
+		CodecType:  envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_AUTO,
+		RouteSpecifier: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_RouteConfig{
+			RouteConfig: &envoy_config_route_v3.RouteConfiguration{
+				Name: "prometheus_route",
+				VirtualHosts: []*envoy_config_route_v3.VirtualHost{{
+					Name:    "prometheus_host",
+					Domains: []string{"*"},
+					Routes:  routes,
+				}},
+			},
+		},
+		HttpFilters: []*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{{
+			Name:       wellknown.Router,
+			ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{TypedConfig: router},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &envoy_config_listener_v3.Listener{
+		Name:    prometheusListenerName,
+		Address: socketAddress("0.0.0.0", envoyStatsPort),
+		FilterChains: []*envoy_config_listener_v3.FilterChain{{
+			Filters: []*envoy_config_listener_v3.Filter{{
+				Name:       wellknown.HTTPConnectionManager,
+				ConfigType: &envoy_config_listener_v3.Filter_TypedConfig{TypedConfig: hcm},
+			}},
+		}},
+	}, nil
+}
+
+func adminRoute(match *envoy_config_route_v3.RouteMatch, prefixRewrite string) *envoy_config_route_v3.Route {
+	return &envoy_config_route_v3.Route{
+		Match: match,
+		Action: &envoy_config_route_v3.Route_Route{
+			Route: &envoy_config_route_v3.RouteAction{
+				ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{Cluster: adminClusterName},
+				PrefixRewrite:    prefixRewrite,
+			},
+		},
+	}
+}
+
+// singleEndpointCluster returns a single endpoint cluster, speaking http2 to the upstream when http2 is set
+func singleEndpointCluster(name string, discoveryType envoy_config_cluster_v3.Cluster_DiscoveryType, host string, port uint32, http2 bool) *envoy_config_cluster_v3.Cluster {
+	cluster := &envoy_config_cluster_v3.Cluster{
+		Name:                 name,
+		ClusterDiscoveryType: &envoy_config_cluster_v3.Cluster_Type{Type: discoveryType},
+		ConnectTimeout:       durationpb.New(bootstrapConnectTimeout),
+		LoadAssignment: &envoy_config_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: name,
+			Endpoints: []*envoy_config_endpoint_v3.LocalityLbEndpoints{{
+				LbEndpoints: []*envoy_config_endpoint_v3.LbEndpoint{{
+					HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+						Endpoint: &envoy_config_endpoint_v3.Endpoint{Address: socketAddress(host, port)},
+					},
+				}},
+			}},
+		},
+	}
+	if http2 {
+		// HttpProtocolOptions is a registered type, wrapping it in an Any can not fail
+		opts, _ := anypb.New(&envoy_extensions_upstreams_http_v3.HttpProtocolOptions{
+			UpstreamProtocolOptions: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+				ExplicitHttpConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+					ProtocolConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+						Http2ProtocolOptions: &envoy_config_core_v3.Http2ProtocolOptions{},
+					},
+				},
+			},
+		})
+		cluster.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": opts,
+		}
+	}
+	return cluster
+}
+
+func socketAddress(host string, port uint32) *envoy_config_core_v3.Address {
+	return &envoy_config_core_v3.Address{
+		Address: &envoy_config_core_v3.Address_SocketAddress{
+			SocketAddress: &envoy_config_core_v3.SocketAddress{
+				Address:       host,
+				PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{PortValue: port},
+			},
+		},
+	}
+}
@@ -0,0 +1,171 @@
+package deployer
+
+import (
+	"slices"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestEnvoyArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		bootstrap *v1alpha1.EnvoyBootstrap
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "defaults",
+			want: []string{"--disable-hot-restart", "--service-node", envoyNodeID, "--log-level", "info", "--config-path", envoyConfigPath},
+		},
+		{
+			name:      "log levels",
+			bootstrap: &v1alpha1.EnvoyBootstrap{LogLevel: ptr.To("debug"), ComponentLogLevels: map[string]string{"upstream": "trace", "http": "warn"}},
+			want: []string{
+				"--disable-hot-restart", "--service-node", envoyNodeID, "--log-level", "debug",
+				"--component-log-level", "http:warn,upstream:trace", "--config-path", envoyConfigPath,
+			},
+		},
+		{
+			name:      "component without level",
+			bootstrap: &v1alpha1.EnvoyBootstrap{ComponentLogLevels: map[string]string{"upstream": ""}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := envoyArgs(tt.bootstrap)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envoyArgs() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("envoyArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildBootstrap(t *testing.T) {
+	gw := &api.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"}}
+	tests := []struct {
+		name         string
+		in           bootstrapInputs
+		wantAPIType  envoy_config_core_v3.ApiConfigSource_ApiType
+		wantClusters []string
+		// wantRoutes are the paths or prefixes of the routes of the prometheus listener, which is left out when empty
+		wantRoutes []string
+	}{
+		{
+			name:         "state of the world",
+			in:           bootstrapInputs{gateway: gw, xdsHost: "fgateway.system.svc", xdsPort: 9000},
+			wantAPIType:  envoy_config_core_v3.ApiConfigSource_GRPC,
+			wantClusters: []string{xdsClusterName, adminClusterName},
+		},
+		{
+			name: "delta",
+			in: bootstrapInputs{
+				gateway: gw, xdsHost: "fgateway.system.svc", xdsPort: 9000,
+				bootstrap: &v1alpha1.EnvoyBootstrap{XdsProtocol: ptr.To(v1alpha1.XdsProtocolDelta)},
+			},
+			wantAPIType:  envoy_config_core_v3.ApiConfigSource_DELTA_GRPC,
+			wantClusters: []string{xdsClusterName, adminClusterName},
+		},
+		{
+			name:         "sds",
+			in:           bootstrapInputs{gateway: gw, xdsHost: "fgateway.system.svc", xdsPort: 9000, sds: true},
+			wantAPIType:  envoy_config_core_v3.ApiConfigSource_GRPC,
+			wantClusters: []string{xdsClusterName, adminClusterName, sdsClusterName},
+		},
+		{
+			name: "stats",
+			in: bootstrapInputs{
+				gateway: gw, xdsHost: "fgateway.system.svc", xdsPort: 9000,
+				stats: &v1alpha1.StatsConfig{Enabled: ptr.To(true), EnableStatsRoute: ptr.To(true)},
+			},
+			wantAPIType:  envoy_config_core_v3.ApiConfigSource_GRPC,
+			wantClusters: []string{xdsClusterName, adminClusterName},
+			wantRoutes:   []string{"/ready", "/metrics", defaultStatsRoute},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bootstrap, err := buildBootstrap(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if role := bootstrap.GetNode().GetMetadata().GetFields()[xds.RoleKey].GetStringValue(); role != xds.GatewayProxyRole("default", "gw") {
+				t.Errorf("node role = %q, want the one of the gateway", role)
+			}
+			if got := bootstrap.GetDynamicResources().GetAdsConfig().GetApiType(); got != tt.wantAPIType {
+				t.Errorf("ads api type = %s, want %s", got, tt.wantAPIType)
+			}
+			var clusters []string
+			for _, c := range bootstrap.GetStaticResources().GetClusters() {
+				clusters = append(clusters, c.GetName())
+			}
+			if !slices.Equal(clusters, tt.wantClusters) {
+				t.Errorf("clusters = %v, want %v", clusters, tt.wantClusters)
+			}
+			xdsAddress := bootstrap.GetStaticResources().GetClusters()[0].GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetSocketAddress()
+			if xdsAddress.GetAddress() != tt.in.xdsHost || xdsAddress.GetPortValue() != uint32(tt.in.xdsPort) {
+				t.Errorf("xds address = %v, want %s:%d", xdsAddress, tt.in.xdsHost, tt.in.xdsPort)
+			}
+
+			listeners := bootstrap.GetStaticResources().GetListeners()
+			if len(tt.wantRoutes) == 0 {
+				if len(listeners) != 0 {
+					t.Errorf("listeners = %v, want none", listeners)
+				}
+				return
+			}
+			if len(listeners) != 1 || listeners[0].GetName() != prometheusListenerName {
+				t.Fatalf("listeners = %v, want the prometheus listener", listeners)
+			}
+			hcm := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+			if err := listeners[0].GetFilterChains()[0].GetFilters()[0].GetTypedConfig().UnmarshalTo(hcm); err != nil {
+				t.Fatal(err)
+			}
+			var routes []string
+			for _, r := range hcm.GetRouteConfig().GetVirtualHosts()[0].GetRoutes() {
+				switch p := r.GetMatch().GetPathSpecifier().(type) {
+				case *envoy_config_route_v3.RouteMatch_Path:
+					routes = append(routes, p.Path)
+				case *envoy_config_route_v3.RouteMatch_Prefix:
+					routes = append(routes, p.Prefix)
+				}
+			}
+			if !slices.Equal(routes, tt.wantRoutes) {
+				t.Errorf("prometheus routes = %v, want %v", routes, tt.wantRoutes)
+			}
+		})
+	}
+}
+
+func TestGetBootstrapValuesIsStable(t *testing.T) {
+	in := bootstrapInputs{
+		gateway: &api.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"}},
+		xdsHost: "fgateway.system.svc",
+		xdsPort: 9000,
+		stats:   &v1alpha1.StatsConfig{Enabled: ptr.To(true)},
+	}
+	first, err := getBootstrapValues(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		again, err := getBootstrapValues(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *again.Config != *first.Config {
+			t.Fatalf("bootstrap changed between renders:\n%s\n%s", *first.Config, *again.Config)
+		}
+	}
+}
@@ -14,10 +14,12 @@ import (
 	"helm.sh/helm/v3/pkg/storage/driver"
 
 	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/logutil"
 	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
 	"github.com/fleezesd/fgateway/internal/version"
 	"github.com/fleezesd/fgateway/manifests/helm"
 	"github.com/fleezesd/fgateway/pkg/utils/helmutil"
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -26,7 +28,6 @@ import (
 
 	"github.com/samber/lo"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 var (
@@ -37,6 +38,13 @@ var (
 			gwpNamespace, gwpName, resourceType, gwNamespace, gwName)
 	}
 	NilDeployerInputsErr = errors.New("nil inputs to NewDeployer")
+
+	ComponentLogLevelEmptyKeyError = func(val string) error {
+		return errors.Errorf("an empty key was provided in componentLogLevels with value %s", val)
+	}
+	ComponentLogLevelEmptyValueError = func(key string) error {
+		return errors.Errorf("an empty value was provided in componentLogLevels for key %s", key)
+	}
 )
 
 type Deployer struct {
@@ -44,6 +52,9 @@ type Deployer struct {
 	cli   client.Client
 
 	inputs *Inputs
+
+	// inventory is the set of GVKs the chart can render, used to find previously deployed objects
+	inventory []schema.GroupVersionKind
 }
 
 // Inputs is the set of options used to configure the gateway deployer deployment
@@ -53,6 +64,14 @@ type Inputs struct {
 	IstioIntegrationEnabled bool
 	ControlPlane            *ControlPlaneInfo
 	Aws                     *AwsInfo
+
+	// ConflictPolicies overrides how field ownership conflicts are handled per kind
+	// when applying deployed objects. Kinds not listed use ConflictPolicyForce.
+	ConflictPolicies map[schema.GroupKind]ConflictPolicy
+
+	// Logging filters the logs of the deployer by the level of its scope. When nil, they are logged at the level of
+	// the logger of the context.
+	Logging *logutil.Registry
 }
 
 type ControlPlaneInfo struct {
@@ -81,10 +100,24 @@ func NewDeployer(cli client.Client, inputs *Inputs) (*Deployer, error) {
 		helmChart.Metadata.AppVersion = version.Version
 		helmChart.Metadata.Version = version.Version
 	}
-	return &Deployer{
+	d := &Deployer{
+		chart:  helmChart,
 		cli:    cli,
 		inputs: inputs,
-	}, nil
+	}
+	if d.inventory, err = d.chartGVKs(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// logger returns the logger of ctx at the level of the deployer scope
+func (d *Deployer) logger(ctx context.Context) logr.Logger {
+	var logging *logutil.Registry
+	if d.inputs != nil {
+		logging = d.inputs.Logging
+	}
+	return logging.FromContext(ctx, logutil.DeployerScope)
 }
 
 // loadFs use to load helm chart files
@@ -135,45 +168,41 @@ func loadFs(filesystem fs.FS) (*chart.Chart, error) {
 	return loader.LoadFiles(bufferedFiles)
 }
 
-// GetGvksToWatch returns the list of GVKs that the deployer will watch for
+// GetGvksToWatch returns the list of GVKs that the deployer will watch for, which are all the ones the chart can
+// render, whatever the values
 func (d *Deployer) GetGvksToWatch(ctx context.Context) ([]schema.GroupVersionKind, error) {
-	// Create a default empty gateway for rendering
-	defaultGateway := &api.Gateway{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: "default",
-			Name:      "default",
-		},
-	}
+	logger := d.logger(ctx)
+	logger.V(1).Info("watching GVKs", "GVKs", d.inventory)
+	return d.inventory, nil
+}
 
-	// Define default helm values
-	helmValues := map[string]any{
-		"gateway": map[string]any{
-			"istio": map[string]any{
-				"enabled": false,
-			},
-			"image": map[string]any{},
+// inventoryValues are the helm values rendering every object the chart can render, including the ones
+// GatewayParameters may turn off, e.g. the ServiceAccount of the proxy. A template only rendered for some values must
+// be turned on here, or the objects it rendered are never pruned.
+var inventoryValues = map[string]any{
+	"gateway": map[string]any{
+		"serviceAccount": map[string]any{
+			"create": true,
 		},
-	}
+		"istio": map[string]any{
+			"enabled": true,
+		},
+	},
+}
 
-	// Render chart objects
-	objects, err := d.renderChartToObjects(defaultGateway, helmValues)
+// chartGVKs returns the GVKs of the objects the chart renders with the inventory values
+func (d *Deployer) chartGVKs() ([]schema.GroupVersionKind, error) {
+	objects, err := d.Render("inventory", "default", inventoryValues)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to render the inventory of the chart")
 	}
-
-	// Extract unique GroupVersionKinds
-	uniqueGVKs := make([]schema.GroupVersionKind, 0)
+	var gvks []schema.GroupVersionKind
 	for _, obj := range objects {
-		gvk := obj.GetObjectKind().GroupVersionKind()
-		if !slices.Contains(uniqueGVKs, gvk) {
-			uniqueGVKs = append(uniqueGVKs, gvk)
+		if gvk := obj.GetObjectKind().GroupVersionKind(); !slices.Contains(gvks, gvk) {
+			gvks = append(gvks, gvk)
 		}
 	}
-
-	logger := log.FromContext(ctx)
-	logger.V(1).Info("watching GVKs", "GVKs", uniqueGVKs)
-
-	return uniqueGVKs, nil
+	return gvks, nil
 }
 
 // renderChartToObjects renders the Helm chart to Kubernetes objects and sets their namespace
@@ -181,7 +210,7 @@ func (d *Deployer) renderChartToObjects(gw *api.Gateway, vals map[string]any) ([
 	// Render the chart using gateway name and namespace
 	objects, err := d.Render(gw.Name, gw.Namespace, vals)
 	if err != nil {
-		return nil, errors.Errorf("failed to render chart: %w", err)
+		return nil, errors.Wrap(err, "failed to render chart")
 	}
 
 	// Ensure all objects are in the gateway's namespace
@@ -223,21 +252,24 @@ func (d *Deployer) Render(name, ns string, vals map[string]any) ([]client.Object
 // * use those helm values to render the internal `gloo-gateway` helm chart into k8s objects
 // * sets ownerRefs on all generated objects
 // * returns the objects to be deployed by the caller
-func (d *Deployer) GetObjsToDeploy(ctx context.Context, gw *api.Gateway) ([]client.Object, error) {
+//
+// It reports whether the Gateway uses self-managed GatewayParameters, in which case no proxy is provisioned for it
+// and no object is returned.
+func (d *Deployer) GetObjsToDeploy(ctx context.Context, gw *api.Gateway) (objs []client.Object, selfManaged bool, err error) {
 	gwParam, err := d.getGatewayParametersForGateway(ctx, gw)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// If this is a self-managed Gateway, skip gateway auto provisioning
 	if gwParam != nil && gwParam.Spec.SelfManaged != nil {
-		return nil, nil
+		return nil, true, nil
 	}
 
-	logger := log.FromContext(ctx)
+	logger := d.logger(ctx)
 
 	vals, err := d.getValues(gw, gwParam)
 	if err != nil {
-		return nil, errors.Errorf("failed to get values to render objects for gateway %s.%s: %w", gw.GetNamespace(), gw.GetName(), err)
+		return nil, false, errors.Wrapf(err, "failed to get values to render objects for gateway %s.%s", gw.GetNamespace(), gw.GetName())
 	}
 	logger.V(1).Info("got deployer helm values",
 		"gatewayName", gw.GetName(),
@@ -248,13 +280,13 @@ func (d *Deployer) GetObjsToDeploy(ctx context.Context, gw *api.Gateway) ([]clie
 	var convertedVals map[string]any
 	err = jsonConvert(vals, &convertedVals)
 	if err != nil {
-		return nil, errors.Errorf("failed to convert helm values for gateway %s.%s: %w", gw.GetNamespace(), gw.GetName(), err)
+		return nil, false, errors.Wrapf(err, "failed to convert helm values for gateway %s.%s", gw.GetNamespace(), gw.GetName())
 	}
-	objs, err := d.renderChartToObjects(gw, convertedVals)
+	objs, err = d.renderChartToObjects(gw, convertedVals)
 	if err != nil {
-		return nil, errors.Errorf("failed to get objects to deploy for gateway %s.%s: %w", gw.GetNamespace(), gw.GetName(), err)
+		return nil, false, errors.Wrapf(err, "failed to get objects to deploy for gateway %s.%s", gw.GetNamespace(), gw.GetName())
 	}
-	// Set owner ref
+	// Set owner ref and ownership label
 	for _, obj := range objs {
 		obj.SetOwnerReferences([]metav1.OwnerReference{{
 			Kind:       gw.Kind,
@@ -263,15 +295,21 @@ func (d *Deployer) GetObjsToDeploy(ctx context.Context, gw *api.Gateway) ([]clie
 			UID:        gw.UID,
 			Name:       gw.Name,
 		}})
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[wellknown.GatewayOwnerLabel] = string(gw.UID)
+		obj.SetLabels(labels)
 	}
 
-	return objs, nil
+	return objs, false, nil
 }
 
 // getGatewayParametersForGateway returns the a merged GatewayParameters object resulting from the default GwParams object and
 // the GwParam object specifically associated with the given Gateway (if one exists).
 func (d *Deployer) getGatewayParametersForGateway(ctx context.Context, gw *api.Gateway) (*v1alpha1.GatewayParameters, error) {
-	logger := log.FromContext(ctx)
+	logger := d.logger(ctx)
 
 	gwpName := gw.GetAnnotations()[wellknown.GatewayParametersAnnonationName]
 	if gwpName == "" {
@@ -323,7 +361,7 @@ func (d *Deployer) getGatewayClassFromGateway(ctx context.Context, gw *api.Gatew
 }
 
 func (d *Deployer) getGatewayParametersForGatewayClass(ctx context.Context, gwc *api.GatewayClass) (*v1alpha1.GatewayParameters, error) {
-	logger := log.FromContext(ctx)
+	logger := d.logger(ctx)
 
 	paramRef := gwc.Spec.ParametersRef
 	if lo.IsNil(paramRef) {
@@ -372,17 +410,123 @@ func prepareInstallAction(cfg *action.Configuration, name, namespace string) *ac
 
 // formatRenderError creates a formatted error for Helm chart rendering failures
 func formatRenderError(err error, namespace, name string) error {
-	return errors.Errorf("failed to render helm chart for gateway %s.%s: %w", namespace, name, err)
+	return errors.Wrapf(err, "failed to render helm chart for gateway %s.%s", namespace, name)
 }
 
 // formatConversionError creates a formatted error for YAML conversion failures
 func formatConversionError(err error, namespace, name string) error {
-	return errors.Errorf("failed to convert helm manifest yaml to objects for gateway %s.%s: %w", namespace, name, err)
+	return errors.Wrapf(err, "failed to convert helm manifest yaml to objects for gateway %s.%s", namespace, name)
 }
 
-// TODO: make get values for get helm config
+// getValues translates the Gateway and its merged GatewayParameters into the helm values used to render the proxy
 func (d *Deployer) getValues(gw *api.Gateway, gwParam *v1alpha1.GatewayParameters) (*helmConfig, error) {
-	return &helmConfig{}, nil
+	vals := &helmConfig{
+		Gateway: &helmGateway{
+			Name:             &gw.Name,
+			GatewayName:      &gw.Name,
+			GatewayNamespace: &gw.Namespace,
+			Ports:            getPortsValues(gw),
+			Istio: &helmIstio{
+				Enabled: ptr.To(d.inputs.IstioIntegrationEnabled),
+			},
+		},
+	}
+	if d.inputs.Aws != nil {
+		vals.Gateway.Aws = &helmAws{
+			EnableServiceAccountCredentials: ptr.To(d.inputs.Aws.EnableServiceAccountCredentials),
+			StsClusterName:                  ptr.To(d.inputs.Aws.StsClusterName),
+			StsUri:                          ptr.To(d.inputs.Aws.StsUri),
+		}
+	}
+
+	// if there is no GatewayParameters, only generate the default bootstrap
+	if gwParam == nil {
+		bootstrap, err := getBootstrapValues(d.bootstrapInputs(gw, nil, nil, false))
+		if err != nil {
+			return nil, err
+		}
+		vals.Gateway.Bootstrap = bootstrap
+		applyRequestedAddress(gw, vals.Gateway)
+		return vals, nil
+	}
+
+	kubeProxyConfig := gwParam.Spec.Kube
+	deployConfig := kubeProxyConfig.GetDeployment()
+	podConfig := kubeProxyConfig.GetPodTemplate()
+	envoyContainerConfig := kubeProxyConfig.GetEnvoyContainer()
+	svcConfig := kubeProxyConfig.GetService()
+	svcAccountConfig := kubeProxyConfig.GetServiceAccount()
+	istioConfig := kubeProxyConfig.GetIstio()
+	sdsContainerConfig := kubeProxyConfig.GetSdsContainer()
+	statsConfig := kubeProxyConfig.GetStats()
+	aiExtensionConfig := kubeProxyConfig.GetAiExtension()
+
+	gateway := vals.Gateway
+	var err error
+
+	// deployment values
+	gateway.ReplicaCount = deployConfig.GetReplicas()
+
+	// service values
+	gateway.Service = getServiceValues(svcConfig)
+	// serviceaccount values
+	gateway.ServiceAccount = getServiceAccountValues(svcAccountConfig)
+
+	// pod template values
+	gateway.ExtraPodAnnotations = podConfig.GetExtraAnnotations()
+	gateway.ExtraPodLabels = podConfig.GetExtraLabels()
+	gateway.ImagePullSecrets = podConfig.GetImagePullSecrets()
+	gateway.PodSecurityContext = podConfig.GetSecurityContext()
+	gateway.NodeSelector = podConfig.GetNodeSelector()
+	gateway.Affinity = podConfig.GetAffinity()
+	gateway.Tolerations = podConfig.GetTolerations()
+	gateway.ReadinessProbe = podConfig.GetReadinessProbe()
+	gateway.LivenessProbe = podConfig.GetLivenessProbe()
+	gateway.GracefulShutdown = podConfig.GetGracefulShutdown()
+	gateway.TerminationGracePeriodSeconds = podConfig.GetTerminationGracePeriodSeconds()
+
+	// envoy container values
+	gateway.Bootstrap, err = getBootstrapValues(d.bootstrapInputs(gw, envoyContainerConfig.GetBootstrap(), statsConfig, sdsContainerConfig != nil))
+	if err != nil {
+		return nil, err
+	}
+	gateway.Image = getImageValues(envoyContainerConfig.GetImage())
+	gateway.Resources = envoyContainerConfig.GetResources()
+	gateway.SecurityContext = envoyContainerConfig.GetSecurityContext()
+
+	// sds container values
+	gateway.SdsContainer = getSdsContainerValues(sdsContainerConfig)
+	// istio values
+	gateway.Istio.CustomSidecars = istioConfig.GetCustomSidecars()
+	gateway.IstioContainer = getIstioContainerValues(istioConfig.GetIstioProxyContainer())
+	// stats values
+	gateway.Stats = getStatsValues(statsConfig)
+	// ai extension values
+	gateway.AIExtension, err = getAIExtensionValues(aiExtensionConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if lo.FromPtr(kubeProxyConfig.GetFloatingUserId()) {
+		applyFloatingUserId(gateway)
+	}
+
+	// addresses requested by the Gateway take precedence over the service configuration
+	applyRequestedAddress(gw, gateway)
+
+	return vals, nil
+}
+
+// bootstrapInputs collects the inputs of the envoy bootstrap generation for a gateway
+func (d *Deployer) bootstrapInputs(gw *api.Gateway, bootstrap *v1alpha1.EnvoyBootstrap, stats *v1alpha1.StatsConfig, sds bool) bootstrapInputs {
+	return bootstrapInputs{
+		gateway:   gw,
+		xdsHost:   d.inputs.ControlPlane.XdsHost,
+		xdsPort:   d.inputs.ControlPlane.XdsPort,
+		bootstrap: bootstrap,
+		stats:     stats,
+		sds:       sds,
+	}
 }
 
 func jsonConvert(in *helmConfig, out interface{}) error {
@@ -0,0 +1,251 @@
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// testDeployer returns a deployer whose client holds the objects, along with a GatewayClass named fgateway whose
+// default GatewayParameters are system/default
+func testDeployer(t *testing.T, inputs *Inputs, objs ...client.Object) (*Deployer, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, api.Install, v1alpha1.SchemeBuilder.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gwc := &api.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "fgateway"},
+		Spec: api.GatewayClassSpec{
+			ControllerName: "fgateway.dev/controller",
+			ParametersRef: &api.ParametersReference{
+				Group:     api.Group(v1alpha1.GroupVersion.Group),
+				Kind:      "GatewayParameters",
+				Name:      "default",
+				Namespace: ptr.To[api.Namespace]("system"),
+			},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objs, gwc)...).Build()
+	inputs.ControllerName = "fgateway.dev/controller"
+	inputs.ControlPlane = &ControlPlaneInfo{XdsHost: "fgateway.system.svc", XdsPort: 9000}
+	d, err := NewDeployer(cli, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, cli
+}
+
+// testGateway returns a Gateway of the default namespace with an HTTP listener on 8080, using the GatewayParameters
+// named parameters when set
+func testGateway(parameters string) *api.Gateway {
+	gw := &api.Gateway{
+		TypeMeta:   metav1.TypeMeta{APIVersion: api.GroupVersion.String(), Kind: "Gateway"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw", UID: "gw-uid"},
+		Spec: api.GatewaySpec{
+			GatewayClassName: "fgateway",
+			Listeners:        []api.Listener{{Name: "http", Port: 8080, Protocol: api.HTTPProtocolType}},
+		},
+	}
+	if parameters != "" {
+		gw.Annotations = map[string]string{wellknown.GatewayParametersAnnonationName: parameters}
+	}
+	return gw
+}
+
+func TestGetObjsToDeploy(t *testing.T) {
+	objects := []client.Object{
+		&v1alpha1.GatewayParameters{ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "default"}},
+		&v1alpha1.GatewayParameters{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "self-managed"},
+			Spec:       v1alpha1.GatewayParametersSpec{SelfManaged: &v1alpha1.SelfManagedGateway{}},
+		},
+	}
+	tests := []struct {
+		name      string
+		gw        *api.Gateway
+		wantKinds []string
+		// wantSelfManaged is whether no proxy is provisioned for the gateway
+		wantSelfManaged bool
+		wantErr         bool
+	}{
+		{name: "proxy", gw: testGateway(""), wantKinds: []string{"ConfigMap", "Deployment", "Service", "ServiceAccount"}},
+		{name: "self-managed", gw: testGateway("self-managed"), wantSelfManaged: true},
+		{name: "missing parameters", gw: testGateway("missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, cli := testDeployer(t, &Inputs{}, objects...)
+			objs, selfManaged, err := d.GetObjsToDeploy(context.Background(), tt.gw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetObjsToDeploy() error = %v, want error %v", err, tt.wantErr)
+			}
+			if selfManaged != tt.wantSelfManaged {
+				t.Errorf("GetObjsToDeploy() selfManaged = %v, want %v", selfManaged, tt.wantSelfManaged)
+			}
+			var kinds []string
+			for _, obj := range objs {
+				gvk, err := cli.GroupVersionKindFor(obj)
+				if err != nil {
+					t.Fatal(err)
+				}
+				kinds = append(kinds, gvk.Kind)
+				if obj.GetNamespace() != "default" {
+					t.Errorf("%s %s is rendered in namespace %q, want the one of the gateway", gvk.Kind, obj.GetName(), obj.GetNamespace())
+				}
+				if obj.GetLabels()[wellknown.GatewayOwnerLabel] != "gw-uid" {
+					t.Errorf("%s %s has labels %v, want the owner label of the gateway", gvk.Kind, obj.GetName(), obj.GetLabels())
+				}
+				if owner := metav1.GetControllerOf(obj); owner == nil || owner.UID != "gw-uid" || owner.Kind != "Gateway" {
+					t.Errorf("%s %s is controlled by %v, want the gateway", gvk.Kind, obj.GetName(), owner)
+				}
+			}
+			slices.Sort(kinds)
+			if !slices.Equal(kinds, tt.wantKinds) {
+				t.Errorf("rendered kinds = %v, want %v", kinds, tt.wantKinds)
+			}
+		})
+	}
+}
+
+func TestRenderProxyChart(t *testing.T) {
+	tests := []struct {
+		name           string
+		kube           *v1alpha1.KubernetesProxyConfig
+		istio          bool
+		wantContainers []string
+		wantReplicas   *int32
+	}{
+		{name: "default", wantContainers: []string{"fgateway-proxy"}},
+		{
+			name:           "sds and replicas",
+			kube:           &v1alpha1.KubernetesProxyConfig{SdsContainer: &v1alpha1.SdsContainer{}, Deployment: &v1alpha1.ProxyDeployment{Replicas: ptr.To[uint32](2)}},
+			wantContainers: []string{"fgateway-proxy", "sds"},
+			wantReplicas:   ptr.To[int32](2),
+		},
+		{
+			name:           "istio",
+			kube:           &v1alpha1.KubernetesProxyConfig{SdsContainer: &v1alpha1.SdsContainer{}},
+			istio:          true,
+			wantContainers: []string{"fgateway-proxy", "sds", "istio-proxy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := testDeployer(t, &Inputs{IstioIntegrationEnabled: tt.istio}, &v1alpha1.GatewayParameters{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "default"},
+				Spec:       v1alpha1.GatewayParametersSpec{Kube: tt.kube},
+			})
+			gw := testGateway("")
+			// a privileged port is bound by the proxy with an offset
+			gw.Spec.Listeners = append(gw.Spec.Listeners, api.Listener{Name: "https", Port: 443, Protocol: api.HTTPProtocolType})
+			objs, _, err := d.GetObjsToDeploy(context.Background(), gw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var (
+				deployment *appsv1.Deployment
+				service    *corev1.Service
+				configMap  *corev1.ConfigMap
+			)
+			for _, obj := range objs {
+				switch obj := obj.(type) {
+				case *appsv1.Deployment:
+					deployment = obj
+				case *corev1.Service:
+					service = obj
+				case *corev1.ConfigMap:
+					configMap = obj
+				}
+			}
+			if deployment == nil || service == nil || configMap == nil {
+				t.Fatalf("rendered objects = %v, want a Deployment, a Service and a ConfigMap", objs)
+			}
+
+			if !reflect.DeepEqual(deployment.Spec.Replicas, tt.wantReplicas) {
+				t.Errorf("replicas = %v, want %v", deployment.Spec.Replicas, tt.wantReplicas)
+			}
+			var containers []string
+			for _, c := range deployment.Spec.Template.Spec.Containers {
+				containers = append(containers, c.Name)
+			}
+			if !slices.Equal(containers, tt.wantContainers) {
+				t.Fatalf("containers = %v, want %v", containers, tt.wantContainers)
+			}
+			envoy := deployment.Spec.Template.Spec.Containers[0]
+			if !strings.Contains(envoy.Image, "envoyproxy/envoy") {
+				t.Errorf("envoy image = %q", envoy.Image)
+			}
+			var containerPorts []int32
+			for _, p := range envoy.Ports {
+				containerPorts = append(containerPorts, p.ContainerPort)
+			}
+			if want := []int32{8080, 8443}; !slices.Equal(containerPorts[:min(len(containerPorts), 2)], want) {
+				t.Errorf("container ports = %v, want %v first", containerPorts, want)
+			}
+
+			type servicePort struct {
+				port, target int32
+			}
+			var servicePorts []servicePort
+			for _, p := range service.Spec.Ports {
+				servicePorts = append(servicePorts, servicePort{p.Port, p.TargetPort.IntVal})
+			}
+			if want := []servicePort{{8080, 8080}, {443, 8443}}; !slices.Equal(servicePorts, want) {
+				t.Errorf("service ports = %v, want %v", servicePorts, want)
+			}
+
+			bootstrap := configMap.Data["envoy.json"]
+			if !json.Valid([]byte(bootstrap)) || !strings.Contains(bootstrap, "fgateway.system.svc") {
+				t.Errorf("envoy.json = %s, want the bootstrap pointing at the control plane", bootstrap)
+			}
+		})
+	}
+}
+
+func TestInventory(t *testing.T) {
+	d, _ := testDeployer(t, &Inputs{})
+
+	// every template must render an object with the inventory values, or the kind of its objects may be missing from
+	// the inventory
+	release, err := prepareInstallAction(setupHelmStorage("default"), "inventory", "default").
+		RunWithContext(context.Background(), d.chart, inventoryValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tmpl := range d.chart.Templates {
+		if strings.HasPrefix(path.Base(tmpl.Name), "_") {
+			continue
+		}
+		if source := "# Source: " + d.chart.Name() + "/" + tmpl.Name + "\n"; !strings.Contains(release.Manifest, source) {
+			t.Errorf("template %s renders no object with the inventory values", tmpl.Name)
+		}
+	}
+
+	var kinds []string
+	for _, gvk := range d.inventory {
+		kinds = append(kinds, gvk.Kind)
+	}
+	slices.Sort(kinds)
+	if want := []string{"ConfigMap", "Deployment", "Service", "ServiceAccount"}; !slices.Equal(kinds, want) {
+		t.Errorf("inventory = %v, want %v", kinds, want)
+	}
+}
@@ -457,6 +457,8 @@ func deepMergeServiceAccount(dst, src *v1alpha1.ServiceAccount) *v1alpha1.Servic
 		return src
 	}
 
+	dst.Create = mergePointers(dst.GetCreate(), src.GetCreate())
+	dst.Name = mergePointers(dst.GetName(), src.GetName())
 	dst.ExtraLabels = deepMergeMaps(dst.GetExtraLabels(), src.GetExtraLabels())
 	dst.ExtraAnnotations = deepMergeMaps(dst.GetExtraAnnotations(), src.GetExtraAnnotations())
 
@@ -629,6 +631,10 @@ func deepMergeEnvoyBootstrap(dst, src *v1alpha1.EnvoyBootstrap) *v1alpha1.EnvoyB
 
 	dst.ComponentLogLevels = deepMergeMaps(dst.GetComponentLogLevels(), src.GetComponentLogLevels())
 
+	if src.GetXdsProtocol() != nil {
+		dst.XdsProtocol = src.GetXdsProtocol()
+	}
+
 	return dst
 }
 
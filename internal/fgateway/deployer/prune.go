@@ -0,0 +1,99 @@
+package deployer
+
+import (
+	"context"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// inventoryKey identifies a deployed object within a Gateway's namespace
+type inventoryKey struct {
+	gvk  schema.GroupVersionKind
+	name string
+}
+
+// PruneObjs deletes the objects previously deployed for the Gateway that are no longer part of the
+// rendered set, e.g. after a GatewayParameters change stopped creating the ServiceAccount of the proxy or
+// switched the Gateway to self-managed. The last-applied set is found by listing every GVK the chart can
+// render for objects carrying the Gateway's ownership label and controller reference.
+func (d *Deployer) PruneObjs(ctx context.Context, gw *api.Gateway, rendered []client.Object) error {
+	logger := d.logger(ctx)
+
+	desired := make(map[inventoryKey]struct{}, len(rendered))
+	for _, obj := range rendered {
+		desired[inventoryKey{gvk: obj.GetObjectKind().GroupVersionKind(), name: obj.GetName()}] = struct{}{}
+	}
+
+	for _, gvk := range d.inventory {
+		deployed, err := d.listDeployed(ctx, gw, gvk)
+		if err != nil {
+			return err
+		}
+		for _, obj := range deployed {
+			if _, ok := desired[inventoryKey{gvk: gvk, name: obj.GetName()}]; ok {
+				continue
+			}
+			logger.Info("pruning object no longer rendered for gateway",
+				"kind", gvk.Kind,
+				"namespace", obj.GetNamespace(),
+				"name", obj.GetName())
+			err := d.cli.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if client.IgnoreNotFound(err) != nil {
+				return errors.Wrapf(err, "failed to prune object %s %s.%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// listDeployed returns the objects of the given GVK that were deployed for, and are still controlled by, the Gateway
+func (d *Deployer) listDeployed(ctx context.Context, gw *api.Gateway, gvk schema.GroupVersionKind) ([]client.Object, error) {
+	listObj, err := d.cli.Scheme().New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	list, ok := listObj.(client.ObjectList)
+	if !ok {
+		return nil, errors.Errorf("object %T is not a client.ObjectList", listObj)
+	}
+	err = d.cli.List(ctx, list,
+		client.InNamespace(gw.GetNamespace()),
+		client.MatchingLabels{wellknown.GatewayOwnerLabel: string(gw.GetUID())},
+	)
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s for gateway %s.%s", gvk.Kind, gw.GetNamespace(), gw.GetName())
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var objs []client.Object
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		if !isControlledBy(obj, gw.GetUID()) {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+func isControlledBy(obj client.Object, uid types.UID) bool {
+	controller := metav1.GetControllerOf(obj)
+	return controller != nil && controller.UID == uid
+}
@@ -0,0 +1,174 @@
+package deployer
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+var (
+	configMapGVK = corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	serviceGVK   = corev1.SchemeGroupVersion.WithKind("Service")
+)
+
+// deployedObject returns an object of the Gateway as the deployer applies them, or of another owner
+func deployedObject(obj client.Object, name string, owner types.UID) client.Object {
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetLabels(map[string]string{wellknown.GatewayOwnerLabel: "gw-uid"})
+	obj.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: api.GroupVersion.String(),
+		Kind:       "Gateway",
+		Name:       "gw",
+		UID:        owner,
+		Controller: ptr.To(true),
+	}})
+	return obj
+}
+
+func renderedObject(gvk schema.GroupVersionKind, name string) client.Object {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	return obj
+}
+
+func TestPruneObjs(t *testing.T) {
+	gw := &api.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw", UID: "gw-uid"}}
+	tests := []struct {
+		name     string
+		deployed []client.Object
+		rendered []client.Object
+		// kept are the kind/name of the objects left after pruning
+		kept []string
+	}{
+		{
+			name: "objects no longer rendered are pruned",
+			deployed: []client.Object{
+				deployedObject(&corev1.ConfigMap{}, "keep", "gw-uid"),
+				deployedObject(&corev1.ConfigMap{}, "stale", "gw-uid"),
+				deployedObject(&corev1.Service{}, "stale-svc", "gw-uid"),
+			},
+			rendered: []client.Object{renderedObject(configMapGVK, "keep")},
+			kept:     []string{"ConfigMap/keep"},
+		},
+		{
+			name: "an object of the same name and another kind is pruned",
+			deployed: []client.Object{
+				deployedObject(&corev1.ConfigMap{}, "proxy", "gw-uid"),
+				deployedObject(&corev1.Service{}, "proxy", "gw-uid"),
+			},
+			rendered: []client.Object{renderedObject(serviceGVK, "proxy")},
+			kept:     []string{"Service/proxy"},
+		},
+		{
+			name: "objects controlled by another owner are kept",
+			deployed: []client.Object{
+				deployedObject(&corev1.ConfigMap{}, "foreign", "other-uid"),
+			},
+			kept: []string{"ConfigMap/foreign"},
+		},
+		{
+			name: "nothing rendered prunes every object of the gateway",
+			deployed: []client.Object{
+				deployedObject(&corev1.ConfigMap{}, "cm", "gw-uid"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(tt.deployed...).Build()
+			d := &Deployer{cli: cli, inventory: []schema.GroupVersionKind{configMapGVK, serviceGVK}}
+			if err := d.PruneObjs(context.Background(), gw, tt.rendered); err != nil {
+				t.Fatal(err)
+			}
+
+			var cms corev1.ConfigMapList
+			var svcs corev1.ServiceList
+			if err := cli.List(context.Background(), &cms); err != nil {
+				t.Fatal(err)
+			}
+			if err := cli.List(context.Background(), &svcs); err != nil {
+				t.Fatal(err)
+			}
+			var kept []string
+			for _, cm := range cms.Items {
+				kept = append(kept, "ConfigMap/"+cm.Name)
+			}
+			for _, svc := range svcs.Items {
+				kept = append(kept, "Service/"+svc.Name)
+			}
+			if !slices.Equal(kept, tt.kept) {
+				t.Errorf("kept %v, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
+
+func TestPruneDroppedServiceAccount(t *testing.T) {
+	d, cli := testDeployer(t, &Inputs{},
+		&v1alpha1.GatewayParameters{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: "default"},
+			Spec:       v1alpha1.GatewayParametersSpec{Kube: &v1alpha1.KubernetesProxyConfig{}},
+		},
+		&v1alpha1.GatewayParameters{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "existing-sa"},
+			Spec: v1alpha1.GatewayParametersSpec{Kube: &v1alpha1.KubernetesProxyConfig{
+				ServiceAccount: &v1alpha1.ServiceAccount{Create: ptr.To(false), Name: ptr.To("proxy")},
+			}},
+		},
+	)
+	ctx := context.Background()
+	rendered, _, err := d.GetObjsToDeploy(ctx, testGateway(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range rendered {
+		if err := cli.Create(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the gateway switches to parameters that stop rendering the ServiceAccount of its proxy
+	rendered, _, err = d.GetObjsToDeploy(ctx, testGateway("existing-sa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range rendered {
+		if deployment, ok := obj.(*appsv1.Deployment); ok && deployment.Spec.Template.Spec.ServiceAccountName != "proxy" {
+			t.Errorf("service account name = %q, want the existing one", deployment.Spec.Template.Spec.ServiceAccountName)
+		}
+	}
+	if err := d.PruneObjs(ctx, testGateway("existing-sa"), rendered); err != nil {
+		t.Fatal(err)
+	}
+
+	var serviceAccounts corev1.ServiceAccountList
+	var deployments appsv1.DeploymentList
+	if err := cli.List(ctx, &serviceAccounts); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.List(ctx, &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if len(serviceAccounts.Items) != 0 {
+		t.Errorf("service accounts = %v, want the one no longer rendered pruned", serviceAccounts.Items)
+	}
+	if len(deployments.Items) != 1 {
+		t.Errorf("deployments = %v, want the proxy deployment kept", deployments.Items)
+	}
+}
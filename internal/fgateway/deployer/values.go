@@ -1,8 +1,153 @@
 package deployer
 
+import (
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The top-level helm values used by the deployer.
 type helmConfig struct {
 	Gateway *helmGateway `json:"gateway,omitempty"`
 }
 
 type helmGateway struct {
+	// not needed by the helm charts, but by the code that uses the values
+	Name             *string `json:"name,omitempty"`
+	GatewayName      *string `json:"gatewayName,omitempty"`
+	GatewayNamespace *string `json:"gatewayNamespace,omitempty"`
+	NameOverride     *string `json:"nameOverride,omitempty"`
+	FullnameOverride *string `json:"fullnameOverride,omitempty"`
+
+	// deployment values
+	ReplicaCount *uint32 `json:"replicaCount,omitempty"`
+
+	// service values
+	Ports   []helmPort   `json:"ports,omitempty"`
+	Service *helmService `json:"service,omitempty"`
+
+	// serviceaccount values
+	ServiceAccount *helmServiceAccount `json:"serviceAccount,omitempty"`
+
+	// pod template values
+	ExtraPodAnnotations           map[string]string              `json:"extraPodAnnotations,omitempty"`
+	ExtraPodLabels                map[string]string              `json:"extraPodLabels,omitempty"`
+	ImagePullSecrets              []corev1.LocalObjectReference  `json:"imagePullSecrets,omitempty"`
+	PodSecurityContext            *corev1.PodSecurityContext     `json:"podSecurityContext,omitempty"`
+	NodeSelector                  map[string]string              `json:"nodeSelector,omitempty"`
+	Affinity                      *corev1.Affinity               `json:"affinity,omitempty"`
+	Tolerations                   []corev1.Toleration            `json:"tolerations,omitempty"`
+	ReadinessProbe                *corev1.Probe                  `json:"readinessProbe,omitempty"`
+	LivenessProbe                 *corev1.Probe                  `json:"livenessProbe,omitempty"`
+	GracefulShutdown              *v1alpha1.GracefulShutdownSpec `json:"gracefulShutdown,omitempty"`
+	TerminationGracePeriodSeconds *int                           `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// envoy container values
+	Bootstrap       *helmBootstrap               `json:"bootstrap,omitempty"`
+	Image           *helmImage                   `json:"image,omitempty"`
+	Resources       *corev1.ResourceRequirements `json:"resources,omitempty"`
+	SecurityContext *corev1.SecurityContext      `json:"securityContext,omitempty"`
+
+	// sds container values
+	SdsContainer *helmSdsContainer `json:"sdsContainer,omitempty"`
+
+	// istio values
+	Istio          *helmIstio          `json:"istio,omitempty"`
+	IstioContainer *helmIstioContainer `json:"istioContainer,omitempty"`
+
+	// stats values
+	Stats *helmStatsConfig `json:"stats,omitempty"`
+
+	// ai extension values
+	AIExtension *helmAIExtension `json:"aiExtension,omitempty"`
+
+	// aws values
+	Aws *helmAws `json:"aws,omitempty"`
+}
+
+// helmPort represents a Gateway Listener port
+type helmPort struct {
+	Port       *uint16 `json:"port,omitempty"`
+	TargetPort *uint16 `json:"targetPort,omitempty"`
+	Protocol   *string `json:"protocol,omitempty"`
+	Name       *string `json:"name,omitempty"`
+}
+
+// helmBootstrap is the generated envoy bootstrap config and the command line the envoy container runs with
+type helmBootstrap struct {
+	Config *string  `json:"config,omitempty"`
+	Args   []string `json:"args,omitempty"`
+}
+
+type helmImage struct {
+	Registry   *string `json:"registry,omitempty"`
+	Repository *string `json:"repository,omitempty"`
+	Tag        *string `json:"tag,omitempty"`
+	Digest     *string `json:"digest,omitempty"`
+	PullPolicy *string `json:"pullPolicy,omitempty"`
+}
+
+type helmService struct {
+	Type             *string           `json:"type,omitempty"`
+	ClusterIP        *string           `json:"clusterIP,omitempty"`
+	LoadBalancerIP   *string           `json:"loadBalancerIP,omitempty"`
+	ExtraAnnotations map[string]string `json:"extraAnnotations,omitempty"`
+	ExtraLabels      map[string]string `json:"extraLabels,omitempty"`
+}
+
+type helmServiceAccount struct {
+	Create           *bool             `json:"create,omitempty"`
+	Name             *string           `json:"name,omitempty"`
+	ExtraAnnotations map[string]string `json:"extraAnnotations,omitempty"`
+	ExtraLabels      map[string]string `json:"extraLabels,omitempty"`
+}
+
+type helmIstio struct {
+	Enabled        *bool              `json:"enabled,omitempty"`
+	CustomSidecars []corev1.Container `json:"customSidecars,omitempty"`
+}
+
+type helmSdsContainer struct {
+	Image           *helmImage                   `json:"image,omitempty"`
+	Resources       *corev1.ResourceRequirements `json:"resources,omitempty"`
+	SecurityContext *corev1.SecurityContext      `json:"securityContext,omitempty"`
+	SdsBootstrap    *sdsBootstrap                `json:"sdsBootstrap,omitempty"`
+}
+
+type sdsBootstrap struct {
+	LogLevel *string `json:"logLevel,omitempty"`
+}
+
+type helmIstioContainer struct {
+	Image           *helmImage                   `json:"image,omitempty"`
+	LogLevel        *string                      `json:"logLevel,omitempty"`
+	Resources       *corev1.ResourceRequirements `json:"resources,omitempty"`
+	SecurityContext *corev1.SecurityContext      `json:"securityContext,omitempty"`
+
+	IstioDiscoveryAddress *string `json:"istioDiscoveryAddress,omitempty"`
+	IstioMetaMeshId       *string `json:"istioMetaMeshId,omitempty"`
+	IstioMetaClusterId    *string `json:"istioMetaClusterId,omitempty"`
+}
+
+type helmStatsConfig struct {
+	Enabled            *bool   `json:"enabled,omitempty"`
+	RoutePrefixRewrite *string `json:"routePrefixRewrite,omitempty"`
+	EnableStatsRoute   *bool   `json:"enableStatsRoute,omitempty"`
+	StatsPrefixRewrite *string `json:"statsPrefixRewrite,omitempty"`
+}
+
+type helmAIExtension struct {
+	Enabled         bool                         `json:"enabled,omitempty"`
+	Image           *helmImage                   `json:"image,omitempty"`
+	SecurityContext *corev1.SecurityContext      `json:"securityContext,omitempty"`
+	Resources       *corev1.ResourceRequirements `json:"resources,omitempty"`
+	Env             []corev1.EnvVar              `json:"env,omitempty"`
+	Ports           []corev1.ContainerPort       `json:"ports,omitempty"`
+	// Stats is the JSON encoded stats configuration passed to the extension
+	Stats *string `json:"stats,omitempty"`
+}
+
+type helmAws struct {
+	EnableServiceAccountCredentials *bool   `json:"enableServiceAccountCredentials,omitempty"`
+	StsClusterName                  *string `json:"stsClusterName,omitempty"`
+	StsUri                          *string `json:"stsUri,omitempty"`
 }
@@ -0,0 +1,259 @@
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	"github.com/fleezesd/fgateway/internal/fgateway/ports"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// This file contains helper functions that generate helm values in the format needed
+// by the deployer.
+
+var portNameInvalidChars = regexp.MustCompile(`[^a-z0-9-]`)
+
+// Extract the listener ports from a Gateway. These will be used to populate:
+// 1. the ports exposed on the envoy container
+// 2. the ports exposed on the proxy service
+// The ports the proxy can not bind, as they translate to the port of another listener, are left out.
+func getPortsValues(gw *api.Gateway) []helmPort {
+	gwPorts := []helmPort{}
+	for _, l := range gw.Spec.Listeners {
+		listenerPort := uint16(l.Port)
+		protocol := string(ports.Protocol(l.Protocol))
+		if ports.Shadowed(listenerPort, listenerPorts(gw, ports.Protocol(l.Protocol))) {
+			continue
+		}
+
+		// only process this port if we haven't already processed a listener with the same port and protocol
+		if slices.IndexFunc(gwPorts, func(p helmPort) bool { return *p.Port == listenerPort && *p.Protocol == protocol }) != -1 {
+			continue
+		}
+
+		targetPort := ports.TranslatePort(listenerPort)
+		portName := uniquePortName(gwPorts, sanitizePortName(string(l.Name)), listenerPort)
+
+		gwPorts = append(gwPorts, helmPort{
+			Port:       &listenerPort,
+			TargetPort: &targetPort,
+			Name:       &portName,
+			Protocol:   &protocol,
+		})
+	}
+	return gwPorts
+}
+
+// listenerPorts returns the ports of the listeners of the Gateway bound with the protocol
+func listenerPorts(gw *api.Gateway, protocol corev1.Protocol) []uint16 {
+	var ret []uint16
+	for _, l := range gw.Spec.Listeners {
+		if ports.Protocol(l.Protocol) == protocol {
+			ret = append(ret, uint16(l.Port))
+		}
+	}
+	return ret
+}
+
+// sanitizePortName turns a listener name into a valid service port name (lowercase alphanumerics and '-',
+// at most 15 characters, not starting or ending with '-')
+func sanitizePortName(name string) string {
+	sanitized := portNameInvalidChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(sanitized) > 15 {
+		sanitized = sanitized[:15]
+	}
+	sanitized = strings.Trim(sanitized, "-")
+	if sanitized == "" {
+		return "listener"
+	}
+	return sanitized
+}
+
+// uniquePortName returns name, or name suffixed with the port when a port already has it, as the names of the ports
+// of a Service must be unique and sanitizePortName truncates the names of different listeners to the same one
+func uniquePortName(gwPorts []helmPort, name string, port uint16) string {
+	taken := func(n string) bool {
+		return slices.ContainsFunc(gwPorts, func(p helmPort) bool { return *p.Name == n })
+	}
+	candidate := name
+	for i := 0; taken(candidate); i++ {
+		suffix := fmt.Sprintf("-%d", port)
+		if i > 0 {
+			suffix = fmt.Sprintf("-%d-%d", port, i)
+		}
+		candidate = strings.TrimRight(name[:min(len(name), 15-len(suffix))], "-") + suffix
+	}
+	return candidate
+}
+
+// Convert service values from GatewayParameters into helm values to be used by the deployer.
+func getServiceValues(svcConfig *v1alpha1.Service) *helmService {
+	// an unset type is left to the chart, which defaults it to LoadBalancer
+	var svcType *string
+	if svcConfig.GetType() != nil {
+		svcType = ptr.To(string(*svcConfig.GetType()))
+	}
+	return &helmService{
+		Type:             svcType,
+		ClusterIP:        svcConfig.GetClusterIP(),
+		ExtraAnnotations: svcConfig.GetExtraAnnotations(),
+		ExtraLabels:      svcConfig.GetExtraLabels(),
+	}
+}
+
+// Convert service account values from GatewayParameters into helm values to be used by the deployer.
+func getServiceAccountValues(svcAccountConfig *v1alpha1.ServiceAccount) *helmServiceAccount {
+	return &helmServiceAccount{
+		Create:           svcAccountConfig.GetCreate(),
+		Name:             svcAccountConfig.GetName(),
+		ExtraAnnotations: svcAccountConfig.GetExtraAnnotations(),
+		ExtraLabels:      svcAccountConfig.GetExtraLabels(),
+	}
+}
+
+// Convert sds values from GatewayParameters into helm values to be used by the deployer.
+func getSdsContainerValues(sdsContainerConfig *v1alpha1.SdsContainer) *helmSdsContainer {
+	if sdsContainerConfig == nil {
+		return nil
+	}
+
+	vals := &helmSdsContainer{
+		Image:           getImageValues(sdsContainerConfig.GetImage()),
+		Resources:       sdsContainerConfig.GetResources(),
+		SecurityContext: sdsContainerConfig.GetSecurityContext(),
+		SdsBootstrap:    &sdsBootstrap{},
+	}
+
+	if bootstrap := sdsContainerConfig.GetBootstrap(); bootstrap != nil {
+		vals.SdsBootstrap = &sdsBootstrap{
+			LogLevel: bootstrap.GetLogLevel(),
+		}
+	}
+
+	return vals
+}
+
+// Convert istio proxy container values from GatewayParameters into helm values to be used by the deployer.
+func getIstioContainerValues(istioContainerConfig *v1alpha1.IstioContainer) *helmIstioContainer {
+	if istioContainerConfig == nil {
+		return nil
+	}
+
+	return &helmIstioContainer{
+		Image:                 getImageValues(istioContainerConfig.GetImage()),
+		LogLevel:              istioContainerConfig.GetLogLevel(),
+		Resources:             istioContainerConfig.GetResources(),
+		SecurityContext:       istioContainerConfig.GetSecurityContext(),
+		IstioDiscoveryAddress: istioContainerConfig.GetIstioDiscoveryAddress(),
+		IstioMetaMeshId:       istioContainerConfig.GetIstioMetaMeshId(),
+		IstioMetaClusterId:    istioContainerConfig.GetIstioMetaClusterId(),
+	}
+}
+
+// Get the image values for the envoy, sds, istio-proxy or ai extension container.
+func getImageValues(image *v1alpha1.Image) *helmImage {
+	if image == nil {
+		return &helmImage{}
+	}
+
+	helmImage := &helmImage{
+		Registry:   image.GetRegistry(),
+		Repository: image.GetRepository(),
+		Tag:        image.GetTag(),
+		Digest:     image.GetDigest(),
+	}
+	if image.GetPullPolicy() != nil {
+		helmImage.PullPolicy = ptr.To(string(*image.GetPullPolicy()))
+	}
+
+	return helmImage
+}
+
+// Get the stats values for the envoy listener in the configmap for bootstrap.
+func getStatsValues(statsConfig *v1alpha1.StatsConfig) *helmStatsConfig {
+	if statsConfig == nil {
+		return nil
+	}
+	return &helmStatsConfig{
+		Enabled:            statsConfig.GetEnabled(),
+		RoutePrefixRewrite: statsConfig.GetRoutePrefixRewrite(),
+		EnableStatsRoute:   statsConfig.GetEnableStatsRoute(),
+		StatsPrefixRewrite: statsConfig.GetStatsRoutePrefixRewrite(),
+	}
+}
+
+// ComponentLogLevelsToString converts the key-value pairs in the map into a string of the
+// format: key1:value1,key2:value2,key3:value3, where the keys are sorted alphabetically.
+// If an empty map is passed in, then an empty string is returned.
+// Map keys and values may not be empty.
+// No other validation is currently done on the keys/values.
+func ComponentLogLevelsToString(vals map[string]string) (string, error) {
+	if len(vals) == 0 {
+		return "", nil
+	}
+
+	parts := make([]string, 0, len(vals))
+	for k, v := range vals {
+		if k == "" {
+			return "", ComponentLogLevelEmptyKeyError(v)
+		}
+		if v == "" {
+			return "", ComponentLogLevelEmptyValueError(k)
+		}
+		parts = append(parts, fmt.Sprintf("%s:%s", k, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ","), nil
+}
+
+// Convert AI extension values from GatewayParameters into helm values to be used by the deployer.
+func getAIExtensionValues(config *v1alpha1.AiExtension) (*helmAIExtension, error) {
+	if config == nil || !lo.FromPtr(config.GetEnabled()) {
+		return nil, nil
+	}
+
+	vals := &helmAIExtension{
+		Enabled:         true,
+		Image:           getImageValues(config.GetImage()),
+		SecurityContext: config.GetSecurityContext(),
+		Resources:       config.GetResources(),
+		Env:             config.GetEnv(),
+		Ports:           config.GetPorts(),
+	}
+
+	if stats := config.GetStats(); stats != nil {
+		b, err := json.Marshal(stats)
+		if err != nil {
+			return nil, err
+		}
+		vals.Stats = ptr.To(string(b))
+	}
+
+	return vals, nil
+}
+
+// applyFloatingUserId removes the user id from every security context of the proxy pod so that
+// the platform (e.g. OpenShift) can assign one.
+func applyFloatingUserId(vals *helmGateway) {
+	if vals.PodSecurityContext != nil {
+		vals.PodSecurityContext.RunAsUser = nil
+	}
+	for _, sc := range []*corev1.SecurityContext{
+		vals.SecurityContext,
+		lo.FromPtr(vals.SdsContainer).SecurityContext,
+		lo.FromPtr(vals.IstioContainer).SecurityContext,
+		lo.FromPtr(vals.AIExtension).SecurityContext,
+	} {
+		if sc != nil {
+			sc.RunAsUser = nil
+		}
+	}
+}
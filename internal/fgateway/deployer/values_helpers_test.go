@@ -0,0 +1,153 @@
+package deployer
+
+import (
+	"testing"
+
+	"github.com/fleezesd/fgateway/apis/fgateway/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestSanitizePortName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid", in: "http", want: "http"},
+		{name: "uppercase", in: "HTTP", want: "http"},
+		{name: "invalid characters", in: "http.api_v1", want: "http-api-v1"},
+		{name: "truncated", in: "listener-https-public", want: "listener-https"},
+		{name: "trailing dash after truncation", in: "listener-https--x", want: "listener-https"},
+		{name: "leading dash", in: "-http", want: "http"},
+		{name: "only invalid characters", in: "...", want: "listener"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizePortName(tt.in); got != tt.want {
+				t.Errorf("sanitizePortName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPortsValues(t *testing.T) {
+	type port struct {
+		port, targetPort uint16
+		name, protocol   string
+	}
+	tests := []struct {
+		name      string
+		listeners []api.Listener
+		want      []port
+	}{
+		{
+			name: "privileged ports are translated",
+			listeners: []api.Listener{
+				{Name: "http", Port: 80, Protocol: api.HTTPProtocolType},
+				{Name: "alt", Port: 8443, Protocol: api.HTTPSProtocolType},
+			},
+			want: []port{
+				{port: 80, targetPort: 8080, name: "http", protocol: "TCP"},
+				{port: 8443, targetPort: 8443, name: "alt", protocol: "TCP"},
+			},
+		},
+		{
+			name: "listeners sharing a port share the service port",
+			listeners: []api.Listener{
+				{Name: "a", Port: 80, Protocol: api.HTTPProtocolType},
+				{Name: "b", Port: 80, Protocol: api.HTTPProtocolType},
+			},
+			want: []port{
+				{port: 80, targetPort: 8080, name: "a", protocol: "TCP"},
+			},
+		},
+		{
+			name: "port translated to the port of another listener is left out",
+			listeners: []api.Listener{
+				{Name: "http", Port: 80, Protocol: api.HTTPProtocolType},
+				{Name: "alt", Port: 8080, Protocol: api.HTTPProtocolType},
+			},
+			want: []port{
+				{port: 8080, targetPort: 8080, name: "alt", protocol: "TCP"},
+			},
+		},
+		{
+			name: "udp listener",
+			listeners: []api.Listener{
+				{Name: "dns", Port: 53, Protocol: api.UDPProtocolType},
+			},
+			want: []port{
+				{port: 53, targetPort: 8053, name: "dns", protocol: "UDP"},
+			},
+		},
+		{
+			name: "tcp and udp listeners sharing a port",
+			listeners: []api.Listener{
+				{Name: "dns", Port: 53, Protocol: api.TCPProtocolType},
+				{Name: "dns", Port: 53, Protocol: api.UDPProtocolType},
+			},
+			want: []port{
+				{port: 53, targetPort: 8053, name: "dns", protocol: "TCP"},
+				{port: 53, targetPort: 8053, name: "dns-53", protocol: "UDP"},
+			},
+		},
+		{
+			name: "names truncated to the same name are suffixed with the port",
+			listeners: []api.Listener{
+				{Name: "listener-https-a", Port: 443, Protocol: api.HTTPSProtocolType},
+				{Name: "listener-https-b", Port: 9443, Protocol: api.HTTPSProtocolType},
+			},
+			want: []port{
+				{port: 443, targetPort: 8443, name: "listener-https", protocol: "TCP"},
+				{port: 9443, targetPort: 9443, name: "listener-h-9443", protocol: "TCP"},
+			},
+		},
+		{
+			name: "suffixed name taken by another listener",
+			listeners: []api.Listener{
+				{Name: "web", Port: 80, Protocol: api.HTTPProtocolType},
+				{Name: "web-81", Port: 82, Protocol: api.HTTPProtocolType},
+				{Name: "web", Port: 81, Protocol: api.HTTPProtocolType},
+			},
+			want: []port{
+				{port: 80, targetPort: 8080, name: "web", protocol: "TCP"},
+				{port: 82, targetPort: 8082, name: "web-81", protocol: "TCP"},
+				{port: 81, targetPort: 8081, name: "web-81-1", protocol: "TCP"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getPortsValues(&api.Gateway{Spec: api.GatewaySpec{Listeners: tt.listeners}})
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d ports, want %d", len(got), len(tt.want))
+			}
+			names := map[string]bool{}
+			for i, p := range got {
+				gotPort := port{port: *p.Port, targetPort: *p.TargetPort, name: *p.Name, protocol: *p.Protocol}
+				if gotPort != tt.want[i] {
+					t.Errorf("port %d = %+v, want %+v", i, gotPort, tt.want[i])
+				}
+				if names[*p.Name] {
+					t.Errorf("port name %q is not unique", *p.Name)
+				}
+				names[*p.Name] = true
+				if len(*p.Name) > 15 {
+					t.Errorf("port name %q is longer than 15 characters", *p.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetServiceValues(t *testing.T) {
+	if got := getServiceValues(&v1alpha1.Service{}); got.Type != nil {
+		t.Errorf("unset type = %q, want it left to the chart", *got.Type)
+	}
+	got := getServiceValues(&v1alpha1.Service{Type: ptr.To(corev1.ServiceTypeNodePort)})
+	if got.Type == nil || *got.Type != "NodePort" {
+		t.Errorf("type = %v, want NodePort", got.Type)
+	}
+}
@@ -7,6 +7,18 @@ type Settings struct {
 	EnableAutoMTLS         bool
 	StsClusterName         string
 	StsUri                 string
+
+	// ConflictBackOffKinds lists the kinds, formatted as `Kind.group` (e.g. `Deployment.apps`, `Service`),
+	// for which the deployer backs off instead of forcing ownership on server-side apply conflicts.
+	ConflictBackOffKinds []string
+
+	// ExtraGatewayClasses lists the names of GatewayClasses, besides the default one, whose Gateways are
+	// handled by this controller regardless of the controllerName of the class.
+	ExtraGatewayClasses []string
+
+	// AdminAddress is the address the admin server binds to, the loopback interface when empty. The admin server is
+	// not authenticated, and changes the log levels.
+	AdminAddress string
 }
 
 func BuildSettings() (*Settings, error) {
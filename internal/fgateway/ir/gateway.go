@@ -0,0 +1,117 @@
+package ir
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// GatewayIR is the xds configuration translated from a Gateway and the routes attached to it.
+// It is shared by the proxies of the Gateway, which connect with Role as their node role, whose pod labels match
+// the same ProxySelectors of the routes.
+type GatewayIR struct {
+	Role string
+	// ProxySelectors are the distinct label selectors restricting routes of the Gateway to some of its proxies
+	ProxySelectors []string
+	// Variant is the set of ProxySelectors matching the labels of the proxies served this configuration, the bit i
+	// being set when ProxySelectors[i] matches
+	Variant uint32
+
+	Listeners []*envoy_config_listener_v3.Listener
+	Routes    []*envoy_config_route_v3.RouteConfiguration
+	Clusters  []*envoy_config_cluster_v3.Cluster
+	Secrets   []*envoy_extensions_transport_sockets_tls_v3.Secret
+}
+
+// ResourceName is the role of the Gateway for the proxies matching none of the ProxySelectors, so that a proxy
+// finds the selectors of its Gateway by role
+func (g GatewayIR) ResourceName() string {
+	return GatewayVariantName(g.Role, g.Variant)
+}
+
+// GatewayVariantName is the resource name of the variant of the configuration of a Gateway
+func GatewayVariantName(role string, variant uint32) string {
+	if variant == 0 {
+		return role
+	}
+	return fmt.Sprintf("%s%s%d", role, KeyDelimiter, variant)
+}
+
+// MarshalJSON lists the secrets by name only, as they hold private keys
+func (g GatewayIR) MarshalJSON() ([]byte, error) {
+	type gatewayIR GatewayIR
+	secrets := make([]string, 0, len(g.Secrets))
+	for _, s := range g.Secrets {
+		secrets = append(secrets, s.GetName())
+	}
+	return json.Marshal(struct {
+		gatewayIR
+		Secrets []string
+	}{gatewayIR(g), secrets})
+}
+
+func (g GatewayIR) Equals(in GatewayIR) bool {
+	return g.Role == in.Role &&
+		slices.Equal(g.ProxySelectors, in.ProxySelectors) &&
+		g.Variant == in.Variant &&
+		protosEqual(g.Listeners, in.Listeners) &&
+		protosEqual(g.Routes, in.Routes) &&
+		protosEqual(g.Clusters, in.Clusters) &&
+		protosEqual(g.Secrets, in.Secrets)
+}
+
+// Endpoint is a ready address of a backend
+type Endpoint struct {
+	Address  string
+	Port     uint32
+	Locality LocalityPod
+}
+
+// BackendEndpoints are the endpoints of the EDS cluster named ClusterName
+type BackendEndpoints struct {
+	ClusterName string
+	Endpoints   []Endpoint
+}
+
+func (b BackendEndpoints) ResourceName() string {
+	return b.ClusterName
+}
+
+func (b BackendEndpoints) Equals(in BackendEndpoints) bool {
+	return b.ClusterName == in.ClusterName && slices.Equal(b.Endpoints, in.Endpoints)
+}
+
+// BackendTLS is the TLS origination to the backends of the cluster named ClusterName, configured by the
+// BackendTLSPolicy targeting its Service port
+type BackendTLS struct {
+	ClusterName string
+	// TransportSocket originates TLS to the backends and verifies their certificate
+	TransportSocket *envoy_config_core_v3.TransportSocket
+	// CACertificates is the SDS secret holding the CA certificates of the policy, nil when the backends are
+	// verified against the well-known CAs of the proxy
+	CACertificates *envoy_extensions_transport_sockets_tls_v3.Secret
+	// Error is set when the policy can not be applied, in which case the backends must not be reached at all
+	Error string
+}
+
+func (b BackendTLS) ResourceName() string {
+	return b.ClusterName
+}
+
+func (b BackendTLS) Equals(in BackendTLS) bool {
+	return b.ClusterName == in.ClusterName &&
+		b.Error == in.Error &&
+		proto.Equal(b.TransportSocket, in.TransportSocket) &&
+		proto.Equal(b.CACertificates, in.CACertificates)
+}
+
+func protosEqual[T proto.Message](a, b []T) bool {
+	return slices.EqualFunc(a, b, func(x, y T) bool { return proto.Equal(x, y) })
+}
@@ -15,11 +15,18 @@ type LocalityPod struct {
 }
 
 type UniqlyConnectedClient struct {
-	Role         string
-	Labels       map[string]string
-	Locality     LocalityPod
-	Namespace    string
-	ResourceName string
+	Role      string
+	Labels    map[string]string
+	Locality  LocalityPod
+	Namespace string
+
+	// resourceName is the key of the client: its role, and the hash of its labels and its namespace when known
+	resourceName string
+}
+
+// ResourceName is the key of the client in krt collections, and of its snapshot in the xds cache
+func (c UniqlyConnectedClient) ResourceName() string {
+	return c.resourceName
 }
 
 func NewUniqlyConnectedClient(roleFromEnvoy string, ns string, labels map[string]string, locality LocalityPod) UniqlyConnectedClient {
@@ -33,7 +40,7 @@ func NewUniqlyConnectedClient(roleFromEnvoy string, ns string, labels map[string
 		Labels:       labels,
 		Locality:     locality,
 		Namespace:    ns,
-		ResourceName: resourceName,
+		resourceName: resourceName,
 	}
 }
 
@@ -13,19 +13,33 @@ import (
 
 type callbacks struct {
 	collection atomic.Pointer[callbacksCollection]
+	nacks      *Nacks
 }
 
 // OnStreamClosed
 func (o *callbacks) OnStreamClosed(streamId int64, node *envoy_config_core_v3.Node) {
+	o.nacks.onStreamClosed(streamKey{id: streamId})
 	callbacksCollection := o.collection.Load()
 	if lo.IsNil(callbacksCollection) {
 		return
 	}
-	callbacksCollection.OnStreamClosed(streamId)
+	callbacksCollection.OnStreamClosed(streamKey{id: streamId})
+}
+
+// OnDeltaStreamClosed
+func (o *callbacks) OnDeltaStreamClosed(streamId int64, node *envoy_config_core_v3.Node) {
+	o.nacks.onStreamClosed(streamKey{id: streamId, delta: true})
+	callbacksCollection := o.collection.Load()
+	if lo.IsNil(callbacksCollection) {
+		return
+	}
+	callbacksCollection.OnStreamClosed(streamKey{id: streamId, delta: true})
 }
 
 // OnStreamRequest
 func (o *callbacks) OnStreamRequest(streamId int64, r *envoy_service_discovery_v3.DiscoveryRequest) error {
+	// before the role of the node is augmented, so that the rejections are recorded by gateway
+	o.nacks.onRequest(streamKey{id: streamId}, r.GetNode(), r.GetTypeUrl(), r.GetResponseNonce(), r.GetResourceNames(), r.GetErrorDetail())
 	// get role
 	role := GetRoleFromRequest(r)
 	// check gateway cache key if or not
@@ -36,7 +50,48 @@ func (o *callbacks) OnStreamRequest(streamId int64, r *envoy_service_discovery_v
 	if lo.IsNil(c) {
 		return errors.New("fgateway not initialized")
 	}
-	return c.OnStreamRequest(streamId, r)
+	return c.OnStreamRequest(streamKey{id: streamId}, r.GetNode())
+}
+
+// OnStreamDeltaRequest. The node is only set on the first request of a delta stream, later requests
+// reuse the node augmented here, so they are already tracked and have nothing to do
+func (o *callbacks) OnStreamDeltaRequest(streamId int64, r *envoy_service_discovery_v3.DeltaDiscoveryRequest) error {
+	o.nacks.onRequest(streamKey{id: streamId, delta: true}, r.GetNode(), r.GetTypeUrl(), r.GetResponseNonce(), nil, r.GetErrorDetail())
+	role := GetRoleFromNode(r.GetNode())
+	if !xds.IsKubeGatewayCacheKey(role) {
+		return nil
+	}
+	c := o.collection.Load()
+	if lo.IsNil(c) {
+		return errors.New("fgateway not initialized")
+	}
+	return c.OnStreamRequest(streamKey{id: streamId, delta: true}, r.GetNode())
+}
+
+// OnStreamResponse records the nonce and version of the response sent, whose resources are the rejected ones if the
+// proxy nacks it. The node of the request holds the resource name of the client by then.
+func (o *callbacks) OnStreamResponse(_ context.Context, streamId int64, req *envoy_service_discovery_v3.DiscoveryRequest, r *envoy_service_discovery_v3.DiscoveryResponse) {
+	o.nacks.onResponse(streamKey{id: streamId}, GetRoleFromRequest(req), r.GetTypeUrl(),
+		sentResponse{nonce: r.GetNonce(), version: r.GetVersionInfo()})
+}
+
+// OnStreamDeltaResponse records the names of the resources sent, which are the rejected ones if the proxy nacks them
+func (o *callbacks) OnStreamDeltaResponse(streamId int64, req *envoy_service_discovery_v3.DeltaDiscoveryRequest, r *envoy_service_discovery_v3.DeltaDiscoveryResponse) {
+	names := make([]string, 0, len(r.GetResources()))
+	for _, res := range r.GetResources() {
+		names = append(names, res.GetName())
+	}
+	o.nacks.onResponse(streamKey{id: streamId, delta: true}, GetRoleFromNode(req.GetNode()), r.GetTypeUrl(),
+		sentResponse{nonce: r.GetNonce(), resourceNames: names})
+}
+
+// connectedStreams lists the streams of the proxies of gateways, none until the collection of the clients is built
+func (o *callbacks) connectedStreams() []ConnectedStream {
+	c := o.collection.Load()
+	if lo.IsNil(c) {
+		return nil
+	}
+	return c.getStreams()
 }
 
 func (o *callbacks) OnFetchRequests(ctx context.Context, r *envoy_service_discovery_v3.DiscoveryRequest) error {
@@ -52,5 +107,9 @@ func (o *callbacks) OnFetchRequests(ctx context.Context, r *envoy_service_discov
 }
 
 func GetRoleFromRequest(r *envoy_service_discovery_v3.DiscoveryRequest) string {
-	return r.GetNode().GetMetadata().GetFields()[xds.RoleKey].GetStringValue()
+	return GetRoleFromNode(r.GetNode())
+}
+
+func GetRoleFromNode(node *envoy_config_core_v3.Node) string {
+	return node.GetMetadata().GetFields()[xds.RoleKey].GetStringValue()
 }
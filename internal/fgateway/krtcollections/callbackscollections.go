@@ -1,7 +1,9 @@
 package krtcollections
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"strings"
 	"sync"
 
@@ -18,10 +20,17 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// streamKey identifies an xds stream. The state of the world and delta servers number their streams
+// independently, so the id alone is ambiguous
+type streamKey struct {
+	id    int64
+	delta bool
+}
+
 type callbacksCollection struct {
 	logger          *zap.Logger
 	augmentedPods   krt.Collection[LocalityPod]
-	clients         map[int64]ConnectedClient
+	clients         map[streamKey]ConnectedClient
 	uniqClientCount map[string]uint64
 	uniqClients     map[string]ir.UniqlyConnectedClient
 	stateLock       sync.RWMutex
@@ -39,21 +48,49 @@ func (o *callbacksCollection) getClients() []ir.UniqlyConnectedClient {
 	return clients
 }
 
+// getStreams returns the tracked streams, sorted by id
+func (o *callbacksCollection) getStreams() []ConnectedStream {
+	o.stateLock.RLock()
+	defer o.stateLock.RUnlock()
+	streams := make([]ConnectedStream, 0, len(o.clients))
+	for stream, cc := range o.clients {
+		streams = append(streams, ConnectedStream{
+			ID:               stream.id,
+			Delta:            stream.delta,
+			NodeID:           cc.nodeID,
+			Role:             cc.role,
+			Locality:         cc.locality,
+			UniqueClientName: cc.uniqueClientName,
+		})
+	}
+	slices.SortFunc(streams, func(a, b ConnectedStream) int {
+		return cmp.Or(cmp.Compare(a.ID, b.ID), cmp.Compare(boolToInt(a.Delta), boolToInt(b.Delta)))
+	})
+	return streams
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // handle stream close and cleanup
-func (o *callbacksCollection) OnStreamClosed(streamId int64) {
-	ucc := o.cleanup(streamId)
+func (o *callbacksCollection) OnStreamClosed(stream streamKey) {
+	ucc := o.cleanup(stream)
 	if lo.IsNotNil(ucc) {
 		// notify who need this collection componentes and trigger re flush computatio
 		o.trigger.TriggerRecomputation()
 	}
 }
 
-func (o *callbacksCollection) cleanup(streamId int64) *ir.UniqlyConnectedClient {
+func (o *callbacksCollection) cleanup(stream streamKey) *ir.UniqlyConnectedClient {
 	o.stateLock.Lock()
 	defer o.stateLock.Unlock()
 
-	connectedClient, ok := o.clients[streamId]
-	delete(o.clients, streamId)
+	connectedClient, ok := o.clients[stream]
+	delete(o.clients, stream)
 	if ok {
 		resourceName := connectedClient.uniqueClientName
 		current := o.uniqClientCount[resourceName]
@@ -68,15 +105,15 @@ func (o *callbacksCollection) cleanup(streamId int64) *ir.UniqlyConnectedClient
 	return nil
 }
 
-// handle stream request
-func (o *callbacksCollection) OnStreamRequest(streamId int64, r *envoy_service_discovery_v3.DiscoveryRequest) error {
-	uccResourceName, isNew, err := o.add(streamId, r)
+// handle the request of a state of the world or delta stream
+func (o *callbacksCollection) OnStreamRequest(stream streamKey, node *envoy_config_core_v3.Node) error {
+	uccResourceName, isNew, err := o.add(stream, node)
 	if err != nil {
 		o.logger.Debug("error processing xds client", zap.Error(err))
 		return err
 	}
 	if uccResourceName != "" {
-		nodeMetadata := r.GetNode().GetMetadata()
+		nodeMetadata := node.GetMetadata()
 		if lo.IsNil(nodeMetadata) {
 			nodeMetadata = &structpb.Struct{}
 		}
@@ -87,7 +124,7 @@ func (o *callbacksCollection) OnStreamRequest(streamId int64, r *envoy_service_d
 		o.logger.Debug("augmenting role in node metadata", zap.String("resourceName", uccResourceName))
 		// set rolekey resourceName
 		nodeMetadata.GetFields()[xds.RoleKey] = structpb.NewStringValue(uccResourceName)
-		r.GetNode().Metadata = nodeMetadata
+		node.Metadata = nodeMetadata
 
 		if isNew {
 			// trigger re computation
@@ -97,12 +134,12 @@ func (o *callbacksCollection) OnStreamRequest(streamId int64, r *envoy_service_d
 	return nil
 }
 
-func (o *callbacksCollection) add(streamId int64, r *envoy_service_discovery_v3.DiscoveryRequest) (string, bool, error) {
+func (o *callbacksCollection) add(stream streamKey, node *envoy_config_core_v3.Node) (string, bool, error) {
 	// stream request core logic
 	var pod *LocalityPod
 	usePod := o.augmentedPods != nil
-	if usePod && r.GetNode() != nil {
-		podRef := getRef(r.GetNode())
+	if usePod && node != nil {
+		podRef := getRef(node)
 		resourceName := krt.Named{Name: podRef.Name, Namespace: podRef.Namespace}.ResourceName()
 		k := krt.Key[LocalityPod](resourceName)
 		pod = o.augmentedPods.GetKey(string(k))
@@ -111,7 +148,7 @@ func (o *callbacksCollection) add(streamId int64, r *envoy_service_discovery_v3.
 	// lock for update resource
 	o.stateLock.Lock()
 	defer o.stateLock.Unlock()
-	cc, ok := o.clients[streamId]
+	cc, ok := o.clients[stream]
 	if !ok {
 		var locality ir.LocalityPod
 		var ns string
@@ -119,27 +156,27 @@ func (o *callbacksCollection) add(streamId int64, r *envoy_service_discovery_v3.
 		if usePod {
 			if lo.IsNil(pod) {
 				// we need to use the pod locality info, so it's an error if we can't get the pod
-				return "", false, errors.Errorf("pod not found for node %v", r.GetNode())
+				return "", false, errors.Errorf("pod not found for node %v", node)
 			} else {
 				locality = pod.Locality
 				ns = pod.Namespace
 				labels = pod.AugmentedLabels
 			}
 		}
-		role := GetRoleFromRequest(r)
+		role := GetRoleFromNode(node)
 		o.logger.Debug("adding xds client", zap.Any("locality", locality), zap.String("ns", ns), zap.Any("labels", labels), zap.String("role", role))
 
 		// update cc & ucc
 		ucc := ir.NewUniqlyConnectedClient(role, ns, labels, locality)
-		cc = NewConnectedClient(ucc.ResourceName)
-		o.clients[streamId] = cc
+		cc = NewConnectedClient(node.GetId(), ucc)
+		o.clients[stream] = cc
 
-		currentUnique := o.uniqClientCount[ucc.ResourceName]
+		currentUnique := o.uniqClientCount[ucc.ResourceName()]
 		if currentUnique == 0 {
-			o.uniqClients[ucc.ResourceName] = ucc
+			o.uniqClients[ucc.ResourceName()] = ucc
 			addedNew = true
 		}
-		o.uniqClientCount[ucc.ResourceName] += 1
+		o.uniqClientCount[ucc.ResourceName()] += 1
 	}
 	return cc.uniqueClientName, addedNew, nil
 }
@@ -170,9 +207,9 @@ func (o *callbacksCollection) OnFetchRequest(_ context.Context, r *envoy_service
 			nodeMetadata.Fields = make(map[string]*structpb.Value)
 		}
 
-		o.logger.Debug("augmenting role in node metadata", zap.String("resourceName", ucc.ResourceName))
+		o.logger.Debug("augmenting role in node metadata", zap.String("resourceName", ucc.ResourceName()))
 		// set rolekey resourceName
-		nodeMetadata.GetFields()[xds.RoleKey] = structpb.NewStringValue(ucc.ResourceName)
+		nodeMetadata.GetFields()[xds.RoleKey] = structpb.NewStringValue(ucc.ResourceName())
 		r.GetNode().Metadata = nodeMetadata
 	} else {
 		return errors.New("get node error")
@@ -0,0 +1,254 @@
+package krtcollections
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var nacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "fgateway_xds_nacks_total",
+	Help: "Number of xds responses rejected by the proxies of a Gateway, by resource type",
+}, []string{"namespace", "gateway", "type_url"})
+
+func init() {
+	metrics.Registry.MustRegister(nacksTotal)
+}
+
+// Nack is the last configuration of a resource type a proxy rejected
+type Nack struct {
+	// NodeID is the node id of the proxy, its pod name and namespace
+	NodeID  string
+	TypeURL string
+	// ResourceNames are the names of the rejected resources: the resources of the rejected response the error of
+	// the proxy names, or all of them when it names none
+	ResourceNames []string
+	// Message is the error of the proxy
+	Message string
+}
+
+// streamNacks are the rejections of the proxy of a stream, by type url
+type streamNacks struct {
+	// role is the role of the gateway of the proxy, before it is augmented into the resource name of the client
+	role string
+	// client is the resource name of the client of the proxy, the key of its resources in the cache, known once a
+	// response is sent
+	client string
+	nodeID string
+	nacks  map[string]rejection
+	// responses are the last responses sent on the stream, by type url
+	responses map[string]sentResponse
+}
+
+// rejection is a Nack and the nonce of the response the proxy rejected. SotW proxies echo the nonce of the last
+// response they received on every request, and only set the error of the first one, so a request echoing the
+// rejected nonce does not mean the proxy accepted a newer response.
+type rejection struct {
+	Nack
+	nonce string
+}
+
+// sentResponse is the last response of a type sent to a proxy. The names of its resources are kept for delta
+// streams, which send them along with the resources. The resources of state of the world responses are only sent
+// marshaled, so their names are found in the cache by the version of the response when the proxy rejects it.
+type sentResponse struct {
+	nonce         string
+	version       string
+	resourceNames []string
+}
+
+// ResponseResources returns the names of the resources of typeURL the client of a resource name had at the version
+// of a state of the world response, nil when they changed since
+type ResponseResources func(client, typeURL, version string) []string
+
+// Nacks records the configuration the proxies reject until they accept a newer one, by Gateway. A proxy acks or
+// nacks every response of the control plane, so a Nack is cleared by the next response of its type the proxy
+// accepts, or when its stream closes.
+type Nacks struct {
+	lock              sync.RWMutex
+	streams           map[streamKey]*streamNacks
+	subscribers       []func(role string)
+	responseResources ResponseResources
+}
+
+func NewNacks() *Nacks {
+	return &Nacks{streams: map[streamKey]*streamNacks{}}
+}
+
+// SetResponseResources sets how the resources of the state of the world responses the proxies reject are found.
+// Without it, the rejected resources of such streams are the ones the proxies request.
+func (n *Nacks) SetResponseResources(f ResponseResources) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.responseResources = f
+}
+
+// Subscribe registers a func called with the role of a Gateway whenever the configuration rejected by its proxies
+// changes. It is called from the xds server, so it must not block.
+func (n *Nacks) Subscribe(f func(role string)) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.subscribers = append(n.subscribers, f)
+}
+
+// Get returns the configuration rejected by the proxies of the Gateway of a role, sorted by proxy and type url
+func (n *Nacks) Get(role string) []Nack {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	var ret []Nack
+	for _, s := range n.streams {
+		if s.role != role {
+			continue
+		}
+		for _, r := range s.nacks {
+			ret = append(ret, r.Nack)
+		}
+	}
+	slices.SortFunc(ret, func(a, b Nack) int {
+		return cmp.Or(cmp.Compare(a.NodeID, b.NodeID), cmp.Compare(a.TypeURL, b.TypeURL))
+	})
+	return ret
+}
+
+// onRequest records the outcome of the last response of typeURL sent on a stream, which the proxy rejected when
+// errorDetail is set, and accepted when it carries the nonce of a newer response. The node is only set on the first
+// request of a stream, which precedes any response. The resource names of the request are the rejected ones when the
+// resources of the rejected response are not known.
+func (n *Nacks) onRequest(stream streamKey, node *envoy_config_core_v3.Node, typeURL, responseNonce string, resourceNames []string, errorDetail *status.Status) {
+	if n == nil {
+		return
+	}
+	n.lock.Lock()
+	s, ok := n.streams[stream]
+	if !ok {
+		role := GetRoleFromNode(node)
+		if !xds.IsKubeGatewayCacheKey(role) {
+			n.lock.Unlock()
+			return
+		}
+		s = &streamNacks{role: role, nodeID: node.GetId(), nacks: map[string]rejection{}, responses: map[string]sentResponse{}}
+		n.streams[stream] = s
+	}
+	changed := false
+	switch {
+	case errorDetail != nil:
+		if sent, ok := s.responses[typeURL]; ok && sent.nonce == responseNonce {
+			if names := n.sentResourceNames(s, typeURL, sent, resourceNames); names != nil {
+				resourceNames = rejectedResources(names, errorDetail.GetMessage())
+			}
+		}
+		nack := Nack{NodeID: s.nodeID, TypeURL: typeURL, ResourceNames: resourceNames, Message: errorDetail.GetMessage()}
+		prev, ok := s.nacks[typeURL]
+		changed = !ok || prev.Message != nack.Message || !slices.Equal(prev.ResourceNames, nack.ResourceNames)
+		s.nacks[typeURL] = rejection{Nack: nack, nonce: responseNonce}
+		if namespace, name, ok := xds.ParseGatewayProxyRole(s.role); ok {
+			nacksTotal.WithLabelValues(namespace, name, typeURL).Inc()
+		}
+	case responseNonce != "":
+		prev, ok := s.nacks[typeURL]
+		if changed = ok && prev.nonce != responseNonce; changed {
+			delete(s.nacks, typeURL)
+		}
+	}
+	subscribers := n.subscribers
+	n.lock.Unlock()
+
+	if changed {
+		for _, f := range subscribers {
+			f(s.role)
+		}
+	}
+}
+
+// sentResourceNames returns the names of the resources of a response sent on a stream, nil when they are no longer
+// known. A state of the world response only holds the requested resources when the proxy names them.
+func (n *Nacks) sentResourceNames(s *streamNacks, typeURL string, sent sentResponse, requested []string) []string {
+	if sent.resourceNames != nil || n.responseResources == nil {
+		return sent.resourceNames
+	}
+	names := n.responseResources(s.client, typeURL, sent.version)
+	if names == nil || len(requested) == 0 {
+		return names
+	}
+	return slices.DeleteFunc(names, func(name string) bool { return !slices.Contains(requested, name) })
+}
+
+// onResponse records the last response of typeURL sent on a stream to the client of a resource name, so that the
+// resources the proxy rejects can be told apart
+func (n *Nacks) onResponse(stream streamKey, client, typeURL string, sent sentResponse) {
+	if n == nil {
+		return
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if s, ok := n.streams[stream]; ok {
+		s.client = client
+		s.responses[typeURL] = sent
+	}
+}
+
+// Mentions reports whether the error of the proxy names a resource, or a part of one such as a virtual host or
+// route of a route configuration
+func (n Nack) Mentions(name string) bool {
+	return mentions(n.Message, name)
+}
+
+// rejectedResources returns the resources the error of a proxy names, or all of them when it names none
+func rejectedResources(resourceNames []string, message string) []string {
+	var ret []string
+	for _, name := range resourceNames {
+		if mentions(message, name) {
+			ret = append(ret, name)
+		}
+	}
+	if len(ret) == 0 {
+		return resourceNames
+	}
+	return ret
+}
+
+// mentions reports whether the message contains the resource name as a whole, so that listener~80 is not
+// mentioned by an error about listener~8080
+func mentions(message, name string) bool {
+	for i := 0; ; {
+		idx := strings.Index(message[i:], name)
+		if idx == -1 {
+			return false
+		}
+		start, end := i+idx, i+idx+len(name)
+		if (start == 0 || !isResourceNameChar(message[start-1])) && (end == len(message) || !isResourceNameChar(message[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// isResourceNameChar reports whether c may be part of the names of the resources of the translator
+func isResourceNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("~_-./", c) != -1
+}
+
+// onStreamClosed forgets the configuration rejected by the proxy of a stream
+func (n *Nacks) onStreamClosed(stream streamKey) {
+	if n == nil {
+		return
+	}
+	n.lock.Lock()
+	s, ok := n.streams[stream]
+	delete(n.streams, stream)
+	subscribers := n.subscribers
+	n.lock.Unlock()
+
+	if ok && len(s.nacks) > 0 {
+		for _, f := range subscribers {
+			f(s.role)
+		}
+	}
+}
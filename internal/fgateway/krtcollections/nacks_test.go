@@ -0,0 +1,146 @@
+package krtcollections
+
+import (
+	"slices"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const testListenerType = "type.googleapis.com/envoy.config.listener.v3.Listener"
+
+func testNode(id, role string) *envoy_config_core_v3.Node {
+	return &envoy_config_core_v3.Node{
+		Id:       id,
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{xds.RoleKey: structpb.NewStringValue(role)}},
+	}
+}
+
+func TestNacks(t *testing.T) {
+	role := xds.GatewayProxyRole("default", "gw")
+	nacks := NewNacks()
+	var notified []string
+	nacks.Subscribe(func(role string) { notified = append(notified, role) })
+
+	sotw, delta := streamKey{id: 1}, streamKey{id: 1, delta: true}
+	// the node is only set on the first request of a stream
+	nacks.onRequest(sotw, testNode("gw-a.default", role), testListenerType, "", nil, nil)
+	nacks.onRequest(delta, testNode("gw-b.default", role), testListenerType, "", nil, nil)
+	nacks.onRequest(streamKey{id: 2}, testNode("other", "other-role"), testListenerType, "", nil, &status.Status{Message: "invalid"})
+	if len(notified) != 0 || len(nacks.Get(role)) != 0 {
+		t.Fatalf("notified %v and recorded %v before any rejection", notified, nacks.Get(role))
+	}
+
+	nacks.onRequest(sotw, nil, testListenerType, "1", []string{"listener~80"}, &status.Status{Message: "invalid listener"})
+	nacks.onRequest(delta, nil, testListenerType, "1", nil, &status.Status{Message: "invalid listener"})
+	// a repeated rejection does not notify again
+	nacks.onRequest(delta, nil, testListenerType, "2", nil, &status.Status{Message: "invalid listener"})
+	want := []Nack{
+		{NodeID: "gw-a.default", TypeURL: testListenerType, ResourceNames: []string{"listener~80"}, Message: "invalid listener"},
+		{NodeID: "gw-b.default", TypeURL: testListenerType, Message: "invalid listener"},
+	}
+	if got := nacks.Get(role); !slices.EqualFunc(got, want, nackEqual) {
+		t.Errorf("nacks = %v, want %v", got, want)
+	}
+	if len(notified) != 2 {
+		t.Errorf("notified %d times, want once per rejection", len(notified))
+	}
+
+	// a sotw proxy echoes the rejected nonce when its subscription changes after the rejection
+	nacks.onRequest(sotw, nil, testListenerType, "1", []string{"listener~80", "listener~443"}, nil)
+	if got := nacks.Get(role); !slices.EqualFunc(got, want, nackEqual) {
+		t.Errorf("nacks after a request echoing the rejected nonce = %v, want %v", got, want)
+	}
+
+	// the proxy accepts the next response
+	nacks.onRequest(sotw, nil, testListenerType, "3", []string{"listener~80"}, nil)
+	if got := nacks.Get(role); !slices.EqualFunc(got, want[1:], nackEqual) {
+		t.Errorf("nacks after an ack = %v, want %v", got, want[1:])
+	}
+	nacks.onStreamClosed(delta)
+	if got := nacks.Get(role); len(got) != 0 {
+		t.Errorf("nacks after the stream closed = %v, want none", got)
+	}
+	if want := []string{role, role, role, role}; !slices.Equal(notified, want) {
+		t.Errorf("notified = %v, want %v", notified, want)
+	}
+}
+
+func nackEqual(a, b Nack) bool {
+	return a.NodeID == b.NodeID && a.TypeURL == b.TypeURL && a.Message == b.Message &&
+		slices.Equal(a.ResourceNames, b.ResourceNames)
+}
+
+func TestNacksRejectedResources(t *testing.T) {
+	role := xds.GatewayProxyRole("default", "gw")
+	nacks := NewNacks()
+	delta := streamKey{id: 1, delta: true}
+	sent := []string{"listener~80", "listener~8080", "listener~53~udp"}
+	nacks.onRequest(delta, testNode("gw-a.default", role), testListenerType, "", nil, nil)
+
+	tests := []struct {
+		name          string
+		responseNonce string
+		message       string
+		want          []string
+	}{
+		{
+			name:          "resources named by the error",
+			responseNonce: "1",
+			message:       "Error adding/updating listener(s) listener~8080: invalid filter, listener~53~udp: invalid address",
+			want:          []string{"listener~8080", "listener~53~udp"},
+		},
+		{name: "error naming no resource", responseNonce: "1", message: "invalid", want: sent},
+		// the rejected response is not the recorded one, the delta request names no resource
+		{name: "unknown response", responseNonce: "0", message: "listener~80: invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nacks.onResponse(delta, "client", testListenerType, sentResponse{nonce: "1", resourceNames: sent})
+			nacks.onRequest(delta, nil, testListenerType, tt.responseNonce, nil, &status.Status{Message: tt.message})
+			got := nacks.Get(role)
+			if len(got) != 1 || !slices.Equal(got[0].ResourceNames, tt.want) {
+				t.Errorf("nacks = %v, want the rejection of %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNacksStateOfTheWorldResources(t *testing.T) {
+	role := xds.GatewayProxyRole("default", "gw")
+	nacks := NewNacks()
+	// the cache only knows the resources of the current version of the client
+	nacks.SetResponseResources(func(client, typeURL, version string) []string {
+		if client != "client" || typeURL != testListenerType || version != "2" {
+			return nil
+		}
+		return []string{"listener~80", "listener~8080", "listener~443"}
+	})
+	sotw := streamKey{id: 1}
+	nacks.onRequest(sotw, testNode("gw-a.default", role), testListenerType, "", nil, nil)
+
+	tests := []struct {
+		name      string
+		version   string
+		requested []string
+		want      []string
+	}{
+		{name: "wildcard request", version: "2", want: []string{"listener~80", "listener~8080", "listener~443"}},
+		// the response only held the requested resources
+		{name: "resources requested by name", version: "2", requested: []string{"listener~80", "listener~443"}, want: []string{"listener~80", "listener~443"}},
+		{name: "version no longer known", version: "1", requested: []string{"listener~80"}, want: []string{"listener~80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nacks.onResponse(sotw, "client", testListenerType, sentResponse{nonce: "1", version: tt.version})
+			nacks.onRequest(sotw, nil, testListenerType, "1", tt.requested, &status.Status{Message: "invalid"})
+			got := nacks.Get(role)
+			if len(got) != 1 || !slices.Equal(got[0].ResourceNames, tt.want) {
+				t.Errorf("nacks = %v, want the rejection of %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,26 +12,53 @@ import (
 
 type ConnectedClient struct {
 	uniqueClientName string
+	// nodeID and locality describe the proxy of the stream
+	nodeID   string
+	role     string
+	locality ir.LocalityPod
 }
 
-func NewConnectedClient(uniqueClientName string) ConnectedClient {
+func NewConnectedClient(nodeID string, ucc ir.UniqlyConnectedClient) ConnectedClient {
 	return ConnectedClient{
-		uniqueClientName: uniqueClientName,
+		uniqueClientName: ucc.ResourceName(),
+		nodeID:           nodeID,
+		role:             ucc.Role,
+		locality:         ucc.Locality,
 	}
 }
 
+// ConnectedStream describes the xds stream of a proxy
+type ConnectedStream struct {
+	ID               int64          `json:"id"`
+	Delta            bool           `json:"delta"`
+	NodeID           string         `json:"nodeId"`
+	Role             string         `json:"role"`
+	Locality         ir.LocalityPod `json:"locality"`
+	UniqueClientName string         `json:"uniqueClientName"`
+}
+
+// ConnectedStreams lists the xds streams of the connected proxies
+type ConnectedStreams func() []ConnectedStream
+
 // If augmentedPods is nil, we won't use the pod locality info, and all pods for the same gateway will receive the same config.
 type UniquelyConnectedClientsBuilder func(ctx context.Context, krtOpts krtutil.KrtOptions, augmentedPods krt.Collection[LocalityPod]) krt.Collection[ir.UniqlyConnectedClient]
 
-func NewUniquelyConnectedClients() (xdsserver.Callbacks, UniquelyConnectedClientsBuilder) {
-	cb := &callbacks{}
+// NewUniquelyConnectedClients returns the xds callbacks tracking the connected clients, the builder of their
+// collection and the lister of their streams. The configuration the clients reject is recorded in nacks, unless
+// it is nil.
+func NewUniquelyConnectedClients(nacks *Nacks) (xdsserver.Callbacks, UniquelyConnectedClientsBuilder, ConnectedStreams) {
+	cb := &callbacks{nacks: nacks}
 	// make xdsserver callback
 	envoycb := xdsserver.CallbackFuncs{
-		StreamClosedFunc:  cb.OnStreamClosed,
-		StreamRequestFunc: cb.OnStreamRequest,
-		FetchRequestFunc:  cb.OnFetchRequests,
+		StreamClosedFunc:        cb.OnStreamClosed,
+		StreamRequestFunc:       cb.OnStreamRequest,
+		StreamResponseFunc:      cb.OnStreamResponse,
+		DeltaStreamClosedFunc:   cb.OnDeltaStreamClosed,
+		StreamDeltaRequestFunc:  cb.OnStreamDeltaRequest,
+		StreamDeltaResponseFunc: cb.OnStreamDeltaResponse,
+		FetchRequestFunc:        cb.OnFetchRequests,
 	}
-	return envoycb, buildCollection(cb)
+	return envoycb, buildCollection(cb), cb.connectedStreams
 }
 
 func buildCollection(callbacks *callbacks) UniquelyConnectedClientsBuilder {
@@ -40,7 +67,7 @@ func buildCollection(callbacks *callbacks) UniquelyConnectedClientsBuilder {
 		col := &callbacksCollection{
 			logger:          contextutils.LoggerFrom(ctx).Desugar(),
 			augmentedPods:   augmentPods,
-			clients:         make(map[int64]ConnectedClient),
+			clients:         make(map[streamKey]ConnectedClient),
 			uniqClientCount: make(map[string]uint64),
 			uniqClients:     make(map[string]ir.UniqlyConnectedClient),
 			trigger:         trigger,
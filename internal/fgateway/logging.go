@@ -4,17 +4,20 @@ import (
 	"context"
 	"os"
 
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/logutil"
 	"github.com/fleezesd/fgateway/internal/version"
-	"github.com/go-logr/zapr"
 	"github.com/solo-io/go-utils/contextutils"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	istiolog "istio.io/istio/pkg/log"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
-// SetupLogging setup zap for controller-runtime logger
-func SetupLogging(ctx context.Context, loggerName string) {
+// SetupLogging builds the logging registry. Every scope starts at the level of the LOG_LEVEL env, then at the one
+// of the scope=level pairs of levels. Dev mode, with human readable logs, is turned on by LOG_LEVEL=debug.
+// The loggers of controller-runtime, istio and contextutils are replaced by the ones of their scopes.
+func SetupLogging(ctx context.Context, loggerName string, levels string) (*logutil.Registry, error) {
 	level := zapcore.InfoLevel
 	logger := contextutils.LoggerFrom(ctx)
 	if envLogLevel := os.Getenv(contextutils.LogLevelEnvName); envLogLevel != "" {
@@ -27,15 +30,37 @@ func SetupLogging(ctx context.Context, loggerName string) {
 			)
 		}
 	}
-	atomicLevel := zap.NewAtomicLevelAt(level)
+	dev := IsDevMode()
+
+	// istio configures the levels of all its scopes, which the registry overrides for krt
+	loggingOptions := istiolog.DefaultOptions()
+	if dev {
+		loggingOptions.SetDefaultOutputLevel(istiolog.OverrideScopeName, istiolog.DebugLevel)
+	}
+	if err := istiolog.Configure(loggingOptions); err != nil {
+		return nil, err
+	}
 
 	baseLogger := ctrlzap.NewRaw(
-		ctrlzap.Level(&atomicLevel),
+		ctrlzap.UseDevMode(dev),
+		// the scopes of the registry filter the entries by their own level
+		ctrlzap.Level(zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })),
 		ctrlzap.RawZapOpts(zap.Fields(
 			zap.String("version", version.Version),
 		)),
 	).Named(loggerName)
+	registry := logutil.NewRegistry(baseLogger, level)
+	if err := registry.SetLevels(levels); err != nil {
+		return nil, err
+	}
 
 	// setup logger
-	ctrllog.SetLogger(zapr.NewLogger(baseLogger))
+	ctrllog.SetLogger(registry.Logr(logutil.ControllerRuntimeScope))
+	contextutils.SetFallbackLogger(registry.Logger(logutil.DefaultScope).Sugar())
+	return registry, nil
+}
+
+// IsDevMode reports whether the LOG_LEVEL env turns dev mode on
+func IsDevMode() bool {
+	return os.Getenv(contextutils.LogLevelEnvName) == "debug"
 }
@@ -0,0 +1,36 @@
+package ports
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// portOffset is added to privileged listener ports so that the proxy can bind them without running as root
+const portOffset = 8000
+
+// TranslatePort returns the port the proxy binds for a Gateway listener port.
+// Both the deployer (Service targetPort) and the xDS translator (Listener address) must use it.
+func TranslatePort(port uint16) uint16 {
+	if port >= 1024 {
+		return port
+	}
+	return port + portOffset
+}
+
+// Protocol returns the protocol the proxy binds the port of a listener with. UDP listeners may share their port
+// number with the listeners of the other protocols, which are all bound over TCP.
+func Protocol(protocol api.ProtocolType) corev1.Protocol {
+	if protocol == api.UDPProtocolType {
+		return corev1.ProtocolUDP
+	}
+	return corev1.ProtocolTCP
+}
+
+// Shadowed reports whether the proxy can not bind a listener port because it translates to one of listenerPorts,
+// the ports of the listeners of its Gateway bound with the same protocol, which the proxy binds as they are
+func Shadowed(port uint16, listenerPorts []uint16) bool {
+	translated := TranslatePort(port)
+	return translated != port && slices.Contains(listenerPorts, translated)
+}
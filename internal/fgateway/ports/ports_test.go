@@ -0,0 +1,41 @@
+package ports
+
+import "testing"
+
+func TestTranslatePort(t *testing.T) {
+	tests := []struct {
+		port, want uint16
+	}{
+		{port: 80, want: 8080},
+		{port: 443, want: 8443},
+		{port: 1023, want: 9023},
+		{port: 1024, want: 1024},
+		{port: 8080, want: 8080},
+	}
+	for _, tt := range tests {
+		if got := TranslatePort(tt.port); got != tt.want {
+			t.Errorf("TranslatePort(%d) = %d, want %d", tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestShadowed(t *testing.T) {
+	tests := []struct {
+		name          string
+		port          uint16
+		listenerPorts []uint16
+		want          bool
+	}{
+		{name: "privileged port alone", port: 80, listenerPorts: []uint16{80, 443}},
+		{name: "privileged port translated to a listener port", port: 80, listenerPorts: []uint16{80, 8080}, want: true},
+		{name: "listener port bound as is", port: 8080, listenerPorts: []uint16{80, 8080}},
+		{name: "unprivileged port", port: 9000, listenerPorts: []uint16{9000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Shadowed(tt.port, tt.listenerPorts); got != tt.want {
+				t.Errorf("Shadowed(%d, %v) = %v, want %v", tt.port, tt.listenerPorts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package proxysyncer
+
+import (
+	"context"
+
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"github.com/pkg/errors"
+	"github.com/solo-io/go-utils/contextutils"
+	"go.uber.org/zap"
+	istiokube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/controllers"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var _ manager.LeaderElectionRunnable = new(ProxySyncer)
+
+// ProxySyncer keeps the xds snapshot cache in sync with the Gateway API resources. It joins the translated
+// gateway IR, the uniquely connected clients and the augmented pods into a snapshot per connected client,
+// and evicts the snapshot of a client once its last proxy disconnects.
+type ProxySyncer struct {
+	controllerName string
+	classNames     []string
+
+	xdsCache      envoycache.SnapshotCache
+	istioClient   istiokube.Client
+	uniqueClients krt.Collection[ir.UniqlyConnectedClient]
+	augmentedPods krt.Collection[krtcollections.LocalityPod]
+	krtOpts       krtutil.KrtOptions
+
+	inputs    translator.Inputs
+	gateways  krt.Collection[ir.GatewayIR]
+	snapshots krt.Collection[translator.ClientSnapshot]
+}
+
+// NewProxySyncer returns a ProxySyncer serving the Gateways of the GatewayClasses with controllerName,
+// or named in classNames. Init must be called before the istio client is started.
+func NewProxySyncer(
+	controllerName string,
+	classNames []string,
+	xdsCache envoycache.SnapshotCache,
+	istioClient istiokube.Client,
+	uniqueClients krt.Collection[ir.UniqlyConnectedClient],
+	augmentedPods krt.Collection[krtcollections.LocalityPod],
+	krtOpts krtutil.KrtOptions,
+) *ProxySyncer {
+	return &ProxySyncer{
+		controllerName: controllerName,
+		classNames:     classNames,
+		xdsCache:       xdsCache,
+		istioClient:    istioClient,
+		uniqueClients:  uniqueClients,
+		augmentedPods:  augmentedPods,
+		krtOpts:        krtOpts,
+	}
+}
+
+// Init builds the krt collections of the syncer
+func (s *ProxySyncer) Init(ctx context.Context) {
+	s.inputs = translator.NewInputs(s.istioClient, s.krtOpts)
+	s.gateways = translator.NewGatewayCollection(ctx, s.inputs, s.controllerName, s.classNames, s.krtOpts)
+	endpoints := translator.NewBackendEndpoints(s.inputs, s.augmentedPods, s.krtOpts)
+	s.snapshots = translator.NewClientSnapshots(ctx, s.gateways, endpoints, s.uniqueClients, s.krtOpts)
+}
+
+// Gateways returns the collection of the IR the syncer translates the Gateways to, once Init is called
+func (s *ProxySyncer) Gateways() krt.Collection[ir.GatewayIR] {
+	return s.gateways
+}
+
+// Secrets returns the collection of the Secrets of the cluster the syncer translates the certificates from, once Init
+// is called
+func (s *ProxySyncer) Secrets() krt.Collection[*corev1.Secret] {
+	return s.inputs.Secrets
+}
+
+// ConfigMaps returns the collection of the ConfigMaps of the cluster the syncer translates the CA certificates of the
+// backends from, once Init is called
+func (s *ProxySyncer) ConfigMaps() krt.Collection[*corev1.ConfigMap] {
+	return s.inputs.ConfigMaps
+}
+
+// Start pushes the snapshots to the cache once the collections are synced, until the context is done
+func (s *ProxySyncer) Start(ctx context.Context) error {
+	logger := contextutils.LoggerFrom(ctx).Desugar()
+	logger.Info("waiting for proxy syncer collections to sync")
+	if !s.snapshots.WaitUntilSynced(ctx.Done()) {
+		return errors.New("proxy syncer collections did not sync")
+	}
+
+	logger.Info("starting proxy syncer")
+	s.snapshots.RegisterBatch(func(events []krt.Event[translator.ClientSnapshot], _ bool) {
+		for _, e := range events {
+			if e.Event == controllers.EventDelete {
+				// the client disconnected, or its gateway is gone
+				logger.Debug("clearing xds snapshot", zap.String("client", e.Latest().ClientName))
+				s.xdsCache.ClearSnapshot(e.Latest().ClientName)
+				continue
+			}
+			snap := e.Latest()
+			logger.Debug("setting xds snapshot", zap.String("client", snap.ClientName))
+			if err := s.xdsCache.SetSnapshot(ctx, snap.ClientName, snap.Snapshot); err != nil {
+				logger.Error("failed to set xds snapshot", zap.String("client", snap.ClientName), zap.Error(err))
+			}
+		}
+	}, true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// NeedLeaderElection is false: every replica of the control plane serves xds to the proxies connected to it
+func (s *ProxySyncer) NeedLeaderElection() bool {
+	return false
+}
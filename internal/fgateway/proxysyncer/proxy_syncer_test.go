@@ -0,0 +1,79 @@
+package proxysyncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	envoytypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/translator"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"istio.io/istio/pkg/kube/krt"
+)
+
+func clientSnapshot(t *testing.T, client, version string) translator.ClientSnapshot {
+	t.Helper()
+	snapshot, err := envoycache.NewSnapshot(version, map[resource.Type][]envoytypes.Resource{resource.ClusterType: nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return translator.ClientSnapshot{ClientName: client, Snapshot: snapshot}
+}
+
+// eventually fails the test unless cond holds within a few seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProxySyncerStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	krtOpts := krtutil.NewKrtOptions(ctx.Done(), nil)
+	snapshots := krt.NewStaticCollection([]translator.ClientSnapshot{clientSnapshot(t, "a", "1")}, krtOpts.ApplyTo("snapshots")...)
+	cache := envoycache.NewSnapshotCache(false, envoycache.IDHash{}, nil)
+	s := &ProxySyncer{xdsCache: cache, snapshots: snapshots}
+
+	done := make(chan error)
+	go func() {
+		done <- s.Start(ctx)
+	}()
+
+	version := func(client string) string {
+		snapshot, err := cache.GetSnapshot(client)
+		if err != nil {
+			return ""
+		}
+		return snapshot.GetVersion(resource.ClusterType)
+	}
+	eventually(t, "the snapshot of the existing client", func() bool { return version("a") == "1" })
+
+	snapshots.UpdateObject(clientSnapshot(t, "a", "2"))
+	snapshots.UpdateObject(clientSnapshot(t, "b", "1"))
+	eventually(t, "the updated snapshots", func() bool { return version("a") == "2" && version("b") == "1" })
+
+	// the snapshot of a disconnected client is evicted
+	snapshots.DeleteObject("a")
+	eventually(t, "the eviction of the snapshot", func() bool { return version("a") == "" })
+	if version("b") != "1" {
+		t.Errorf("snapshot of another client is evicted")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("syncer did not stop with its context")
+	}
+}
@@ -3,15 +3,17 @@ package fgateway
 import (
 	"context"
 	"net"
-	"os"
 
-	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	xdsserver "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/admin"
 	"github.com/fleezesd/fgateway/internal/fgateway/controller"
+	"github.com/fleezesd/fgateway/internal/fgateway/extension/settings"
 	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
 	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/logutil"
 	"github.com/fleezesd/fgateway/pkg/utils/envutil"
 	"github.com/fleezesd/fgateway/pkg/utils/kubeutil"
+	"github.com/fleezesd/fgateway/pkg/xds"
 	"github.com/solo-io/go-utils/contextutils"
 	"istio.io/istio/pkg/cluster"
 	istiokube "istio.io/istio/pkg/kube"
@@ -21,9 +23,14 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-func Run(ctx context.Context) error {
-	SetupLogging(ctx, kubeutil.FgatewayComponentName)
-	return startFgateway(ctx)
+// Run runs the controller, with the log levels of its components given as a comma separated list of scope=level
+// pairs
+func Run(ctx context.Context, logLevels string) error {
+	logging, err := SetupLogging(ctx, kubeutil.FgatewayComponentName, logLevels)
+	if err != nil {
+		return err
+	}
+	return startFgateway(ctx, logging)
 }
 
 func createIstioClient(restConfig *rest.Config, clusterId cluster.ID) (istiokube.Client, error) {
@@ -37,19 +44,27 @@ func createIstioClient(restConfig *rest.Config, clusterId cluster.ID) (istiokube
 	return client, nil
 }
 
-func startFgateway(ctx context.Context) error {
+func startFgateway(ctx context.Context, logging *logutil.Registry) error {
 	restConfig := ctrl.GetConfigOrDie()
 	// callback & ucc builder
-	uniqueClientCallbacks, uccBuilder := krtcollections.NewUniquelyConnectedClients()
+	nacks := krtcollections.NewNacks()
+	uniqueClientCallbacks, uccBuilder, streams := krtcollections.NewUniquelyConnectedClients(nacks)
 	// envoycache
-	cache, err := startControlPlane(ctx, uniqueClientCallbacks)
+	cache, err := startControlPlane(
+		contextutils.WithExistingLogger(ctx, logging.Logger(logutil.XdsScope).Sugar()),
+		uniqueClientCallbacks,
+	)
 	if err != nil {
 		return err
 	}
+	// the proxies reject state of the world responses by version, whose resources the cache knows
+	nacks.SetResponseResources(cache.ResourceNames)
 
 	opts := &controller.StartOptions{
 		Cache:       cache,
 		KrtDebugger: new(krt.DebugHandler),
+		Nacks:       nacks,
+		Logging:     logging,
 		XdsHost: kubeutil.GetServiceFQDN(
 			metav1.ObjectMeta{
 				Name:      kubeutil.FgatewayServiceName,
@@ -58,10 +73,10 @@ func startFgateway(ctx context.Context) error {
 		),
 		XdsPort: 9000,
 	}
-	return startFgatewayWithConfig(ctx, restConfig, uccBuilder, opts)
+	return startFgatewayWithConfig(ctx, restConfig, uccBuilder, streams, opts)
 }
 
-func startControlPlane(ctx context.Context, callbacks xdsserver.Callbacks) (envoycache.SnapshotCache, error) {
+func startControlPlane(ctx context.Context, callbacks xdsserver.Callbacks) (*xds.ClientCache, error) {
 	return NewControlPlane(
 		ctx,
 		&net.TCPAddr{IP: net.IPv4zero, Port: 9000}, // recieve any ip requests
@@ -73,6 +88,7 @@ func startFgatewayWithConfig(
 	ctx context.Context,
 	restConfig *rest.Config,
 	uccBuilder krtcollections.UniquelyConnectedClientsBuilder,
+	streams krtcollections.ConnectedStreams,
 	startOpts *controller.StartOptions,
 ) error {
 	ctx = contextutils.WithLogger(ctx, "k8s")
@@ -94,12 +110,20 @@ func startFgatewayWithConfig(
 
 	// ucc builder
 	ucc := uccBuilder(ctx, krtOpts, augmentedPodsForUcc)
+
+	st, err := settings.BuildSettings()
+	if err != nil {
+		logger.Error("error loading settings from env: ", err)
+		return err
+	}
+	startOpts.ExtraGatewayClasses = st.ExtraGatewayClasses
 	logger.Info("initializing controller")
 	// controller builder
 	c, err := controller.NewControllerBuilder(ctx, controller.StartConfig{
 		// todo: add extra plugin later
-		Dev:           os.Getenv("LOG_LEVL") == "debug",
+		Dev:           IsDevMode(),
 		StartOpts:     startOpts,
+		Settings:      *st,
 		RestConfig:    restConfig,
 		Client:        istioClient,
 		AugmentedPods: augmentedPods,
@@ -114,7 +138,14 @@ func startFgatewayWithConfig(
 	logger.Info("waiting for cache sync")
 	istioClient.RunAndWait(ctx.Done())
 
-	// 3.todo: make admin server
+	admin.StartServer(ctx, admin.Options{
+		Address:     st.AdminAddress,
+		Port:        admin.DefaultPort,
+		KrtDebugger: startOpts.KrtDebugger,
+		Cache:       startOpts.Cache,
+		Streams:     streams,
+		LogLevel:    startOpts.Logging,
+	})
 
 	// start controller
 	logger.Info("starting controller")
@@ -0,0 +1,273 @@
+package translator
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_extensions_upstreams_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/krtcollections"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const (
+	clusterConnectTimeout = 5 * time.Second
+
+	// httpProtocolOptionsName is the key of the upstream HTTP protocol options in the typed extension protocol
+	// options of a cluster
+	httpProtocolOptionsName = "envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
+)
+
+// ClusterName returns the name of the EDS cluster of a Service port. The clusters of UDP ports are suffixed, as a
+// Service may expose the same port number over TCP and UDP.
+func ClusterName(namespace, name string, port int32, protocol corev1.Protocol) string {
+	if protocol == corev1.ProtocolUDP {
+		return fmt.Sprintf("kube_%s_%s_%d_udp", namespace, name, port)
+	}
+	return fmt.Sprintf("kube_%s_%s_%d", namespace, name, port)
+}
+
+// BackendRefError is returned when a backendRef of a route can not be resolved. Reason is the reason of the
+// ResolvedRefs condition of the route.
+type BackendRefError struct {
+	Reason  api.RouteConditionReason
+	Message string
+}
+
+func (e *BackendRefError) Error() string {
+	return e.Message
+}
+
+// BackendResolver resolves the backendRefs of routes to the clusters of Service ports
+type BackendResolver struct {
+	// GetService returns the Service with the given namespace and name, or nil if it does not exist
+	GetService func(namespace, name string) *corev1.Service
+	// Grants allow the backendRefs to Services of other namespaces
+	Grants ReferenceGrants
+}
+
+// krtBackendResolver looks Services up in the collection, recording them as dependencies of the handler context
+func krtBackendResolver(kctx krt.HandlerContext, services krt.Collection[*corev1.Service], grants ReferenceGrants) BackendResolver {
+	return BackendResolver{
+		GetService: func(namespace, name string) *corev1.Service {
+			return ptr.Deref(krt.FetchOne(kctx, services, krt.FilterObjectName(types.NamespacedName{Namespace: namespace, Name: name})), nil)
+		},
+		Grants: grants,
+	}
+}
+
+// Resolve returns the cluster of the TCP Service port a backendRef of a route of kind routeKind in routeNamespace
+// refers to. The returned error is a *BackendRefError.
+func (r BackendResolver) Resolve(ref api.BackendObjectReference, routeKind api.RouteGroupKind, routeNamespace string) (string, error) {
+	return r.ResolveProtocol(ref, routeKind, routeNamespace, corev1.ProtocolTCP)
+}
+
+// ResolveProtocol is Resolve for the Service ports of the given protocol
+func (r BackendResolver) ResolveProtocol(ref api.BackendObjectReference, routeKind api.RouteGroupKind, routeNamespace string, protocol corev1.Protocol) (string, error) {
+	if ptr.Deref(ref.Group, "") != "" || ptr.Deref(ref.Kind, "Service") != "Service" {
+		return "", &BackendRefError{
+			Reason:  api.RouteReasonInvalidKind,
+			Message: fmt.Sprintf("backend kind %s.%s is not supported", ptr.Deref(ref.Kind, "Service"), ptr.Deref(ref.Group, "")),
+		}
+	}
+	ns := string(ptr.Deref(ref.Namespace, api.Namespace(routeNamespace)))
+	from := apiv1beta1.ReferenceGrantFrom{Group: ptr.Deref(routeKind.Group, api.GroupName), Kind: routeKind.Kind, Namespace: api.Namespace(routeNamespace)}
+	if !r.Grants.Allowed(from, ns, apiv1beta1.ReferenceGrantTo{Kind: "Service", Name: ptr.To(ref.Name)}) {
+		return "", &BackendRefError{
+			Reason:  api.RouteReasonRefNotPermitted,
+			Message: fmt.Sprintf("backend %s/%s is in another namespace and no ReferenceGrant allows it", ns, ref.Name),
+		}
+	}
+	if ref.Port == nil {
+		return "", &BackendRefError{
+			Reason:  api.RouteReasonBackendNotFound,
+			Message: fmt.Sprintf("backend %s/%s has no port", ns, ref.Name),
+		}
+	}
+	svc := r.GetService(ns, string(ref.Name))
+	if svc == nil {
+		return "", &BackendRefError{
+			Reason:  api.RouteReasonBackendNotFound,
+			Message: fmt.Sprintf("service %s/%s not found", ns, ref.Name),
+		}
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Port == int32(*ref.Port) && cmp.Or(p.Protocol, corev1.ProtocolTCP) == protocol {
+			return ClusterName(ns, string(ref.Name), p.Port, protocol), nil
+		}
+	}
+	return "", &BackendRefError{
+		Reason:  api.RouteReasonBackendNotFound,
+		Message: fmt.Sprintf("service %s/%s has no %s port %d", ns, ref.Name, protocol, *ref.Port),
+	}
+}
+
+// edsCluster is a cluster whose endpoints are served over ADS. The requests are balanced between the localities
+// of a priority by their weights, see loadAssignment.
+func edsCluster(name string) *envoy_config_cluster_v3.Cluster {
+	return &envoy_config_cluster_v3.Cluster{
+		Name:                 name,
+		ConnectTimeout:       durationpb.New(clusterConnectTimeout),
+		ClusterDiscoveryType: &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_EDS},
+		EdsClusterConfig: &envoy_config_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig: adsConfigSource(),
+		},
+		CommonLbConfig: &envoy_config_cluster_v3.Cluster_CommonLbConfig{
+			LocalityConfigSpecifier: &envoy_config_cluster_v3.Cluster_CommonLbConfig_LocalityWeightedLbConfig_{
+				LocalityWeightedLbConfig: &envoy_config_cluster_v3.Cluster_CommonLbConfig_LocalityWeightedLbConfig{},
+			},
+		},
+	}
+}
+
+// staticCluster is a cluster without endpoints, to which the proxy answers the requests with a 503
+func staticCluster(name string) *envoy_config_cluster_v3.Cluster {
+	return &envoy_config_cluster_v3.Cluster{
+		Name:                 name,
+		ConnectTimeout:       durationpb.New(clusterConnectTimeout),
+		ClusterDiscoveryType: &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_STATIC},
+		LoadAssignment:       &envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: name},
+	}
+}
+
+// http2ProtocolOptions are the protocol options of a cluster whose backends speak HTTP/2 in cleartext
+func http2ProtocolOptions() (map[string]*anypb.Any, error) {
+	opts, err := anypb.New(&envoy_extensions_upstreams_http_v3.HttpProtocolOptions{
+		UpstreamProtocolOptions: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: &envoy_config_core_v3.Http2ProtocolOptions{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]*anypb.Any{httpProtocolOptionsName: opts}, nil
+}
+
+func adsConfigSource() *envoy_config_core_v3.ConfigSource {
+	return &envoy_config_core_v3.ConfigSource{
+		ResourceApiVersion: envoy_config_core_v3.ApiVersion_V3,
+		ConfigSourceSpecifier: &envoy_config_core_v3.ConfigSource_Ads{
+			Ads: &envoy_config_core_v3.AggregatedConfigSource{},
+		},
+	}
+}
+
+// NewBackendEndpoints builds the ready endpoints of every Service port from the EndpointSlices of the Service,
+// keyed by the name of the cluster of the port. The locality of an endpoint is the one of its pod in
+// augmentedPods, falling back to the zone of the endpoint when the pod is unknown or augmentedPods is nil, and to
+// the region of the other endpoints of that zone.
+func NewBackendEndpoints(inputs Inputs, augmentedPods krt.Collection[krtcollections.LocalityPod], krtOpts krtutil.KrtOptions) krt.Collection[ir.BackendEndpoints] {
+	slicesByService := krt.NewIndex(inputs.EndpointSlices, func(s *discoveryv1.EndpointSlice) []types.NamespacedName {
+		svcName := s.Labels[discoveryv1.LabelServiceName]
+		if svcName == "" {
+			return nil
+		}
+		return []types.NamespacedName{{Namespace: s.Namespace, Name: svcName}}
+	})
+	return krt.NewManyCollection(inputs.Services, func(kctx krt.HandlerContext, svc *corev1.Service) []ir.BackendEndpoints {
+		endpointSlices := krt.Fetch(kctx, inputs.EndpointSlices, krt.FilterIndex(slicesByService, types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}))
+		locality := func(ep discoveryv1.Endpoint) ir.LocalityPod {
+			if augmentedPods != nil && ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+				pod := krt.FetchOne(kctx, augmentedPods, krt.FilterObjectName(types.NamespacedName{Namespace: ep.TargetRef.Namespace, Name: ep.TargetRef.Name}))
+				if pod != nil {
+					return pod.Locality
+				}
+			}
+			return ir.LocalityPod{Zone: ptr.Deref(ep.Zone, "")}
+		}
+		ret := make([]ir.BackendEndpoints, 0, len(svc.Spec.Ports))
+		for _, port := range svc.Spec.Ports {
+			ret = append(ret, ir.BackendEndpoints{
+				ClusterName: ClusterName(svc.Namespace, svc.Name, port.Port, cmp.Or(port.Protocol, corev1.ProtocolTCP)),
+				Endpoints:   portEndpoints(endpointSlices, port, locality),
+			})
+		}
+		return ret
+	}, krtOpts.ApplyTo("BackendEndpoints")...)
+}
+
+// portEndpoints returns the ready addresses of the EndpointSlices for a Service port, which is matched
+// by name against the ports of the slices
+func portEndpoints(endpointSlices []*discoveryv1.EndpointSlice, svcPort corev1.ServicePort, locality func(discoveryv1.Endpoint) ir.LocalityPod) []ir.Endpoint {
+	var ret []ir.Endpoint
+	for _, slice := range endpointSlices {
+		if slice.AddressType != discoveryv1.AddressTypeIPv4 && slice.AddressType != discoveryv1.AddressTypeIPv6 {
+			continue
+		}
+		var port *int32
+		for _, p := range slice.Ports {
+			if ptr.Deref(p.Name, "") == svcPort.Name && ptr.Deref(p.Protocol, corev1.ProtocolTCP) == cmp.Or(svcPort.Protocol, corev1.ProtocolTCP) {
+				port = p.Port
+				break
+			}
+		}
+		if port == nil {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if !ptr.Deref(ep.Conditions.Ready, true) {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				ret = append(ret, ir.Endpoint{
+					Address:  addr,
+					Port:     uint32(*port),
+					Locality: locality(ep),
+				})
+			}
+		}
+	}
+	// slices are fetched in no particular order
+	slices.SortFunc(ret, func(a, b ir.Endpoint) int {
+		return cmp.Or(cmp.Compare(a.Address, b.Address), cmp.Compare(a.Port, b.Port))
+	})
+	regions := zoneRegions(ret)
+	for i := range ret {
+		ret[i].Locality = withZoneRegion(ret[i].Locality, regions)
+	}
+	return ret
+}
+
+// zoneRegions maps the zones of the endpoints, and of the extra localities, to their region. Zone names are unique
+// across regions, so that the endpoints whose pod is unknown, which only have a zone, can take it from the others.
+func zoneRegions(endpoints []ir.Endpoint, extra ...ir.LocalityPod) map[string]string {
+	regions := map[string]string{}
+	add := func(l ir.LocalityPod) {
+		if l.Region != "" && l.Zone != "" {
+			regions[l.Zone] = l.Region
+		}
+	}
+	for _, ep := range endpoints {
+		add(ep.Locality)
+	}
+	for _, l := range extra {
+		add(l)
+	}
+	return regions
+}
+
+// withZoneRegion fills in the region of a locality that only has a zone from regions
+func withZoneRegion(l ir.LocalityPod, regions map[string]string) ir.LocalityPod {
+	if l.Region == "" && l.Zone != "" {
+		l.Region = regions[l.Zone]
+	}
+	return l
+}
@@ -0,0 +1,280 @@
+package translator
+
+import (
+	"cmp"
+	"crypto/x509"
+	"fmt"
+	"slices"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"google.golang.org/protobuf/types/known/anypb"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+)
+
+const (
+	// caCertificateKey is the key of the CA certificates in the ConfigMaps and Secrets of caCertificateRefs
+	caCertificateKey = "ca.crt"
+
+	// systemCAFile is the bundle of the well-known CAs in the proxy image
+	systemCAFile = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+// BackendTLSSecretName returns the name of the SDS secret holding the CA certificates of a BackendTLSPolicy
+func BackendTLSSecretName(namespace, name string) string {
+	return fmt.Sprintf("kube_backendtls_%s_%s", namespace, name)
+}
+
+// BackendTLSPolicyError is returned when a BackendTLSPolicy can not be applied. Reason is the reason of the
+// Accepted condition of the policy.
+type BackendTLSPolicyError struct {
+	Reason  apiv1alpha2.PolicyConditionReason
+	Message string
+}
+
+func (e *BackendTLSPolicyError) Error() string {
+	return e.Message
+}
+
+// BackendTLSResolver resolves the caCertificateRefs of BackendTLSPolicies to the ConfigMaps and Secrets holding
+// the CA certificates
+type BackendTLSResolver struct {
+	// GetConfigMap returns the ConfigMap with the given namespace and name, or nil if it does not exist
+	GetConfigMap func(namespace, name string) *corev1.ConfigMap
+	// GetSecret returns the Secret with the given namespace and name, or nil if it does not exist
+	GetSecret func(namespace, name string) *corev1.Secret
+}
+
+// krtBackendTLSResolver looks ConfigMaps and Secrets up in the collections, recording them as dependencies of the
+// handler context
+func krtBackendTLSResolver(kctx krt.HandlerContext, configMaps krt.Collection[*corev1.ConfigMap], secrets krt.Collection[*corev1.Secret]) BackendTLSResolver {
+	return BackendTLSResolver{
+		GetConfigMap: func(namespace, name string) *corev1.ConfigMap {
+			return ptr.Deref(krt.FetchOne(kctx, configMaps, krt.FilterObjectName(types.NamespacedName{Namespace: namespace, Name: name})), nil)
+		},
+		GetSecret: func(namespace, name string) *corev1.Secret {
+			return ptr.Deref(krt.FetchOne(kctx, secrets, krt.FilterObjectName(types.NamespacedName{Namespace: namespace, Name: name})), nil)
+		},
+	}
+}
+
+// Resolve returns the PEM bundle of the CA certificates a BackendTLSPolicy verifies the backends against, which is
+// nil when the policy relies on the well-known CAs. The returned error is a *BackendTLSPolicyError.
+func (r BackendTLSResolver) Resolve(policy *apiv1alpha3.BackendTLSPolicy) ([]byte, error) {
+	validation := policy.Spec.Validation
+	if validation.WellKnownCACertificates != nil {
+		if *validation.WellKnownCACertificates != apiv1alpha3.WellKnownCACertificatesSystem {
+			return nil, &BackendTLSPolicyError{
+				Reason:  apiv1alpha2.PolicyReasonInvalid,
+				Message: fmt.Sprintf("well-known CA certificates %q are not supported", *validation.WellKnownCACertificates),
+			}
+		}
+		return nil, nil
+	}
+	if len(validation.CACertificateRefs) == 0 {
+		return nil, &BackendTLSPolicyError{
+			Reason:  apiv1alpha2.PolicyReasonInvalid,
+			Message: "policy has neither caCertificateRefs nor wellKnownCACertificates",
+		}
+	}
+	var bundle []byte
+	for _, ref := range validation.CACertificateRefs {
+		var data string
+		switch {
+		case ref.Group == "" && ref.Kind == "ConfigMap":
+			cm := r.GetConfigMap(policy.Namespace, string(ref.Name))
+			if cm == nil {
+				return nil, &BackendTLSPolicyError{
+					Reason:  apiv1alpha2.PolicyReasonInvalid,
+					Message: fmt.Sprintf("CA certificate ConfigMap %s/%s not found", policy.Namespace, ref.Name),
+				}
+			}
+			data = cm.Data[caCertificateKey]
+		case ref.Group == "" && ref.Kind == "Secret":
+			secret := r.GetSecret(policy.Namespace, string(ref.Name))
+			if secret == nil {
+				return nil, &BackendTLSPolicyError{
+					Reason:  apiv1alpha2.PolicyReasonInvalid,
+					Message: fmt.Sprintf("CA certificate Secret %s/%s not found", policy.Namespace, ref.Name),
+				}
+			}
+			data = string(secret.Data[caCertificateKey])
+		default:
+			return nil, &BackendTLSPolicyError{
+				Reason:  apiv1alpha2.PolicyReasonInvalid,
+				Message: fmt.Sprintf("CA certificate kind %s.%s is not supported", ref.Kind, ref.Group),
+			}
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(data)) {
+			return nil, &BackendTLSPolicyError{
+				Reason:  apiv1alpha2.PolicyReasonInvalid,
+				Message: fmt.Sprintf("%s %s/%s has no valid CA certificate in %s", ref.Kind, policy.Namespace, ref.Name, caCertificateKey),
+			}
+		}
+		bundle = append(bundle, data...)
+		if bundle[len(bundle)-1] != '\n' {
+			bundle = append(bundle, '\n')
+		}
+	}
+	return bundle, nil
+}
+
+// backendTLSTargets reports whether a BackendTLSPolicy targets a port of a Service, and whether it targets the port
+// by its sectionName rather than the whole Service
+func backendTLSTargets(policy *apiv1alpha3.BackendTLSPolicy, svcName string, port corev1.ServicePort) (targets, bySection bool) {
+	for _, ref := range policy.Spec.TargetRefs {
+		if ref.Group != "" || ref.Kind != "Service" || string(ref.Name) != svcName {
+			continue
+		}
+		if ref.SectionName == nil {
+			targets = true
+		} else if string(*ref.SectionName) == port.Name {
+			return true, true
+		}
+	}
+	return targets, false
+}
+
+// PolicyForPort returns the BackendTLSPolicy applying to a port of a Service among the policies of its namespace,
+// or nil. A policy targeting the port by sectionName takes precedence over the ones targeting the whole Service,
+// and the oldest policy wins between the ones targeting the port alike.
+func PolicyForPort(policies []*apiv1alpha3.BackendTLSPolicy, svc *corev1.Service, port corev1.ServicePort) *apiv1alpha3.BackendTLSPolicy {
+	var ret *apiv1alpha3.BackendTLSPolicy
+	retBySection := false
+	for _, policy := range policies {
+		targets, bySection := backendTLSTargets(policy, svc.Name, port)
+		if !targets || policy.Namespace != svc.Namespace {
+			continue
+		}
+		if ret == nil || (bySection && !retBySection) || (bySection == retBySection && compareRoutes(policy, ret) < 0) {
+			ret, retBySection = policy, bySection
+		}
+	}
+	return ret
+}
+
+// NewBackendTLSCollection builds the TLS origination of the TCP Service ports targeted by a BackendTLSPolicy,
+// keyed by the name of the cluster of the port
+func NewBackendTLSCollection(inputs Inputs, krtOpts krtutil.KrtOptions) krt.Collection[ir.BackendTLS] {
+	policiesByService := krt.NewIndex(inputs.BackendTLSPolicies, func(p *apiv1alpha3.BackendTLSPolicy) []types.NamespacedName {
+		var ret []types.NamespacedName
+		for _, ref := range p.Spec.TargetRefs {
+			key := types.NamespacedName{Namespace: p.Namespace, Name: string(ref.Name)}
+			if ref.Group == "" && ref.Kind == "Service" && !slices.Contains(ret, key) {
+				ret = append(ret, key)
+			}
+		}
+		return ret
+	})
+	return krt.NewManyCollection(inputs.Services, func(kctx krt.HandlerContext, svc *corev1.Service) []ir.BackendTLS {
+		policies := krt.Fetch(kctx, inputs.BackendTLSPolicies, krt.FilterIndex(policiesByService, types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}))
+		if len(policies) == 0 {
+			return nil
+		}
+		resolver := krtBackendTLSResolver(kctx, inputs.ConfigMaps, inputs.Secrets)
+		var ret []ir.BackendTLS
+		for _, port := range svc.Spec.Ports {
+			if cmp.Or(port.Protocol, corev1.ProtocolTCP) != corev1.ProtocolTCP {
+				continue
+			}
+			policy := PolicyForPort(policies, svc, port)
+			if policy == nil {
+				continue
+			}
+			tls := ir.BackendTLS{ClusterName: ClusterName(svc.Namespace, svc.Name, port.Port, corev1.ProtocolTCP)}
+			bundle, err := resolver.Resolve(policy)
+			if err == nil {
+				tls.TransportSocket, tls.CACertificates, err = upstreamTLS(policy, bundle)
+			}
+			if err != nil {
+				tls.Error = err.Error()
+			}
+			ret = append(ret, tls)
+		}
+		return ret
+	}, krtOpts.ApplyTo("BackendTLS")...)
+}
+
+// upstreamTLS builds the transport socket originating TLS to the backends of a BackendTLSPolicy, sending its
+// hostname as SNI and verifying the certificate of the backends against its subjectAltNames, or its hostname when
+// it has none. The CA certificates of the policy are served over SDS, so that they are rotated without draining
+// the connections to the backends.
+func upstreamTLS(policy *apiv1alpha3.BackendTLSPolicy, caBundle []byte) (*envoy_config_core_v3.TransportSocket, *envoy_extensions_transport_sockets_tls_v3.Secret, error) {
+	validation := policy.Spec.Validation
+	var sans []*envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher
+	for _, san := range validation.SubjectAltNames {
+		switch san.Type {
+		case apiv1alpha3.HostnameSubjectAltNameType:
+			sans = append(sans, sanMatcher(envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher_DNS, string(san.Hostname)))
+		case apiv1alpha3.URISubjectAltNameType:
+			sans = append(sans, sanMatcher(envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher_URI, string(san.URI)))
+		}
+	}
+	if len(sans) == 0 {
+		sans = append(sans, sanMatcher(envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher_DNS, string(validation.Hostname)))
+	}
+
+	tlsContext := &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext{}
+	var secret *envoy_extensions_transport_sockets_tls_v3.Secret
+	if caBundle == nil {
+		tlsContext.ValidationContextType = &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext_ValidationContext{
+			ValidationContext: &envoy_extensions_transport_sockets_tls_v3.CertificateValidationContext{
+				TrustedCa: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_Filename{Filename: systemCAFile},
+				},
+				MatchTypedSubjectAltNames: sans,
+			},
+		}
+	} else {
+		secret = &envoy_extensions_transport_sockets_tls_v3.Secret{
+			Name: BackendTLSSecretName(policy.Namespace, policy.Name),
+			Type: &envoy_extensions_transport_sockets_tls_v3.Secret_ValidationContext{
+				ValidationContext: &envoy_extensions_transport_sockets_tls_v3.CertificateValidationContext{
+					TrustedCa: &envoy_config_core_v3.DataSource{
+						Specifier: &envoy_config_core_v3.DataSource_InlineBytes{InlineBytes: caBundle},
+					},
+				},
+			},
+		}
+		tlsContext.ValidationContextType = &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext_CombinedValidationContext{
+			CombinedValidationContext: &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext_CombinedCertificateValidationContext{
+				DefaultValidationContext: &envoy_extensions_transport_sockets_tls_v3.CertificateValidationContext{
+					MatchTypedSubjectAltNames: sans,
+				},
+				ValidationContextSdsSecretConfig: &envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig{
+					Name:      secret.GetName(),
+					SdsConfig: adsConfigSource(),
+				},
+			},
+		}
+	}
+	upstreamTLSContext, err := anypb.New(&envoy_extensions_transport_sockets_tls_v3.UpstreamTlsContext{
+		CommonTlsContext: tlsContext,
+		Sni:              string(validation.Hostname),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &envoy_config_core_v3.TransportSocket{
+		Name:       wellknown.TransportSocketTls,
+		ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: upstreamTLSContext},
+	}, secret, nil
+}
+
+func sanMatcher(typ envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher_SanType, value string) *envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher {
+	return &envoy_extensions_transport_sockets_tls_v3.SubjectAltNameMatcher{
+		SanType: typ,
+		Matcher: &envoy_type_matcher_v3.StringMatcher{
+			MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: value},
+		},
+	}
+}
@@ -0,0 +1,183 @@
+package translator
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+)
+
+// backendTLSPolicy returns a policy of the default namespace targeting a Service, or one of its ports when
+// sectionName is set, verified against the CA certificates of the given ConfigMaps
+func backendTLSPolicy(name string, created int64, service, sectionName string, configMaps ...string) *apiv1alpha3.BackendTLSPolicy {
+	target := apiv1alpha2.LocalPolicyTargetReferenceWithSectionName{
+		LocalPolicyTargetReference: apiv1alpha2.LocalPolicyTargetReference{Kind: "Service", Name: api.ObjectName(service)},
+	}
+	if sectionName != "" {
+		target.SectionName = ptr.To(api.SectionName(sectionName))
+	}
+	policy := &apiv1alpha3.BackendTLSPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, CreationTimestamp: metav1.Unix(created, 0)},
+		Spec: apiv1alpha3.BackendTLSPolicySpec{
+			TargetRefs: []apiv1alpha2.LocalPolicyTargetReferenceWithSectionName{target},
+			Validation: apiv1alpha3.BackendTLSPolicyValidation{Hostname: "backend.example.com"},
+		},
+	}
+	for _, cm := range configMaps {
+		policy.Spec.Validation.CACertificateRefs = append(policy.Spec.Validation.CACertificateRefs, api.LocalObjectReference{
+			Kind: "ConfigMap",
+			Name: api.ObjectName(cm),
+		})
+	}
+	return policy
+}
+
+// caConfigMap returns a ConfigMap of the default namespace holding a CA certificate
+func caConfigMap(t *testing.T, name string) *corev1.ConfigMap {
+	t.Helper()
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Data:       map[string]string{caCertificateKey: string(tlsSecret(t, "default", name, "ca.example.com").Data[corev1.TLSCertKey])},
+	}
+}
+
+func TestBackendTLSResolver(t *testing.T) {
+	configMaps := []*corev1.ConfigMap{
+		caConfigMap(t, "ca"),
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "garbage"}, Data: map[string]string{caCertificateKey: "not a certificate"}},
+	}
+	secret := tlsSecret(t, "default", "ca-secret", "ca.example.com")
+	secret.Data[caCertificateKey] = secret.Data[corev1.TLSCertKey]
+	resolver := BackendTLSResolver{
+		GetConfigMap: func(namespace, name string) *corev1.ConfigMap {
+			for _, cm := range configMaps {
+				if cm.Namespace == namespace && cm.Name == name {
+					return cm
+				}
+			}
+			return nil
+		},
+		GetSecret: staticCertificates([]*corev1.Secret{secret}).GetSecret,
+	}
+	system := backendTLSPolicy("system", 0, "web", "")
+	system.Spec.Validation.WellKnownCACertificates = ptr.To(apiv1alpha3.WellKnownCACertificatesSystem)
+	fromSecret := backendTLSPolicy("secret", 0, "web", "")
+	fromSecret.Spec.Validation.CACertificateRefs = []api.LocalObjectReference{{Kind: "Secret", Name: "ca-secret"}}
+	otherKind := backendTLSPolicy("other-kind", 0, "web", "")
+	otherKind.Spec.Validation.CACertificateRefs = []api.LocalObjectReference{{Group: "example.com", Kind: "Bundle", Name: "ca"}}
+
+	tests := []struct {
+		name       string
+		policy     *apiv1alpha3.BackendTLSPolicy
+		wantBundle bool
+		wantErr    bool
+	}{
+		{name: "config map", policy: backendTLSPolicy("cm", 0, "web", "", "ca"), wantBundle: true},
+		{name: "secret", policy: fromSecret, wantBundle: true},
+		{name: "system", policy: system},
+		{name: "no CA", policy: backendTLSPolicy("none", 0, "web", ""), wantErr: true},
+		{name: "missing config map", policy: backendTLSPolicy("missing", 0, "web", "", "ca", "missing"), wantErr: true},
+		{name: "invalid certificate", policy: backendTLSPolicy("garbage", 0, "web", "", "garbage"), wantErr: true},
+		{name: "unsupported kind", policy: otherKind, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle, err := resolver.Resolve(tt.policy)
+			var policyErr *BackendTLSPolicyError
+			switch {
+			case !tt.wantErr && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr && (!errors.As(err, &policyErr) || policyErr.Reason != apiv1alpha2.PolicyReasonInvalid):
+				t.Errorf("error = %v, want an Invalid policy error", err)
+			}
+			if got := bundle != nil; got != tt.wantBundle {
+				t.Errorf("bundle = %q, want a bundle: %v", bundle, tt.wantBundle)
+			}
+		})
+	}
+}
+
+func TestPolicyForPort(t *testing.T) {
+	svc := testService("default", "web", 443, 8443)
+	svc.Spec.Ports[0].Name = "https"
+	svc.Spec.Ports[1].Name = "admin"
+	older := backendTLSPolicy("older", 1, "web", "")
+	newer := backendTLSPolicy("newer", 2, "web", "")
+	section := backendTLSPolicy("section", 3, "web", "https")
+	otherService := backendTLSPolicy("other", 0, "db", "")
+
+	tests := []struct {
+		name     string
+		policies []*apiv1alpha3.BackendTLSPolicy
+		port     int
+		want     string
+	}{
+		{name: "none", policies: []*apiv1alpha3.BackendTLSPolicy{otherService}},
+		{name: "oldest wins", policies: []*apiv1alpha3.BackendTLSPolicy{newer, older}, want: "older"},
+		{name: "section takes precedence", policies: []*apiv1alpha3.BackendTLSPolicy{older, section}, want: "section"},
+		{name: "section of another port", policies: []*apiv1alpha3.BackendTLSPolicy{older, section}, port: 1, want: "older"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PolicyForPort(tt.policies, svc, svc.Spec.Ports[tt.port])
+			if got == nil && tt.want != "" || got != nil && got.Name != tt.want {
+				t.Errorf("PolicyForPort() = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatewayCollectionBackendTLS(t *testing.T) {
+	gw := testGateway("gw", api.Listener{Name: "http", Port: 8080, Protocol: api.HTTPProtocolType})
+	gateways := translateGateways(t,
+		gw,
+		testService("default", "web", 443),
+		testService("default", "db", 443),
+		testService("default", "plain", 80),
+		caConfigMap(t, "ca"),
+		backendTLSPolicy("web", 0, "web", "", "ca"),
+		backendTLSPolicy("db", 0, "db", "", "missing"),
+		testHTTPRoute("route", "gw", nil, backendRef("web", 443), backendRef("db", 443), backendRef("plain", 80)),
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+	if got, want := resourceNames(out.Secrets), []string{BackendTLSSecretName("default", "web")}; !slices.Equal(got, want) {
+		t.Errorf("secrets = %v, want %v", got, want)
+	}
+	clusters := map[string]*envoy_config_cluster_v3.Cluster{}
+	for _, c := range out.Clusters {
+		clusters[c.GetName()] = c
+	}
+
+	web := clusters["kube_default_web_443"]
+	var tlsContext envoy_extensions_transport_sockets_tls_v3.UpstreamTlsContext
+	if err := web.GetTransportSocket().GetTypedConfig().UnmarshalTo(&tlsContext); err != nil {
+		t.Fatalf("transport socket of the web cluster = %v: %v", web.GetTransportSocket(), err)
+	}
+	if tlsContext.GetSni() != "backend.example.com" {
+		t.Errorf("sni = %q, want backend.example.com", tlsContext.GetSni())
+	}
+	combined := tlsContext.GetCommonTlsContext().GetCombinedValidationContext()
+	if got := combined.GetValidationContextSdsSecretConfig().GetName(); got != BackendTLSSecretName("default", "web") {
+		t.Errorf("validation context secret = %q, want the CA certificates of the policy", got)
+	}
+	if sans := combined.GetDefaultValidationContext().GetMatchTypedSubjectAltNames(); len(sans) != 1 || sans[0].GetMatcher().GetExact() != "backend.example.com" {
+		t.Errorf("subject alt names = %v, want the hostname of the policy", sans)
+	}
+
+	// the traffic to a backend whose policy can not be applied is not sent in cleartext
+	if db := clusters["kube_default_db_443"]; db.GetType() != envoy_config_cluster_v3.Cluster_STATIC || len(db.GetLoadAssignment().GetEndpoints()) != 0 {
+		t.Errorf("cluster of an invalid policy = %v, want a static cluster without endpoints", db)
+	}
+	if plain := clusters["kube_default_plain_80"]; plain.GetTransportSocket() != nil || plain.GetType() != envoy_config_cluster_v3.Cluster_EDS {
+		t.Errorf("cluster without policy = %v, want a cleartext EDS cluster", plain)
+	}
+}
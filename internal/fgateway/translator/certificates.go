@@ -0,0 +1,99 @@
+package translator
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// SecretName returns the name of the SDS secret holding the certificate of a kubernetes Secret
+func SecretName(namespace, name string) string {
+	return fmt.Sprintf("kube_secret_%s_%s", namespace, name)
+}
+
+// CertificateRefError is returned when a certificateRef of a listener can not be resolved. Reason is the reason of
+// the ResolvedRefs condition of the listener.
+type CertificateRefError struct {
+	Reason  api.ListenerConditionReason
+	Message string
+}
+
+func (e *CertificateRefError) Error() string {
+	return e.Message
+}
+
+// CertificateResolver resolves the certificateRefs of Gateway listeners to the TLS Secrets holding the certificates
+type CertificateResolver struct {
+	// GetSecret returns the TLS Secret with the given namespace and name, or nil if it does not exist
+	GetSecret func(namespace, name string) *corev1.Secret
+	// Grants allow the certificateRefs to Secrets of other namespaces
+	Grants ReferenceGrants
+}
+
+// krtCertificateResolver looks Secrets up in the collection, recording them as dependencies of the handler context
+func krtCertificateResolver(kctx krt.HandlerContext, secrets krt.Collection[*corev1.Secret], grants ReferenceGrants) CertificateResolver {
+	return CertificateResolver{
+		GetSecret: func(namespace, name string) *corev1.Secret {
+			return ptr.Deref(krt.FetchOne(kctx, secrets, krt.FilterObjectName(types.NamespacedName{Namespace: namespace, Name: name})), nil)
+		},
+		Grants: grants,
+	}
+}
+
+// Resolve returns the Secret a certificateRef of a listener of a Gateway in gatewayNamespace refers to, once checked
+// that it holds a certificate and its private key. The returned error is a *CertificateRefError.
+func (r CertificateResolver) Resolve(ref api.SecretObjectReference, gatewayNamespace string) (*corev1.Secret, error) {
+	if ptr.Deref(ref.Group, "") != "" || ptr.Deref(ref.Kind, "Secret") != "Secret" {
+		return nil, &CertificateRefError{
+			Reason:  api.ListenerReasonInvalidCertificateRef,
+			Message: fmt.Sprintf("certificate kind %s.%s is not supported", ptr.Deref(ref.Kind, "Secret"), ptr.Deref(ref.Group, "")),
+		}
+	}
+	ns := string(ptr.Deref(ref.Namespace, api.Namespace(gatewayNamespace)))
+	from := apiv1beta1.ReferenceGrantFrom{Group: api.GroupName, Kind: "Gateway", Namespace: api.Namespace(gatewayNamespace)}
+	if !r.Grants.Allowed(from, ns, apiv1beta1.ReferenceGrantTo{Kind: "Secret", Name: ptr.To(ref.Name)}) {
+		return nil, &CertificateRefError{
+			Reason:  api.ListenerReasonRefNotPermitted,
+			Message: fmt.Sprintf("certificate %s/%s is in another namespace and no ReferenceGrant allows it", ns, ref.Name),
+		}
+	}
+	secret := r.GetSecret(ns, string(ref.Name))
+	if secret == nil || secret.Type != corev1.SecretTypeTLS {
+		return nil, &CertificateRefError{
+			Reason:  api.ListenerReasonInvalidCertificateRef,
+			Message: fmt.Sprintf("TLS secret %s/%s not found", ns, ref.Name),
+		}
+	}
+	if _, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]); err != nil {
+		return nil, &CertificateRefError{
+			Reason:  api.ListenerReasonInvalidCertificateRef,
+			Message: fmt.Sprintf("TLS secret %s/%s does not hold a valid certificate: %v", ns, ref.Name, err),
+		}
+	}
+	return secret, nil
+}
+
+// tlsCertificateSecret is the SDS secret serving the certificate of a TLS Secret
+func tlsCertificateSecret(secret *corev1.Secret) *envoy_extensions_transport_sockets_tls_v3.Secret {
+	return &envoy_extensions_transport_sockets_tls_v3.Secret{
+		Name: SecretName(secret.Namespace, secret.Name),
+		Type: &envoy_extensions_transport_sockets_tls_v3.Secret_TlsCertificate{
+			TlsCertificate: &envoy_extensions_transport_sockets_tls_v3.TlsCertificate{
+				CertificateChain: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineBytes{InlineBytes: secret.Data[corev1.TLSCertKey]},
+				},
+				PrivateKey: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineBytes{InlineBytes: secret.Data[corev1.TLSPrivateKeyKey]},
+				},
+			},
+		},
+	}
+}
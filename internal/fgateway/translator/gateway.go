@@ -0,0 +1,360 @@
+package translator
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/ports"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"github.com/solo-io/go-utils/contextutils"
+	"go.uber.org/zap"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// NewGatewayCollection translates the Gateways whose GatewayClass has controllerName, or is one of classNames,
+// together with the routes attached to them, into the xds configuration of their proxies. A Gateway whose routes
+// have proxy selectors is translated once for every combination of the selectors, see ClientVariant.
+func NewGatewayCollection(
+	ctx context.Context,
+	inputs Inputs,
+	controllerName string,
+	classNames []string,
+	krtOpts krtutil.KrtOptions,
+) krt.Collection[ir.GatewayIR] {
+	logger := contextutils.LoggerFrom(ctx).Desugar()
+	names := sets.New(classNames...)
+	httpRoutesByGateway := krt.NewIndex(inputs.HTTPRoutes, func(r *api.HTTPRoute) []types.NamespacedName {
+		return ParentGateways(r.Namespace, r.Spec.ParentRefs)
+	})
+	grpcRoutesByGateway := krt.NewIndex(inputs.GRPCRoutes, func(r *api.GRPCRoute) []types.NamespacedName {
+		return ParentGateways(r.Namespace, r.Spec.ParentRefs)
+	})
+	tcpRoutesByGateway := krt.NewIndex(inputs.TCPRoutes, func(r *apiv1alpha2.TCPRoute) []types.NamespacedName {
+		return ParentGateways(r.Namespace, r.Spec.ParentRefs)
+	})
+	tlsRoutesByGateway := krt.NewIndex(inputs.TLSRoutes, func(r *apiv1alpha2.TLSRoute) []types.NamespacedName {
+		return ParentGateways(r.Namespace, r.Spec.ParentRefs)
+	})
+	udpRoutesByGateway := krt.NewIndex(inputs.UDPRoutes, func(r *apiv1alpha2.UDPRoute) []types.NamespacedName {
+		return ParentGateways(r.Namespace, r.Spec.ParentRefs)
+	})
+	grantsByNamespace := krt.NewNamespaceIndex(inputs.ReferenceGrants)
+	backendTLS := NewBackendTLSCollection(inputs, krtOpts)
+
+	return krt.NewManyCollection(inputs.Gateways, func(kctx krt.HandlerContext, gw *api.Gateway) []ir.GatewayIR {
+		if !names.Has(string(gw.Spec.GatewayClassName)) {
+			gwc := krt.FetchOne(kctx, inputs.GatewayClasses, krt.FilterObjectName(types.NamespacedName{Name: string(gw.Spec.GatewayClassName)}))
+			if gwc == nil || string((*gwc).Spec.ControllerName) != controllerName {
+				return nil
+			}
+		}
+
+		key := types.NamespacedName{Namespace: gw.Namespace, Name: gw.Name}
+		grants := krtReferenceGrants(kctx, inputs.ReferenceGrants, grantsByNamespace)
+		reports := ValidateListeners(gw, krtCertificateResolver(kctx, inputs.Secrets, grants))
+		backends := krtBackendResolver(kctx, inputs.Services, grants)
+		httpRoutes := sortRoutes(krt.Fetch(kctx, inputs.HTTPRoutes, krt.FilterIndex(httpRoutesByGateway, key)))
+		grpcRoutes := sortRoutes(krt.Fetch(kctx, inputs.GRPCRoutes, krt.FilterIndex(grpcRoutesByGateway, key)))
+		tcpRoutes := sortRoutes(krt.Fetch(kctx, inputs.TCPRoutes, krt.FilterIndex(tcpRoutesByGateway, key)))
+		tlsRoutes := sortRoutes(krt.Fetch(kctx, inputs.TLSRoutes, krt.FilterIndex(tlsRoutesByGateway, key)))
+		udpRoutes := sortRoutes(krt.Fetch(kctx, inputs.UDPRoutes, krt.FilterIndex(udpRoutesByGateway, key)))
+
+		var routes []metav1.Object
+		routes = appendObjects(routes, httpRoutes)
+		routes = appendObjects(routes, grpcRoutes)
+		routes = appendObjects(routes, tcpRoutes)
+		routes = appendObjects(routes, tlsRoutes)
+		routes = appendObjects(routes, udpRoutes)
+		selectors, dropped := proxySelectors(routes)
+		if len(dropped) > 0 {
+			logger.Error("ignoring the routes with invalid or too many distinct proxy selectors",
+				zap.Stringer("gateway", key), zap.Strings("selectors", dropped), zap.Int("max", maxProxySelectors))
+		}
+
+		ret := make([]ir.GatewayIR, 0, 1<<len(selectors))
+		for variant := range uint32(1 << len(selectors)) {
+			t := &gatewayTranslator{
+				kctx:     kctx,
+				inputs:   inputs,
+				gw:       gw,
+				reports:  reports,
+				backends: backends,
+				backendTLS: func(cluster string) *ir.BackendTLS {
+					return krt.FetchOne(kctx, backendTLS, krt.FilterKey(cluster))
+				},
+				httpRoutes: selectRoutes(httpRoutes, selectors, variant),
+				grpcRoutes: selectRoutes(grpcRoutes, selectors, variant),
+				tcpRoutes:  selectRoutes(tcpRoutes, selectors, variant),
+				tlsRoutes:  selectRoutes(tlsRoutes, selectors, variant),
+				udpRoutes:  selectRoutes(udpRoutes, selectors, variant),
+				clusters:   map[string]*envoy_config_cluster_v3.Cluster{},
+				secrets:    map[string]*envoy_extensions_transport_sockets_tls_v3.Secret{},
+			}
+			out, err := t.translate()
+			if err != nil {
+				logger.Error("failed to translate gateway", zap.Stringer("gateway", key), zap.Error(err))
+				return nil
+			}
+			out.ProxySelectors = selectors
+			out.Variant = variant
+			ret = append(ret, *out)
+		}
+		return ret
+	}, krtOpts.ApplyTo("GatewayIR")...)
+}
+
+// gatewayTranslator holds the state of the translation of a single Gateway
+type gatewayTranslator struct {
+	kctx     krt.HandlerContext
+	inputs   Inputs
+	gw       *api.Gateway
+	reports  []ListenerReport
+	backends BackendResolver
+	// backendTLS returns the TLS origination of a cluster, or nil when it is reached in cleartext
+	backendTLS func(cluster string) *ir.BackendTLS
+	httpRoutes []*api.HTTPRoute
+	grpcRoutes []*api.GRPCRoute
+	tcpRoutes  []*apiv1alpha2.TCPRoute
+	tlsRoutes  []*apiv1alpha2.TLSRoute
+	udpRoutes  []*apiv1alpha2.UDPRoute
+
+	// clusters are the clusters referenced by the translated routes, by name
+	clusters map[string]*envoy_config_cluster_v3.Cluster
+	// secrets are the SDS secrets referenced by the translated listeners, by name
+	secrets map[string]*envoy_extensions_transport_sockets_tls_v3.Secret
+}
+
+// listenerGroup is the set of valid Gateway listeners sharing a port and the protocol it is bound with, which are
+// served by a single envoy listener
+type listenerGroup struct {
+	port     api.PortNumber
+	protocol api.ProtocolType
+	// listeners are the indexes of the listeners in the Gateway spec
+	listeners []int
+}
+
+func (t *gatewayTranslator) translate() (*ir.GatewayIR, error) {
+	out := &ir.GatewayIR{Role: xds.GatewayProxyRole(t.gw.Namespace, t.gw.Name)}
+	for _, group := range t.listenerGroups() {
+		switch group.protocol {
+		case api.HTTPProtocolType, api.HTTPSProtocolType:
+			listener, routeConfig, err := t.translateHTTPListener(group)
+			if err != nil {
+				return nil, err
+			}
+			out.Listeners = append(out.Listeners, listener)
+			out.Routes = append(out.Routes, routeConfig)
+		case api.TCPProtocolType:
+			listener, err := t.translateTCPListener(group)
+			if err != nil {
+				return nil, err
+			}
+			if listener != nil {
+				out.Listeners = append(out.Listeners, listener)
+			}
+		case api.TLSProtocolType:
+			listener, err := t.translateTLSListener(group)
+			if err != nil {
+				return nil, err
+			}
+			if listener != nil {
+				out.Listeners = append(out.Listeners, listener)
+			}
+		case api.UDPProtocolType:
+			listener, err := t.translateUDPListener(group)
+			if err != nil {
+				return nil, err
+			}
+			if listener != nil {
+				out.Listeners = append(out.Listeners, listener)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(t.clusters)) {
+		out.Clusters = append(out.Clusters, t.clusters[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(t.secrets)) {
+		out.Secrets = append(out.Secrets, t.secrets[name])
+	}
+	return out, nil
+}
+
+// listenerGroups groups the valid listeners of the Gateway by port and bound protocol, in the order of the
+// listeners. Listeners sharing a group have the same protocol, as they are conflicted otherwise.
+func (t *gatewayTranslator) listenerGroups() []*listenerGroup {
+	var groups []*listenerGroup
+	for i, report := range t.reports {
+		if !report.Valid {
+			continue
+		}
+		l := t.gw.Spec.Listeners[i]
+		idx := slices.IndexFunc(groups, func(g *listenerGroup) bool {
+			return g.port == l.Port && ports.Protocol(g.protocol) == ports.Protocol(l.Protocol)
+		})
+		if idx == -1 {
+			groups = append(groups, &listenerGroup{port: l.Port, protocol: l.Protocol})
+			idx = len(groups) - 1
+		}
+		groups[idx].listeners = append(groups[idx].listeners, i)
+	}
+	return groups
+}
+
+// attachedListeners returns the indexes of the listeners a route attaches to through its parentRefs
+func (t *gatewayTranslator) attachedListeners(kind api.RouteGroupKind, routeNamespace string, parentRefs []api.ParentReference, hostnames []api.Hostname) sets.Set[int] {
+	var nsLabels map[string]string
+	if ns := krt.FetchOne(t.kctx, t.inputs.Namespaces, krt.FilterObjectName(types.NamespacedName{Name: routeNamespace})); ns != nil {
+		nsLabels = (*ns).Labels
+	}
+	attached := sets.New[int]()
+	for _, ref := range parentRefs {
+		if !ParentRefSelectsGateway(ref, routeNamespace, t.gw) {
+			continue
+		}
+		listeners, _ := ParentRefListeners(t.gw, t.reports, ref, kind, routeNamespace, nsLabels, hostnames)
+		attached.Insert(listeners...)
+	}
+	return attached
+}
+
+// addCluster records the cluster of a resolved backend so that it is sent along the routes referencing it. The
+// cluster originates TLS to the backends when a BackendTLSPolicy targets them. When the policy can not be applied,
+// the cluster has no endpoints rather than sending the traffic meant to be encrypted in cleartext.
+func (t *gatewayTranslator) addCluster(name string) {
+	if _, ok := t.clusters[name]; ok {
+		return
+	}
+	cluster := edsCluster(name)
+	if tls := t.backendTLS(name); tls != nil {
+		if tls.Error != "" {
+			cluster = staticCluster(name)
+		} else {
+			cluster.TransportSocket = tls.TransportSocket
+			if tls.CACertificates != nil {
+				t.secrets[tls.CACertificates.GetName()] = tls.CACertificates
+			}
+		}
+	}
+	t.clusters[name] = cluster
+}
+
+// addSecret records the SDS secret serving the certificate of a TLS Secret and returns its name
+func (t *gatewayTranslator) addSecret(secret *corev1.Secret) string {
+	name := SecretName(secret.Namespace, secret.Name)
+	if _, ok := t.secrets[name]; !ok {
+		t.secrets[name] = tlsCertificateSecret(secret)
+	}
+	return name
+}
+
+// addUDPCluster records the cluster of a resolved UDP backend. Its endpoints are picked by a consistent hash of
+// the datagrams, so that the sessions of a client stick to one endpoint.
+func (t *gatewayTranslator) addUDPCluster(name string) {
+	if _, ok := t.clusters[name]; !ok {
+		cluster := edsCluster(name)
+		cluster.LbPolicy = envoy_config_cluster_v3.Cluster_MAGLEV
+		t.clusters[name] = cluster
+	}
+}
+
+// useHTTP2 makes the proxy talk HTTP/2 to the backends of a cluster, which is required by gRPC. As clusters are
+// per Service port, HTTPRoutes sharing the backend of a GRPCRoute reach it over HTTP/2 as well.
+func (t *gatewayTranslator) useHTTP2(name string) error {
+	cluster, ok := t.clusters[name]
+	if !ok || cluster.GetTypedExtensionProtocolOptions() != nil {
+		return nil
+	}
+	opts, err := http2ProtocolOptions()
+	if err != nil {
+		return err
+	}
+	cluster.TypedExtensionProtocolOptions = opts
+	return nil
+}
+
+// listenerName is the name of the envoy listener, and of its route configuration, serving a Gateway port
+func listenerName(port api.PortNumber) string {
+	return fmt.Sprintf("listener~%d", port)
+}
+
+// ListenerResourceName returns the name of the envoy listener serving a Gateway listener, which is also the name of
+// the route configuration of the HTTP listeners
+func ListenerResourceName(l api.Listener) string {
+	if ports.Protocol(l.Protocol) == corev1.ProtocolUDP {
+		return udpListenerName(l.Port)
+	}
+	return listenerName(l.Port)
+}
+
+// udpListenerName is the name of the envoy listener serving a UDP Gateway port, which may share its number with
+// the listener of a TCP port
+func udpListenerName(port api.PortNumber) string {
+	return fmt.Sprintf("listener~%d~udp", port)
+}
+
+func listenerAddress(port api.PortNumber) *envoy_config_core_v3.Address {
+	return &envoy_config_core_v3.Address{
+		Address: &envoy_config_core_v3.Address_SocketAddress{
+			SocketAddress: &envoy_config_core_v3.SocketAddress{
+				Address:       "0.0.0.0",
+				PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{PortValue: uint32(ports.TranslatePort(uint16(port)))},
+			},
+		},
+	}
+}
+
+// ParentGateways returns the Gateways referenced by the parentRefs of a route in routeNamespace
+func ParentGateways(routeNamespace string, refs []api.ParentReference) []types.NamespacedName {
+	var ret []types.NamespacedName
+	for _, ref := range refs {
+		if ptr.Deref(ref.Group, api.GroupName) != api.GroupName || ptr.Deref(ref.Kind, "Gateway") != "Gateway" {
+			continue
+		}
+		key := types.NamespacedName{
+			Namespace: string(ptr.Deref(ref.Namespace, api.Namespace(routeNamespace))),
+			Name:      string(ref.Name),
+		}
+		if !slices.Contains(ret, key) {
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+// appendObjects appends routes of a kind to routes of any kind
+func appendObjects[T metav1.Object](objs []metav1.Object, routes []T) []metav1.Object {
+	for _, r := range routes {
+		objs = append(objs, r)
+	}
+	return objs
+}
+
+// sortRoutes orders routes by age, then by namespace and name, which is how the Gateway API breaks ties
+// between conflicting routes
+func sortRoutes[T metav1.Object](routes []T) []T {
+	slices.SortFunc(routes, func(a, b T) int { return compareRoutes(a, b) })
+	return routes
+}
+
+func compareRoutes(a, b metav1.Object) int {
+	return cmp.Or(
+		a.GetCreationTimestamp().Time.Compare(b.GetCreationTimestamp().Time),
+		cmp.Compare(a.GetNamespace(), b.GetNamespace()),
+		cmp.Compare(a.GetName(), b.GetName()),
+	)
+}
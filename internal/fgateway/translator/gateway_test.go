@@ -0,0 +1,244 @@
+package translator
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"istio.io/istio/pkg/kube/krt"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// testClassName is a GatewayClass of the controller, created by translateGateways
+const testClassName = "fgateway"
+
+// staticObjects returns a static collection of the objects of type T
+func staticObjects[T any](krtOpts krtutil.KrtOptions, objs []any) krt.Collection[T] {
+	var vals []T
+	for _, o := range objs {
+		if v, ok := o.(T); ok {
+			vals = append(vals, v)
+		}
+	}
+	return krt.NewStaticCollection(vals, krtOpts.ApplyTo("static")...)
+}
+
+// staticInputs returns inputs holding the given objects
+func staticInputs(krtOpts krtutil.KrtOptions, objs []any) Inputs {
+	return Inputs{
+		GatewayClasses:     staticObjects[*api.GatewayClass](krtOpts, objs),
+		Gateways:           staticObjects[*api.Gateway](krtOpts, objs),
+		HTTPRoutes:         staticObjects[*api.HTTPRoute](krtOpts, objs),
+		GRPCRoutes:         staticObjects[*api.GRPCRoute](krtOpts, objs),
+		TCPRoutes:          staticObjects[*apiv1alpha2.TCPRoute](krtOpts, objs),
+		TLSRoutes:          staticObjects[*apiv1alpha2.TLSRoute](krtOpts, objs),
+		UDPRoutes:          staticObjects[*apiv1alpha2.UDPRoute](krtOpts, objs),
+		Namespaces:         staticObjects[*corev1.Namespace](krtOpts, objs),
+		Services:           staticObjects[*corev1.Service](krtOpts, objs),
+		EndpointSlices:     staticObjects[*discoveryv1.EndpointSlice](krtOpts, objs),
+		Secrets:            staticObjects[*corev1.Secret](krtOpts, objs),
+		ConfigMaps:         staticObjects[*corev1.ConfigMap](krtOpts, objs),
+		ReferenceGrants:    staticObjects[*apiv1beta1.ReferenceGrant](krtOpts, objs),
+		BackendTLSPolicies: staticObjects[*apiv1alpha3.BackendTLSPolicy](krtOpts, objs),
+	}
+}
+
+// translateGateways translates the Gateways among objs, along with a GatewayClass of the controller named
+// testClassName, keyed by resource name, which is the role of a Gateway for the proxies its proxy selectors
+// do not match
+func translateGateways(t *testing.T, objs ...any) map[string]ir.GatewayIR {
+	t.Helper()
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	krtOpts := krtutil.NewKrtOptions(stop, nil)
+
+	objs = append(objs, &api.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: testClassName},
+		Spec:       api.GatewayClassSpec{ControllerName: wellknown.GatewayControllerName},
+	})
+	gateways := NewGatewayCollection(context.Background(), staticInputs(krtOpts, objs), wellknown.GatewayControllerName, nil, krtOpts)
+	if !gateways.WaitUntilSynced(stop) {
+		t.Fatal("gateway collection did not sync")
+	}
+	byName := map[string]ir.GatewayIR{}
+	for _, gw := range gateways.List() {
+		byName[gw.ResourceName()] = gw
+	}
+	return byName
+}
+
+func testGateway(name string, listeners ...api.Listener) *api.Gateway {
+	return &api.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       api.GatewaySpec{GatewayClassName: testClassName, Listeners: listeners},
+	}
+}
+
+func testService(namespace, name string, ports ...int32) *corev1.Service {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for _, p := range ports {
+		svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{Port: p, Protocol: corev1.ProtocolTCP})
+	}
+	return svc
+}
+
+func backendRef(name string, port api.PortNumber) api.HTTPBackendRef {
+	return api.HTTPBackendRef{BackendRef: api.BackendRef{BackendObjectReference: api.BackendObjectReference{
+		Name: api.ObjectName(name),
+		Port: ptr.To(port),
+	}}}
+}
+
+// testHTTPRoute returns an HTTPRoute in the default namespace attached to the Gateway gw, with a rule per
+// backendRef
+func testHTTPRoute(name, gw string, hostnames []api.Hostname, refs ...api.HTTPBackendRef) *api.HTTPRoute {
+	route := &api.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: api.HTTPRouteSpec{
+			CommonRouteSpec: api.CommonRouteSpec{ParentRefs: []api.ParentReference{{Name: api.ObjectName(gw)}}},
+			Hostnames:       hostnames,
+		},
+	}
+	for _, ref := range refs {
+		route.Spec.Rules = append(route.Spec.Rules, api.HTTPRouteRule{BackendRefs: []api.HTTPBackendRef{ref}})
+	}
+	return route
+}
+
+func resourceNames[T interface{ GetName() string }](resources []T) []string {
+	var ret []string
+	for _, r := range resources {
+		ret = append(ret, r.GetName())
+	}
+	return ret
+}
+
+// vhostDomains returns the routes of every domain of a route configuration, by envoy route name
+func vhostDomains(rc *envoy_config_route_v3.RouteConfiguration) map[string][]string {
+	ret := map[string][]string{}
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, domain := range vh.GetDomains() {
+			ret[domain] = resourceNames(vh.GetRoutes())
+		}
+	}
+	return ret
+}
+
+func TestGatewayCollectionClasses(t *testing.T) {
+	http := api.Listener{Name: "http", Port: 80, Protocol: api.HTTPProtocolType}
+	ours := testGateway("ours", http)
+	other := testGateway("other", http)
+	other.Spec.GatewayClassName = "other"
+	missing := testGateway("missing", http)
+	missing.Spec.GatewayClassName = "missing"
+
+	gateways := translateGateways(t, ours, other, missing,
+		&api.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "other"},
+			Spec:       api.GatewayClassSpec{ControllerName: "example.com/other"},
+		},
+	)
+	if _, ok := gateways[xds.GatewayProxyRole("default", "ours")]; !ok {
+		t.Errorf("gateway of a class of the controller is not translated")
+	}
+	if len(gateways) != 1 {
+		t.Errorf("translated %d gateways, want only the one of a class of the controller", len(gateways))
+	}
+}
+
+func TestGatewayCollectionHTTP(t *testing.T) {
+	gw := testGateway("gw",
+		api.Listener{Name: "http", Port: 80, Protocol: api.HTTPProtocolType},
+		api.Listener{Name: "api", Port: 8081, Protocol: api.HTTPProtocolType, Hostname: ptr.To[api.Hostname]("*.example.com")},
+		// conflicted listeners are left out of the configuration
+		api.Listener{Name: "dup-a", Port: 9000, Protocol: api.HTTPProtocolType},
+		api.Listener{Name: "dup-b", Port: 9000, Protocol: api.HTTPProtocolType},
+	)
+	gateways := translateGateways(t,
+		gw,
+		testService("default", "web", 8080),
+		testService("default", "api", 9090),
+		testHTTPRoute("web", "gw", nil, backendRef("web", 8080)),
+		testHTTPRoute("api", "gw", []api.Hostname{"a.example.com", "other.io"}, backendRef("api", 9090)),
+		testHTTPRoute("other", "gw", []api.Hostname{"other.io"}, backendRef("api", 9090)),
+		testHTTPRoute("missing", "gw", []api.Hostname{"missing.example.com"}, backendRef("missing", 80)),
+		testHTTPRoute("unattached", "another-gw", nil, backendRef("web", 8080)),
+	)
+	out, ok := gateways[xds.GatewayProxyRole("default", "gw")]
+	if !ok {
+		t.Fatal("gateway is not translated")
+	}
+
+	if got, want := resourceNames(out.Listeners), []string{"listener~80", "listener~8081"}; !slices.Equal(got, want) {
+		t.Errorf("listeners = %v, want %v", got, want)
+	}
+	if got := out.Listeners[0].GetAddress().GetSocketAddress().GetPortValue(); got != 8080 {
+		t.Errorf("privileged port is bound on %d, want 8080", got)
+	}
+	if got, want := resourceNames(out.Clusters), []string{"kube_default_api_9090", "kube_default_web_8080"}; !slices.Equal(got, want) {
+		t.Errorf("clusters = %v, want %v", got, want)
+	}
+
+	routes := map[string]map[string][]string{}
+	for _, rc := range out.Routes {
+		routes[rc.GetName()] = vhostDomains(rc)
+	}
+	want := map[string]map[string][]string{
+		"listener~80": {
+			"*":                   {"default/web~0~0"},
+			"a.example.com":       {"default/api~0~0"},
+			"missing.example.com": {"default/missing~0~0"},
+			"other.io":            {"default/api~0~0", "default/other~0~0"},
+		},
+		"listener~8081": {
+			"*.example.com":       {"default/web~0~0"},
+			"a.example.com":       {"default/api~0~0"},
+			"missing.example.com": {"default/missing~0~0"},
+		},
+	}
+	for name, domains := range want {
+		for domain, wantRoutes := range domains {
+			if got := routes[name][domain]; !slices.Equal(got, wantRoutes) {
+				t.Errorf("routes of %s on %s = %v, want %v", domain, name, got, wantRoutes)
+			}
+		}
+		if len(routes[name]) != len(domains) {
+			t.Errorf("domains of %s = %v, want %v", name, routes[name], domains)
+		}
+	}
+
+	for _, rc := range out.Routes {
+		for _, vh := range rc.GetVirtualHosts() {
+			for _, r := range vh.GetRoutes() {
+				if r.GetName() == "default/missing~0~0" && r.GetDirectResponse().GetStatus() != 500 {
+					t.Errorf("route to a missing backend = %v, want a 500 direct response", r.GetAction())
+				}
+			}
+		}
+	}
+}
+
+func TestGatewayCollectionInvalidListeners(t *testing.T) {
+	gw := testGateway("gw", httpsListener("https", 443, api.SecretObjectReference{Name: "missing"}))
+	gateways := translateGateways(t, gw, testHTTPRoute("web", "gw", nil, backendRef("web", 8080)))
+	out, ok := gateways[xds.GatewayProxyRole("default", "gw")]
+	if !ok {
+		t.Fatal("gateway is not translated")
+	}
+	if len(out.Listeners) != 0 || len(out.Routes) != 0 || len(out.Clusters) != 0 {
+		t.Errorf("gateway without valid listener = %d listeners, %d routes, %d clusters, want none",
+			len(out.Listeners), len(out.Routes), len(out.Clusters))
+	}
+}
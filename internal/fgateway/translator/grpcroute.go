@@ -0,0 +1,121 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/samber/lo"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// grpcNameRegex matches any gRPC service or method name in a regular expression method match
+const grpcNameRegex = "[^/]+"
+
+// translateGRPCRoute builds an envoy route for every match of every rule of the GRPCRoute. The filters and
+// backends of a rule are those of an HTTPRoute rule, and its backends are sent HTTP/2.
+func (t *gatewayTranslator) translateGRPCRoute(route *api.GRPCRoute) ([]httpRouteEntry, error) {
+	var ret []httpRouteEntry
+	for i, rule := range route.Spec.Rules {
+		httpRule := api.HTTPRouteRule{
+			Filters: lo.Map(rule.Filters, func(f api.GRPCRouteFilter, _ int) api.HTTPRouteFilter {
+				return api.HTTPRouteFilter{
+					Type:                   api.HTTPRouteFilterType(f.Type),
+					RequestHeaderModifier:  f.RequestHeaderModifier,
+					ResponseHeaderModifier: f.ResponseHeaderModifier,
+					RequestMirror:          f.RequestMirror,
+					ExtensionRef:           f.ExtensionRef,
+				}
+			}),
+			BackendRefs: lo.Map(rule.BackendRefs, func(ref api.GRPCBackendRef, _ int) api.HTTPBackendRef {
+				return api.HTTPBackendRef{BackendRef: ref.BackendRef}
+			}),
+		}
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []api.GRPCRouteMatch{{}}
+		}
+		for j, match := range matches {
+			envoyRoute := t.httpRuleRoute(GRPCRouteKind, route.Namespace, httpRule, api.HTTPRouteMatch{})
+			envoyRoute.Name = RouteResourceName(route.Namespace, route.Name, i, j)
+			routeMatch, pathRank, pathLen := grpcRouteMatch(match)
+			envoyRoute.Match = routeMatch
+			for _, cluster := range routeClusters(envoyRoute) {
+				if err := t.useHTTP2(cluster); err != nil {
+					return nil, err
+				}
+			}
+			ret = append(ret, httpRouteEntry{
+				pathRank: pathRank,
+				pathLen:  pathLen,
+				headers:  len(match.Headers),
+				owner:    route,
+				ruleIdx:  i,
+				matchIdx: j,
+				route:    envoyRoute,
+			})
+		}
+	}
+	return ret, nil
+}
+
+// grpcRouteMatch translates a GRPCRoute match to a match on the /service/method path of gRPC requests. It also
+// returns the rank and length of the path match, which order the route as an HTTPRoute path match would.
+func grpcRouteMatch(match api.GRPCRouteMatch) (*envoy_config_route_v3.RouteMatch, int, int) {
+	ret := &envoy_config_route_v3.RouteMatch{}
+	for _, h := range match.Headers {
+		ret.Headers = append(ret.Headers, &envoy_config_route_v3.HeaderMatcher{
+			Name: strings.ToLower(string(h.Name)),
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{
+				StringMatch: stringMatcher(ptr.Deref(h.Type, api.GRPCHeaderMatchExact) == api.GRPCHeaderMatchRegularExpression, h.Value),
+			},
+		})
+	}
+
+	method := ptr.Deref(match.Method, api.GRPCMethodMatch{})
+	service, name := ptr.Deref(method.Service, ""), ptr.Deref(method.Method, "")
+	if ptr.Deref(method.Type, api.GRPCMethodMatchExact) == api.GRPCMethodMatchRegularExpression {
+		regex := fmt.Sprintf("/%s/%s", lo.CoalesceOrEmpty(service, grpcNameRegex), lo.CoalesceOrEmpty(name, grpcNameRegex))
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_SafeRegex{SafeRegex: &envoy_type_matcher_v3.RegexMatcher{Regex: regex}}
+		return ret, 2, len(service) + len(name)
+	}
+	switch {
+	case service != "" && name != "":
+		path := fmt.Sprintf("/%s/%s", service, name)
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_Path{Path: path}
+		return ret, 0, len(path)
+	case service != "":
+		prefix := fmt.Sprintf("/%s/", service)
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_Prefix{Prefix: prefix}
+		return ret, 1, len(prefix)
+	case name != "":
+		regex := fmt.Sprintf("/%s/%s", grpcNameRegex, regexp.QuoteMeta(name))
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_SafeRegex{SafeRegex: &envoy_type_matcher_v3.RegexMatcher{Regex: regex}}
+		return ret, 2, len(name)
+	default:
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_Prefix{Prefix: "/"}
+		return ret, 1, 1
+	}
+}
+
+// routeClusters returns the clusters an envoy route forwards or mirrors requests to
+func routeClusters(route *envoy_config_route_v3.Route) []string {
+	action := route.GetRoute()
+	if action == nil {
+		return nil
+	}
+	var ret []string
+	if c := action.GetCluster(); c != "" {
+		ret = append(ret, c)
+	}
+	for _, c := range action.GetWeightedClusters().GetClusters() {
+		ret = append(ret, c.GetName())
+	}
+	for _, m := range action.GetRequestMirrorPolicies() {
+		ret = append(ret, m.GetCluster())
+	}
+	return ret
+}
@@ -0,0 +1,133 @@
+package translator
+
+import (
+	"slices"
+	"testing"
+
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestGRPCRouteMatch(t *testing.T) {
+	method := func(typ api.GRPCMethodMatchType, service, name string) *api.GRPCMethodMatch {
+		m := &api.GRPCMethodMatch{Type: ptr.To(typ)}
+		if service != "" {
+			m.Service = ptr.To(service)
+		}
+		if name != "" {
+			m.Method = ptr.To(name)
+		}
+		return m
+	}
+	tests := []struct {
+		name  string
+		match api.GRPCRouteMatch
+		// path, prefix and regex are the expected path specifier, only one of which is set
+		path, prefix, regex   string
+		wantRank, wantPathLen int
+	}{
+		{name: "any", prefix: "/", wantRank: 1, wantPathLen: 1},
+		{
+			name:     "service and method",
+			match:    api.GRPCRouteMatch{Method: method(api.GRPCMethodMatchExact, "helloworld.Greeter", "SayHello")},
+			path:     "/helloworld.Greeter/SayHello",
+			wantRank: 0, wantPathLen: len("/helloworld.Greeter/SayHello"),
+		},
+		{
+			name:     "service",
+			match:    api.GRPCRouteMatch{Method: method(api.GRPCMethodMatchExact, "helloworld.Greeter", "")},
+			prefix:   "/helloworld.Greeter/",
+			wantRank: 1, wantPathLen: len("/helloworld.Greeter/"),
+		},
+		{
+			name:     "method of any service",
+			match:    api.GRPCRouteMatch{Method: method(api.GRPCMethodMatchExact, "", "Say.Hello")},
+			regex:    `/[^/]+/Say\.Hello`,
+			wantRank: 2, wantPathLen: len("Say.Hello"),
+		},
+		{
+			name:     "regular expressions",
+			match:    api.GRPCRouteMatch{Method: method(api.GRPCMethodMatchRegularExpression, `helloworld\..*`, "")},
+			regex:    `/helloworld\..*/[^/]+`,
+			wantRank: 2, wantPathLen: len(`helloworld\..*`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rank, pathLen := grpcRouteMatch(tt.match)
+			if got.GetPath() != tt.path || got.GetPrefix() != tt.prefix || got.GetSafeRegex().GetRegex() != tt.regex {
+				t.Errorf("path specifier = %v, want path %q, prefix %q or regex %q", got.GetPathSpecifier(), tt.path, tt.prefix, tt.regex)
+			}
+			if rank != tt.wantRank || pathLen != tt.wantPathLen {
+				t.Errorf("rank and path length = %d, %d, want %d, %d", rank, pathLen, tt.wantRank, tt.wantPathLen)
+			}
+		})
+	}
+}
+
+func TestGRPCRouteMatchHeaders(t *testing.T) {
+	got, _, _ := grpcRouteMatch(api.GRPCRouteMatch{Headers: []api.GRPCHeaderMatch{
+		{Name: "X-Tenant", Value: "a"},
+		{Type: ptr.To(api.GRPCHeaderMatchRegularExpression), Name: "X-Version", Value: "v[12]"},
+	}})
+	headers := got.GetHeaders()
+	if len(headers) != 2 {
+		t.Fatalf("headers = %v, want two", headers)
+	}
+	if headers[0].GetName() != "x-tenant" || headers[0].GetStringMatch().GetExact() != "a" {
+		t.Errorf("exact header match = %v", headers[0])
+	}
+	if headers[1].GetName() != "x-version" || headers[1].GetStringMatch().GetSafeRegex().GetRegex() != "v[12]" {
+		t.Errorf("regular expression header match = %v", headers[1])
+	}
+}
+
+func TestGatewayCollectionGRPCRoutes(t *testing.T) {
+	greeter := &api.GRPCRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "greeter"},
+		Spec: api.GRPCRouteSpec{
+			CommonRouteSpec: api.CommonRouteSpec{ParentRefs: []api.ParentReference{{Name: "gw"}}},
+			Rules: []api.GRPCRouteRule{{
+				Matches: []api.GRPCRouteMatch{{Method: &api.GRPCMethodMatch{Service: ptr.To("helloworld.Greeter"), Method: ptr.To("SayHello")}}},
+				BackendRefs: []api.GRPCBackendRef{{BackendRef: api.BackendRef{BackendObjectReference: api.BackendObjectReference{
+					Name: "grpc", Port: ptr.To[api.PortNumber](9000),
+				}}}},
+			}},
+		},
+	}
+	gateways := translateGateways(t,
+		testGateway("gw", api.Listener{Name: "http", Port: 8080, Protocol: api.HTTPProtocolType}),
+		testService("default", "grpc", 9000),
+		testService("default", "web", 8080),
+		greeter,
+		testHTTPRoute("web", "gw", nil, backendRef("web", 8080)),
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+	if len(out.Routes) != 1 {
+		t.Fatalf("route configurations = %v, want one", out.Routes)
+	}
+
+	// the exact gRPC method is more precise than the path prefix of the HTTPRoute
+	if got, want := vhostDomains(out.Routes[0])["*"], []string{"default/greeter~0~0", "default/web~0~0"}; !slices.Equal(got, want) {
+		t.Errorf("routes = %v, want %v", got, want)
+	}
+	var greeterRoute *envoy_config_route_v3.Route
+	for _, r := range out.Routes[0].GetVirtualHosts()[0].GetRoutes() {
+		if r.GetName() == "default/greeter~0~0" {
+			greeterRoute = r
+		}
+	}
+	if greeterRoute.GetMatch().GetPath() != "/helloworld.Greeter/SayHello" || greeterRoute.GetRoute().GetCluster() != "kube_default_grpc_9000" {
+		t.Errorf("gRPC route = %v, want the method path forwarded to the grpc Service", greeterRoute)
+	}
+
+	for _, c := range out.Clusters {
+		http2 := c.GetTypedExtensionProtocolOptions() != nil
+		if want := c.GetName() == "kube_default_grpc_9000"; http2 != want {
+			t.Errorf("cluster %s speaks HTTP/2: %v, want %v", c.GetName(), http2, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package translator
+
+import (
+	"strings"
+
+	"github.com/samber/lo"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// anyHost is the virtual host domain serving requests for every host
+const anyHost = "*"
+
+// routeHostnames returns the hostnames a route serves on a listener, narrowing every route hostname that
+// matches the listener hostname to the most specific of the two. It returns nil when no route hostname
+// matches the listener hostname.
+func routeHostnames(listenerHostname *api.Hostname, hostnames []api.Hostname) []string {
+	if listenerHostname == nil || *listenerHostname == "" {
+		if len(hostnames) == 0 {
+			return []string{anyHost}
+		}
+		return lo.Uniq(lo.Map(hostnames, func(h api.Hostname, _ int) string { return string(h) }))
+	}
+	listener := string(*listenerHostname)
+	if len(hostnames) == 0 {
+		return []string{listener}
+	}
+
+	var ret []string
+	for _, h := range hostnames {
+		route := string(h)
+		switch {
+		case hostnameMatches(listener, route):
+			// the listener hostname is the more specific one
+			ret = append(ret, listener)
+		case hostnameMatches(route, listener):
+			ret = append(ret, route)
+		}
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return lo.Uniq(ret)
+}
+
+// hostnameMatches reports whether every host served by hostname is also served by the pattern, which is
+// either an exact hostname or a wildcard of the form *.domain
+func hostnameMatches(hostname, pattern string) bool {
+	if hostname == pattern {
+		return true
+	}
+	suffix, wildcard := strings.CutPrefix(pattern, "*")
+	if !wildcard {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimPrefix(hostname, "*"), suffix) && len(strings.TrimPrefix(hostname, "*")) > len(suffix)
+}
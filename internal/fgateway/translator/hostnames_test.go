@@ -0,0 +1,72 @@
+package translator
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestRouteHostnames(t *testing.T) {
+	tests := []struct {
+		name      string
+		listener  *api.Hostname
+		hostnames []api.Hostname
+		want      []string
+	}{
+		{name: "no hostnames", want: []string{"*"}},
+		{name: "empty listener hostname", listener: ptr.To(api.Hostname("")), want: []string{"*"}},
+		{
+			name:      "route hostnames on a listener without hostname",
+			hostnames: []api.Hostname{"a.example.com", "b.example.com", "a.example.com"},
+			want:      []string{"a.example.com", "b.example.com"},
+		},
+		{name: "listener hostname only", listener: ptr.To(api.Hostname("a.example.com")), want: []string{"a.example.com"}},
+		{
+			name:      "exact match",
+			listener:  ptr.To(api.Hostname("a.example.com")),
+			hostnames: []api.Hostname{"a.example.com"},
+			want:      []string{"a.example.com"},
+		},
+		{
+			name:      "route hostname narrows a wildcard listener",
+			listener:  ptr.To(api.Hostname("*.example.com")),
+			hostnames: []api.Hostname{"a.example.com", "other.com"},
+			want:      []string{"a.example.com"},
+		},
+		{
+			name:      "listener hostname narrows a wildcard route",
+			listener:  ptr.To(api.Hostname("a.example.com")),
+			hostnames: []api.Hostname{"*.example.com"},
+			want:      []string{"a.example.com"},
+		},
+		{
+			name:      "nested wildcards",
+			listener:  ptr.To(api.Hostname("*.example.com")),
+			hostnames: []api.Hostname{"*.a.example.com"},
+			want:      []string{"*.a.example.com"},
+		},
+		{
+			name:      "wildcard does not match its own domain",
+			listener:  ptr.To(api.Hostname("*.example.com")),
+			hostnames: []api.Hostname{"example.com"},
+		},
+		{
+			name:      "no route hostname matches",
+			listener:  ptr.To(api.Hostname("*.example.com")),
+			hostnames: []api.Hostname{"other.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := routeHostnames(tt.listener, tt.hostnames)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("routeHostnames() = %v, want %v", got, tt.want)
+			}
+			if tt.want == nil && got != nil {
+				t.Errorf("routeHostnames() = %#v, want nil so that the route does not attach", got)
+			}
+		})
+	}
+}
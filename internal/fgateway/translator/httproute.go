@@ -0,0 +1,580 @@
+package translator
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_router_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	envoy_extensions_filters_listener_tls_inspector_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/tls_inspector/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// invalidBackendCluster is the cluster the share of traffic of an unresolved backendRef is sent to. It is never
+// sent to the proxy, which answers the requests routed to it with a 500, and drops the requests mirrored to it.
+const invalidBackendCluster = "invalid_backend"
+
+var redirectResponseCodes = map[int]envoy_config_route_v3.RedirectAction_RedirectResponseCode{
+	301: envoy_config_route_v3.RedirectAction_MOVED_PERMANENTLY,
+	302: envoy_config_route_v3.RedirectAction_FOUND,
+	303: envoy_config_route_v3.RedirectAction_SEE_OTHER,
+	307: envoy_config_route_v3.RedirectAction_TEMPORARY_REDIRECT,
+	308: envoy_config_route_v3.RedirectAction_PERMANENT_REDIRECT,
+}
+
+// translateHTTPListener builds the envoy listener serving a group of HTTP listeners, and its route configuration
+// with a virtual host per hostname served by the routes attached to the listeners
+func (t *gatewayTranslator) translateHTTPListener(group *listenerGroup) (*envoy_config_listener_v3.Listener, *envoy_config_route_v3.RouteConfiguration, error) {
+	name := listenerName(group.port)
+	vhosts, err := t.httpVirtualHosts(name, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	routeConfig := &envoy_config_route_v3.RouteConfiguration{
+		Name:         name,
+		VirtualHosts: vhosts,
+	}
+
+	router, err := anypb.New(&envoy_extensions_filters_http_router_v3.Router{})
+	if err != nil {
+		return nil, nil, err
+	}
+	hcm, err := anypb.New(&envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{
+		StatPrefix: name,
+		CodecType:  envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_AUTO,
+		RouteSpecifier: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Rds{
+			Rds: &envoy_extensions_filters_network_http_connection_manager_v3.Rds{
+				ConfigSource:    adsConfigSource(),
+				RouteConfigName: name,
+			},
+		},
+		HttpFilters: []*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{{
+			Name:       wellknown.Router,
+			ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{TypedConfig: router},
+		}},
+		// hostnames are matched without the port the client may have put in the host header
+		StripPortMode: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_StripAnyHostPort{
+			StripAnyHostPort: true,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filter := &envoy_config_listener_v3.Filter{
+		Name:       wellknown.HTTPConnectionManager,
+		ConfigType: &envoy_config_listener_v3.Filter_TypedConfig{TypedConfig: hcm},
+	}
+	listener := &envoy_config_listener_v3.Listener{
+		Name:    name,
+		Address: listenerAddress(group.port),
+	}
+	if group.protocol != api.HTTPSProtocolType {
+		listener.FilterChains = []*envoy_config_listener_v3.FilterChain{{Filters: []*envoy_config_listener_v3.Filter{filter}}}
+		return listener, routeConfig, nil
+	}
+
+	// HTTPS listeners get a filter chain per Gateway listener, selected by SNI
+	for _, idx := range group.listeners {
+		chain, err := t.httpsFilterChain(idx, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		listener.FilterChains = append(listener.FilterChains, chain)
+	}
+	tlsInspector, err := anypb.New(&envoy_extensions_filters_listener_tls_inspector_v3.TlsInspector{})
+	if err != nil {
+		return nil, nil, err
+	}
+	listener.ListenerFilters = []*envoy_config_listener_v3.ListenerFilter{{
+		Name:       wellknown.TlsInspector,
+		ConfigType: &envoy_config_listener_v3.ListenerFilter_TypedConfig{TypedConfig: tlsInspector},
+	}}
+	return listener, routeConfig, nil
+}
+
+// httpsFilterChain terminates the TLS connections to the hostname of a Gateway listener with its certificates,
+// which are served over SDS so that they are rotated without draining the listener. When several certificates
+// are set, the proxy presents the one matching the SNI of the client.
+func (t *gatewayTranslator) httpsFilterChain(idx int, filter *envoy_config_listener_v3.Filter) (*envoy_config_listener_v3.FilterChain, error) {
+	l := t.gw.Spec.Listeners[idx]
+	tlsContext := &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext{
+		AlpnProtocols: []string{"h2", "http/1.1"},
+	}
+	for _, secret := range t.reports[idx].Certificates {
+		tlsContext.TlsCertificateSdsSecretConfigs = append(tlsContext.TlsCertificateSdsSecretConfigs, &envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig{
+			Name:      t.addSecret(secret),
+			SdsConfig: adsConfigSource(),
+		})
+	}
+	transportSocket, err := anypb.New(&envoy_extensions_transport_sockets_tls_v3.DownstreamTlsContext{CommonTlsContext: tlsContext})
+	if err != nil {
+		return nil, err
+	}
+	chain := &envoy_config_listener_v3.FilterChain{
+		Name:    string(l.Name),
+		Filters: []*envoy_config_listener_v3.Filter{filter},
+		TransportSocket: &envoy_config_core_v3.TransportSocket{
+			Name:       wellknown.TransportSocketTls,
+			ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: transportSocket},
+		},
+	}
+	// the chain of a listener without hostname serves the connections matching no other chain
+	if l.Hostname != nil && *l.Hostname != "" {
+		chain.FilterChainMatch = &envoy_config_listener_v3.FilterChainMatch{ServerNames: []string{string(*l.Hostname)}}
+	}
+	return chain, nil
+}
+
+// httpRouteEntry is an envoy route translated from a match of an HTTPRoute or GRPCRoute rule, along with what is
+// needed to order it among the other routes of its virtual host
+type httpRouteEntry struct {
+	// pathRank is 0 for an exact path match, 1 for a prefix match and 2 for a regular expression
+	pathRank    int
+	pathLen     int
+	method      bool
+	headers     int
+	queryParams int
+
+	// owner is the route the entry is translated from
+	owner    metav1.Object
+	ruleIdx  int
+	matchIdx int
+
+	route *envoy_config_route_v3.Route
+}
+
+// httpVirtualHosts groups the rules of the HTTPRoutes and GRPCRoutes attached to the listeners by the hostnames
+// they serve
+func (t *gatewayTranslator) httpVirtualHosts(listenerName string, group *listenerGroup) ([]*envoy_config_route_v3.VirtualHost, error) {
+	entriesByHost := map[string][]httpRouteEntry{}
+	addRoute := func(kind api.RouteGroupKind, obj metav1.Object, parentRefs []api.ParentReference, hostnames []api.Hostname, translate func() ([]httpRouteEntry, error)) error {
+		attached := t.attachedListeners(kind, obj.GetNamespace(), parentRefs, hostnames)
+		// a route attached to several listeners of the port is added once to every host it serves
+		var hosts []string
+		for _, idx := range group.listeners {
+			if attached.Has(idx) {
+				hosts = append(hosts, routeHostnames(t.gw.Spec.Listeners[idx].Hostname, hostnames)...)
+			}
+		}
+		if len(hosts) == 0 {
+			return nil
+		}
+		entries, err := translate()
+		if err != nil {
+			return err
+		}
+		for _, host := range lo.Uniq(hosts) {
+			entriesByHost[host] = append(entriesByHost[host], entries...)
+		}
+		return nil
+	}
+	for _, route := range t.httpRoutes {
+		err := addRoute(HTTPRouteKind, route, route.Spec.ParentRefs, route.Spec.Hostnames, func() ([]httpRouteEntry, error) {
+			return t.translateHTTPRoute(route), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, route := range t.grpcRoutes {
+		err := addRoute(GRPCRouteKind, route, route.Spec.ParentRefs, route.Spec.Hostnames, func() ([]httpRouteEntry, error) {
+			return t.translateGRPCRoute(route)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hosts := slices.Sorted(maps.Keys(entriesByHost))
+	vhosts := make([]*envoy_config_route_v3.VirtualHost, 0, len(hosts))
+	for _, host := range hosts {
+		entries := entriesByHost[host]
+		slices.SortStableFunc(entries, compareHTTPRouteEntries)
+		vhosts = append(vhosts, &envoy_config_route_v3.VirtualHost{
+			Name:    virtualHostName(listenerName, host),
+			Domains: []string{host},
+			Routes:  lo.Map(entries, func(e httpRouteEntry, _ int) *envoy_config_route_v3.Route { return e.route }),
+		})
+	}
+	return vhosts, nil
+}
+
+// virtualHostName is the name of the virtual host serving a hostname in the route configuration of a listener
+func virtualHostName(listenerName, host string) string {
+	return fmt.Sprintf("%s~%s", listenerName, strings.ReplaceAll(host, "*", "wildcard"))
+}
+
+// VirtualHostNames returns the names of the virtual hosts serving an HTTPRoute or GRPCRoute of the hostnames on a
+// Gateway listener
+func VirtualHostNames(l api.Listener, hostnames []api.Hostname) []string {
+	return lo.Map(routeHostnames(l.Hostname, hostnames), func(host string, _ int) string {
+		return virtualHostName(listenerName(l.Port), host)
+	})
+}
+
+// RouteResourceName returns the name of the envoy route translated from a match of a rule of an HTTPRoute or
+// GRPCRoute, which the errors of the proxies about it name
+func RouteResourceName(namespace, name string, rule, match int) string {
+	return fmt.Sprintf("%s/%s~%d~%d", namespace, name, rule, match)
+}
+
+// compareHTTPRouteEntries orders routes by the Gateway API precedence: exact paths first, then the longest
+// path, the routes matching a method, the ones with the most header matches, and the most query param matches.
+// Remaining ties go to the oldest route, then to the first rule and match.
+func compareHTTPRouteEntries(a, b httpRouteEntry) int {
+	return cmp.Or(
+		cmp.Compare(a.pathRank, b.pathRank),
+		cmp.Compare(b.pathLen, a.pathLen),
+		cmp.Compare(lo.Ternary(b.method, 1, 0), lo.Ternary(a.method, 1, 0)),
+		cmp.Compare(b.headers, a.headers),
+		cmp.Compare(b.queryParams, a.queryParams),
+		compareRoutes(a.owner, b.owner),
+		cmp.Compare(a.ruleIdx, b.ruleIdx),
+		cmp.Compare(a.matchIdx, b.matchIdx),
+	)
+}
+
+func pathTypeRank(path api.HTTPPathMatch) int {
+	switch ptr.Deref(path.Type, api.PathMatchPathPrefix) {
+	case api.PathMatchExact:
+		return 0
+	case api.PathMatchPathPrefix:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// translateHTTPRoute builds an envoy route for every match of every rule of the HTTPRoute
+func (t *gatewayTranslator) translateHTTPRoute(route *api.HTTPRoute) []httpRouteEntry {
+	var ret []httpRouteEntry
+	for i, rule := range route.Spec.Rules {
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []api.HTTPRouteMatch{{}}
+		}
+		for j, match := range matches {
+			envoyRoute := t.httpRuleRoute(HTTPRouteKind, route.Namespace, rule, match)
+			envoyRoute.Name = RouteResourceName(route.Namespace, route.Name, i, j)
+			envoyRoute.Match = httpRouteMatch(match)
+			path := ptr.Deref(match.Path, api.HTTPPathMatch{})
+			ret = append(ret, httpRouteEntry{
+				pathRank:    pathTypeRank(path),
+				pathLen:     len(ptr.Deref(path.Value, "/")),
+				method:      match.Method != nil,
+				headers:     len(match.Headers),
+				queryParams: len(match.QueryParams),
+				owner:       route,
+				ruleIdx:     i,
+				matchIdx:    j,
+				route:       envoyRoute,
+			})
+		}
+	}
+	return ret
+}
+
+// FilterSupported reports whether the translator applies the filters of a type. ExtensionRef filters are not, as
+// they refer to no supported kind.
+func FilterSupported(t api.HTTPRouteFilterType) bool {
+	switch t {
+	case api.HTTPRouteFilterRequestHeaderModifier, api.HTTPRouteFilterResponseHeaderModifier,
+		api.HTTPRouteFilterRequestRedirect, api.HTTPRouteFilterURLRewrite, api.HTTPRouteFilterRequestMirror:
+		return true
+	}
+	return false
+}
+
+// httpRuleRoute translates the filters and backends of a rule for one of its matches, which prefix
+// rewrites depend on
+func (t *gatewayTranslator) httpRuleRoute(routeKind api.RouteGroupKind, routeNamespace string, rule api.HTTPRouteRule, match api.HTTPRouteMatch) *envoy_config_route_v3.Route {
+	ret := &envoy_config_route_v3.Route{}
+	// the rule must not be served without a filter that can not be applied
+	if slices.ContainsFunc(rule.Filters, func(f api.HTTPRouteFilter) bool { return !FilterSupported(f.Type) }) {
+		ret.Action = &envoy_config_route_v3.Route_DirectResponse{
+			DirectResponse: &envoy_config_route_v3.DirectResponseAction{Status: 500},
+		}
+		return ret
+	}
+	var redirect *api.HTTPRequestRedirectFilter
+	var rewrite *api.HTTPURLRewriteFilter
+	var mirrors []*api.HTTPRequestMirrorFilter
+	for _, f := range rule.Filters {
+		switch f.Type {
+		case api.HTTPRouteFilterRequestHeaderModifier:
+			ret.RequestHeadersToAdd = append(ret.RequestHeadersToAdd, headersToAdd(f.RequestHeaderModifier)...)
+			ret.RequestHeadersToRemove = append(ret.RequestHeadersToRemove, headersToRemove(f.RequestHeaderModifier)...)
+		case api.HTTPRouteFilterResponseHeaderModifier:
+			ret.ResponseHeadersToAdd = append(ret.ResponseHeadersToAdd, headersToAdd(f.ResponseHeaderModifier)...)
+			ret.ResponseHeadersToRemove = append(ret.ResponseHeadersToRemove, headersToRemove(f.ResponseHeaderModifier)...)
+		case api.HTTPRouteFilterRequestRedirect:
+			redirect = f.RequestRedirect
+		case api.HTTPRouteFilterURLRewrite:
+			rewrite = f.URLRewrite
+		case api.HTTPRouteFilterRequestMirror:
+			mirrors = append(mirrors, f.RequestMirror)
+		}
+	}
+
+	if redirect != nil {
+		ret.Action = &envoy_config_route_v3.Route_Redirect{Redirect: redirectAction(redirect, match)}
+		return ret
+	}
+
+	action := t.httpRouteAction(routeKind, routeNamespace, rule.BackendRefs)
+	if action == nil {
+		ret.Action = &envoy_config_route_v3.Route_DirectResponse{
+			DirectResponse: &envoy_config_route_v3.DirectResponseAction{Status: 500},
+		}
+		return ret
+	}
+	if rewrite != nil {
+		applyURLRewrite(action, rewrite, match)
+	}
+	for _, mirror := range mirrors {
+		// the requests mirrored to a backend that can not be resolved are dropped, like the share of traffic of one
+		cluster, err := t.backends.Resolve(mirror.BackendRef, routeKind, routeNamespace)
+		if err != nil {
+			cluster = invalidBackendCluster
+		} else {
+			t.addCluster(cluster)
+		}
+		action.RequestMirrorPolicies = append(action.RequestMirrorPolicies, &envoy_config_route_v3.RouteAction_RequestMirrorPolicy{
+			Cluster:         cluster,
+			RuntimeFraction: mirrorFraction(mirror),
+		})
+	}
+	ret.Action = &envoy_config_route_v3.Route_Route{Route: action}
+	return ret
+}
+
+// httpRouteAction forwards to the backends of a rule, weighted by their backendRef weight. The share of the
+// backends that can not be resolved is answered with a 500. It returns nil when no backend is resolved, in which
+// case the rule responds with a 500.
+func (t *gatewayTranslator) httpRouteAction(routeKind api.RouteGroupKind, routeNamespace string, refs []api.HTTPBackendRef) *envoy_config_route_v3.RouteAction {
+	var weighted []*envoy_config_route_v3.WeightedCluster_ClusterWeight
+	resolved := false
+	for _, ref := range refs {
+		weight := ptr.Deref(ref.Weight, 1)
+		if weight == 0 {
+			continue
+		}
+		cluster, err := t.backends.Resolve(ref.BackendObjectReference, routeKind, routeNamespace)
+		if err != nil {
+			cluster = invalidBackendCluster
+		} else {
+			resolved = true
+			t.addCluster(cluster)
+		}
+		weighted = append(weighted, &envoy_config_route_v3.WeightedCluster_ClusterWeight{
+			Name:   cluster,
+			Weight: wrapperspb.UInt32(uint32(weight)),
+		})
+	}
+	if !resolved {
+		return nil
+	}
+
+	action := &envoy_config_route_v3.RouteAction{
+		ClusterNotFoundResponseCode: envoy_config_route_v3.RouteAction_INTERNAL_SERVER_ERROR,
+	}
+	if len(weighted) == 1 {
+		action.ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{Cluster: weighted[0].GetName()}
+	} else {
+		action.ClusterSpecifier = &envoy_config_route_v3.RouteAction_WeightedClusters{
+			WeightedClusters: &envoy_config_route_v3.WeightedCluster{Clusters: weighted},
+		}
+	}
+	return action
+}
+
+// httpRouteMatch translates an HTTPRoute match. A path prefix matches whole path segments only.
+func httpRouteMatch(match api.HTTPRouteMatch) *envoy_config_route_v3.RouteMatch {
+	path := ptr.Deref(match.Path, api.HTTPPathMatch{})
+	value := ptr.Deref(path.Value, "/")
+	ret := &envoy_config_route_v3.RouteMatch{}
+	switch ptr.Deref(path.Type, api.PathMatchPathPrefix) {
+	case api.PathMatchExact:
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_Path{Path: value}
+	case api.PathMatchRegularExpression:
+		ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_SafeRegex{
+			SafeRegex: &envoy_type_matcher_v3.RegexMatcher{Regex: value},
+		}
+	default:
+		value = strings.TrimSuffix(value, "/")
+		if value == "" {
+			ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_Prefix{Prefix: "/"}
+		} else {
+			ret.PathSpecifier = &envoy_config_route_v3.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: value}
+		}
+	}
+
+	for _, h := range match.Headers {
+		ret.Headers = append(ret.Headers, &envoy_config_route_v3.HeaderMatcher{
+			Name: strings.ToLower(string(h.Name)),
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{
+				StringMatch: stringMatcher(ptr.Deref(h.Type, api.HeaderMatchExact) == api.HeaderMatchRegularExpression, h.Value),
+			},
+		})
+	}
+	if match.Method != nil {
+		ret.Headers = append(ret.Headers, &envoy_config_route_v3.HeaderMatcher{
+			Name: ":method",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{
+				StringMatch: stringMatcher(false, string(*match.Method)),
+			},
+		})
+	}
+	for _, q := range match.QueryParams {
+		ret.QueryParameters = append(ret.QueryParameters, &envoy_config_route_v3.QueryParameterMatcher{
+			Name: string(q.Name),
+			QueryParameterMatchSpecifier: &envoy_config_route_v3.QueryParameterMatcher_StringMatch{
+				StringMatch: stringMatcher(ptr.Deref(q.Type, api.QueryParamMatchExact) == api.QueryParamMatchRegularExpression, q.Value),
+			},
+		})
+	}
+	return ret
+}
+
+func stringMatcher(regex bool, value string) *envoy_type_matcher_v3.StringMatcher {
+	if regex {
+		return &envoy_type_matcher_v3.StringMatcher{
+			MatchPattern: &envoy_type_matcher_v3.StringMatcher_SafeRegex{
+				SafeRegex: &envoy_type_matcher_v3.RegexMatcher{Regex: value},
+			},
+		}
+	}
+	return &envoy_type_matcher_v3.StringMatcher{
+		MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: value},
+	}
+}
+
+// headersToAdd translates the set headers of a modifier, which overwrite existing values, and the add headers,
+// which are appended to them
+func headersToAdd(modifier *api.HTTPHeaderFilter) []*envoy_config_core_v3.HeaderValueOption {
+	if modifier == nil {
+		return nil
+	}
+	var ret []*envoy_config_core_v3.HeaderValueOption
+	for _, h := range modifier.Set {
+		ret = append(ret, &envoy_config_core_v3.HeaderValueOption{
+			Header:       &envoy_config_core_v3.HeaderValue{Key: strings.ToLower(string(h.Name)), Value: h.Value},
+			AppendAction: envoy_config_core_v3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}
+	for _, h := range modifier.Add {
+		ret = append(ret, &envoy_config_core_v3.HeaderValueOption{
+			Header:       &envoy_config_core_v3.HeaderValue{Key: strings.ToLower(string(h.Name)), Value: h.Value},
+			AppendAction: envoy_config_core_v3.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD,
+		})
+	}
+	return ret
+}
+
+func headersToRemove(modifier *api.HTTPHeaderFilter) []string {
+	if modifier == nil {
+		return nil
+	}
+	return lo.Map(modifier.Remove, func(h string, _ int) string { return strings.ToLower(h) })
+}
+
+func redirectAction(f *api.HTTPRequestRedirectFilter, match api.HTTPRouteMatch) *envoy_config_route_v3.RedirectAction {
+	ret := &envoy_config_route_v3.RedirectAction{
+		ResponseCode: redirectResponseCodes[ptr.Deref(f.StatusCode, 302)],
+	}
+	if f.Scheme != nil {
+		ret.SchemeRewriteSpecifier = &envoy_config_route_v3.RedirectAction_SchemeRedirect{SchemeRedirect: *f.Scheme}
+	}
+	if f.Hostname != nil {
+		ret.HostRedirect = string(*f.Hostname)
+	}
+	if f.Port != nil {
+		ret.PortRedirect = uint32(*f.Port)
+	}
+	if f.Path != nil {
+		switch f.Path.Type {
+		case api.FullPathHTTPPathModifier:
+			ret.PathRewriteSpecifier = &envoy_config_route_v3.RedirectAction_PathRedirect{
+				PathRedirect: ptr.Deref(f.Path.ReplaceFullPath, "/"),
+			}
+		case api.PrefixMatchHTTPPathModifier:
+			prefix, regex := prefixRewrite(match, ptr.Deref(f.Path.ReplacePrefixMatch, "/"))
+			if regex != nil {
+				ret.PathRewriteSpecifier = &envoy_config_route_v3.RedirectAction_RegexRewrite{RegexRewrite: regex}
+			} else {
+				ret.PathRewriteSpecifier = &envoy_config_route_v3.RedirectAction_PrefixRewrite{PrefixRewrite: prefix}
+			}
+		}
+	}
+	return ret
+}
+
+func applyURLRewrite(action *envoy_config_route_v3.RouteAction, f *api.HTTPURLRewriteFilter, match api.HTTPRouteMatch) {
+	if f.Hostname != nil {
+		action.HostRewriteSpecifier = &envoy_config_route_v3.RouteAction_HostRewriteLiteral{HostRewriteLiteral: string(*f.Hostname)}
+	}
+	if f.Path == nil {
+		return
+	}
+	switch f.Path.Type {
+	case api.FullPathHTTPPathModifier:
+		action.RegexRewrite = &envoy_type_matcher_v3.RegexMatchAndSubstitute{
+			Pattern:      &envoy_type_matcher_v3.RegexMatcher{Regex: "^.*$"},
+			Substitution: ptr.Deref(f.Path.ReplaceFullPath, "/"),
+		}
+	case api.PrefixMatchHTTPPathModifier:
+		action.PrefixRewrite, action.RegexRewrite = prefixRewrite(match, ptr.Deref(f.Path.ReplacePrefixMatch, "/"))
+	}
+}
+
+// prefixRewrite returns how to replace the path prefix matched by a PathPrefix match. Rewriting to or from
+// the root path is done with a regex, as a plain prefix rewrite would double or drop the separating slash.
+func prefixRewrite(match api.HTTPRouteMatch, replacement string) (string, *envoy_type_matcher_v3.RegexMatchAndSubstitute) {
+	matched := strings.TrimSuffix(ptr.Deref(ptr.Deref(match.Path, api.HTTPPathMatch{}).Value, "/"), "/")
+	replacement = strings.TrimSuffix(replacement, "/")
+	switch {
+	case replacement == "":
+		return "", &envoy_type_matcher_v3.RegexMatchAndSubstitute{
+			Pattern:      &envoy_type_matcher_v3.RegexMatcher{Regex: "^" + regexp.QuoteMeta(matched) + "/*"},
+			Substitution: "/",
+		}
+	case matched == "":
+		return "", &envoy_type_matcher_v3.RegexMatchAndSubstitute{
+			Pattern:      &envoy_type_matcher_v3.RegexMatcher{Regex: "^/"},
+			Substitution: replacement + "/",
+		}
+	default:
+		return replacement, nil
+	}
+}
+
+// mirrorFraction is the share of requests to mirror, all of them unless the filter sets a percent or a fraction
+func mirrorFraction(f *api.HTTPRequestMirrorFilter) *envoy_config_core_v3.RuntimeFractionalPercent {
+	percent := &envoy_type_v3.FractionalPercent{Numerator: 100, Denominator: envoy_type_v3.FractionalPercent_HUNDRED}
+	switch {
+	case f.Percent != nil:
+		percent.Numerator = uint32(*f.Percent)
+	case f.Fraction != nil:
+		// envoy has no arbitrary denominators, scale the fraction to a million
+		percent.Numerator = uint32(int64(f.Fraction.Numerator) * 1_000_000 / int64(ptr.Deref(f.Fraction.Denominator, 100)))
+		percent.Denominator = envoy_type_v3.FractionalPercent_MILLION
+	}
+	return &envoy_config_core_v3.RuntimeFractionalPercent{DefaultValue: percent}
+}
@@ -0,0 +1,389 @@
+package translator
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestCompareHTTPRouteEntries(t *testing.T) {
+	older := &api.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "b", Name: "older", CreationTimestamp: metav1.NewTime(time.Unix(100, 0)),
+	}}
+	newer := &api.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "a", Name: "newer", CreationTimestamp: metav1.NewTime(time.Unix(200, 0)),
+	}}
+	sameAgeA := &api.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "a", Name: "z", CreationTimestamp: metav1.NewTime(time.Unix(300, 0)),
+	}}
+	sameAgeB := &api.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "b", Name: "a", CreationTimestamp: metav1.NewTime(time.Unix(300, 0)),
+	}}
+	tests := []struct {
+		name string
+		// first is the entry taking precedence over second
+		first, second httpRouteEntry
+	}{
+		{
+			name:   "exact path before a longer prefix",
+			first:  httpRouteEntry{pathRank: 0, pathLen: 2, owner: newer},
+			second: httpRouteEntry{pathRank: 1, pathLen: 10, owner: older},
+		},
+		{
+			name:   "prefix before a regular expression",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, owner: newer},
+			second: httpRouteEntry{pathRank: 2, pathLen: 10, owner: older},
+		},
+		{
+			name:   "longest prefix",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 5, owner: newer},
+			second: httpRouteEntry{pathRank: 1, pathLen: 4, owner: older, method: true, headers: 3},
+		},
+		{
+			name:   "method match",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, method: true, owner: newer},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, headers: 3, owner: older},
+		},
+		{
+			name:   "most header matches",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, headers: 2, owner: newer},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, headers: 1, queryParams: 3, owner: older},
+		},
+		{
+			name:   "most query param matches",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, queryParams: 2, owner: newer},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, queryParams: 1, owner: older},
+		},
+		{
+			name:   "oldest route",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, owner: older},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, owner: newer},
+		},
+		{
+			name:   "namespace and name of routes of the same age",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, owner: sameAgeA},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, owner: sameAgeB},
+		},
+		{
+			name:   "first rule",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, owner: older, ruleIdx: 0, matchIdx: 1},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, owner: older, ruleIdx: 1, matchIdx: 0},
+		},
+		{
+			name:   "first match",
+			first:  httpRouteEntry{pathRank: 1, pathLen: 1, owner: older, ruleIdx: 1, matchIdx: 0},
+			second: httpRouteEntry{pathRank: 1, pathLen: 1, owner: older, ruleIdx: 1, matchIdx: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareHTTPRouteEntries(tt.first, tt.second); got >= 0 {
+				t.Errorf("compareHTTPRouteEntries(first, second) = %d, want < 0", got)
+			}
+			if got := compareHTTPRouteEntries(tt.second, tt.first); got <= 0 {
+				t.Errorf("compareHTTPRouteEntries(second, first) = %d, want > 0", got)
+			}
+		})
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	route := func(namespace, name string, created int64) *api.HTTPRoute {
+		return &api.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace, Name: name, CreationTimestamp: metav1.NewTime(time.Unix(created, 0)),
+		}}
+	}
+	// the oldest route first, then the routes of the same age by namespace and name
+	routes := sortRoutes([]*api.HTTPRoute{route("a", "z", 300), route("a", "newer", 200), route("b", "a", 300), route("b", "older", 100)})
+	var got []string
+	for _, r := range routes {
+		got = append(got, r.Namespace+"/"+r.Name)
+	}
+	if want := []string{"b/older", "a/newer", "a/z", "b/a"}; !slices.Equal(got, want) {
+		t.Errorf("sorted routes = %v, want %v", got, want)
+	}
+}
+
+func TestPrefixRewrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		matched     *string
+		replacement string
+		wantPrefix  string
+		wantPattern string
+		wantSubst   string
+	}{
+		{name: "prefix to prefix", matched: ptr.To("/api"), replacement: "/v2", wantPrefix: "/v2"},
+		{name: "trailing slashes are ignored", matched: ptr.To("/api/"), replacement: "/v2/", wantPrefix: "/v2"},
+		{name: "prefix to root", matched: ptr.To("/api"), replacement: "/", wantPattern: `^/api/*`, wantSubst: "/"},
+		{name: "root to prefix", matched: ptr.To("/"), replacement: "/v2", wantPattern: "^/", wantSubst: "/v2/"},
+		{name: "no path match is the root", replacement: "/v2", wantPattern: "^/", wantSubst: "/v2/"},
+		{name: "root to root", matched: ptr.To("/"), replacement: "/", wantPattern: "^/*", wantSubst: "/"},
+		{name: "matched prefix is quoted", matched: ptr.To("/a.b"), replacement: "/", wantPattern: `^/a\.b/*`, wantSubst: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var match api.HTTPRouteMatch
+			if tt.matched != nil {
+				match.Path = &api.HTTPPathMatch{Type: ptr.To(api.PathMatchPathPrefix), Value: tt.matched}
+			}
+			prefix, regex := prefixRewrite(match, tt.replacement)
+			if prefix != tt.wantPrefix {
+				t.Errorf("prefix = %q, want %q", prefix, tt.wantPrefix)
+			}
+			if got := regex.GetPattern().GetRegex(); got != tt.wantPattern {
+				t.Errorf("pattern = %q, want %q", got, tt.wantPattern)
+			}
+			if got := regex.GetSubstitution(); got != tt.wantSubst {
+				t.Errorf("substitution = %q, want %q", got, tt.wantSubst)
+			}
+		})
+	}
+}
+
+func TestHTTPRouteMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		match api.HTTPRouteMatch
+		want  *envoy_config_route_v3.RouteMatch
+	}{
+		{
+			name:  "no path is the root prefix",
+			match: api.HTTPRouteMatch{},
+			want:  &envoy_config_route_v3.RouteMatch{PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{Prefix: "/"}},
+		},
+		{
+			name:  "prefix matches whole segments",
+			match: api.HTTPRouteMatch{Path: &api.HTTPPathMatch{Value: ptr.To("/api/")}},
+			want: &envoy_config_route_v3.RouteMatch{
+				PathSpecifier: &envoy_config_route_v3.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: "/api"},
+			},
+		},
+		{
+			name:  "exact path",
+			match: api.HTTPRouteMatch{Path: &api.HTTPPathMatch{Type: ptr.To(api.PathMatchExact), Value: ptr.To("/api/")}},
+			want:  &envoy_config_route_v3.RouteMatch{PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{Path: "/api/"}},
+		},
+		{
+			name: "headers, method and query params",
+			match: api.HTTPRouteMatch{
+				Headers: []api.HTTPHeaderMatch{
+					{Name: "X-Version", Value: "2"},
+					{Type: ptr.To(api.HeaderMatchRegularExpression), Name: "X-Tenant", Value: "a.*"},
+				},
+				Method:      ptr.To(api.HTTPMethodPost),
+				QueryParams: []api.HTTPQueryParamMatch{{Name: "debug", Value: "true"}},
+			},
+			want: &envoy_config_route_v3.RouteMatch{
+				PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{Prefix: "/"},
+				Headers: []*envoy_config_route_v3.HeaderMatcher{
+					{Name: "x-version", HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{StringMatch: stringMatcher(false, "2")}},
+					{Name: "x-tenant", HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{StringMatch: stringMatcher(true, "a.*")}},
+					{Name: ":method", HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{StringMatch: stringMatcher(false, "POST")}},
+				},
+				QueryParameters: []*envoy_config_route_v3.QueryParameterMatcher{{
+					Name:                         "debug",
+					QueryParameterMatchSpecifier: &envoy_config_route_v3.QueryParameterMatcher_StringMatch{StringMatch: stringMatcher(false, "true")},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := httpRouteMatch(tt.match); !proto.Equal(got, tt.want) {
+				t.Errorf("httpRouteMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirrorFraction(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      *api.HTTPRequestMirrorFilter
+		numerator   uint32
+		denominator envoy_type_v3.FractionalPercent_DenominatorType
+	}{
+		{name: "all requests", filter: &api.HTTPRequestMirrorFilter{}, numerator: 100},
+		{name: "percent", filter: &api.HTTPRequestMirrorFilter{Percent: ptr.To[int32](25)}, numerator: 25},
+		{
+			name:        "fraction",
+			filter:      &api.HTTPRequestMirrorFilter{Fraction: &api.Fraction{Numerator: 1, Denominator: ptr.To[int32](3)}},
+			numerator:   333333,
+			denominator: envoy_type_v3.FractionalPercent_MILLION,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mirrorFraction(tt.filter).GetDefaultValue()
+			if got.GetNumerator() != tt.numerator || got.GetDenominator() != tt.denominator {
+				t.Errorf("mirror fraction = %v, want %d/%v", got, tt.numerator, tt.denominator)
+			}
+		})
+	}
+}
+
+func TestHTTPRouteTranslation(t *testing.T) {
+	pathMatch := func(typ api.PathMatchType, value string) []api.HTTPRouteMatch {
+		return []api.HTTPRouteMatch{{Path: &api.HTTPPathMatch{Type: ptr.To(typ), Value: ptr.To(value)}}}
+	}
+	weighted := func(ref api.HTTPBackendRef, weight int32) api.HTTPBackendRef {
+		ref.Weight = ptr.To(weight)
+		return ref
+	}
+	route := testHTTPRoute("route", "gw", nil)
+	route.Spec.Rules = []api.HTTPRouteRule{
+		{
+			Matches:     pathMatch(api.PathMatchPathPrefix, "/"),
+			BackendRefs: []api.HTTPBackendRef{weighted(backendRef("web", 8080), 3), weighted(backendRef("canary", 8080), 1)},
+		},
+		{
+			Matches: pathMatch(api.PathMatchPathPrefix, "/api"),
+			Filters: []api.HTTPRouteFilter{
+				{
+					Type: api.HTTPRouteFilterRequestHeaderModifier,
+					RequestHeaderModifier: &api.HTTPHeaderFilter{
+						Set:    []api.HTTPHeader{{Name: "X-Env", Value: "prod"}},
+						Remove: []string{"X-Debug"},
+					},
+				},
+				{
+					Type:       api.HTTPRouteFilterURLRewrite,
+					URLRewrite: &api.HTTPURLRewriteFilter{Path: &api.HTTPPathModifier{Type: api.PrefixMatchHTTPPathModifier, ReplacePrefixMatch: ptr.To("/v2")}},
+				},
+				{
+					Type:          api.HTTPRouteFilterRequestMirror,
+					RequestMirror: &api.HTTPRequestMirrorFilter{BackendRef: backendRef("canary", 8080).BackendObjectReference},
+				},
+			},
+			BackendRefs: []api.HTTPBackendRef{backendRef("web", 8080), weighted(backendRef("canary", 8080), 0)},
+		},
+		{
+			Matches: pathMatch(api.PathMatchExact, "/old"),
+			Filters: []api.HTTPRouteFilter{{
+				Type:            api.HTTPRouteFilterRequestRedirect,
+				RequestRedirect: &api.HTTPRequestRedirectFilter{Scheme: ptr.To("https"), StatusCode: ptr.To(301)},
+			}},
+		},
+		{
+			Matches:     pathMatch(api.PathMatchPathPrefix, "/partial"),
+			BackendRefs: []api.HTTPBackendRef{backendRef("web", 8080), backendRef("missing", 8080)},
+		},
+	}
+
+	gateways := translateGateways(t,
+		testGateway("gw", api.Listener{Name: "http", Port: 8080, Protocol: api.HTTPProtocolType}),
+		testService("default", "web", 8080),
+		testService("default", "canary", 8080),
+		route,
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+	if len(out.Routes) != 1 || len(out.Routes[0].GetVirtualHosts()) != 1 {
+		t.Fatalf("route configurations = %v, want a single virtual host", out.Routes)
+	}
+	routes := out.Routes[0].GetVirtualHosts()[0].GetRoutes()
+	if got, want := resourceNames(routes), []string{
+		"default/route~2~0", "default/route~3~0", "default/route~1~0", "default/route~0~0",
+	}; !slices.Equal(got, want) {
+		t.Fatalf("routes = %v, want them ordered by precedence %v", got, want)
+	}
+
+	redirect := routes[0].GetRedirect()
+	if redirect.GetSchemeRedirect() != "https" || redirect.GetResponseCode() != envoy_config_route_v3.RedirectAction_MOVED_PERMANENTLY {
+		t.Errorf("redirect = %v, want a permanent redirect to https", redirect)
+	}
+
+	partial := routes[1].GetRoute().GetWeightedClusters().GetClusters()
+	if got, want := resourceNames(partial), []string{"kube_default_web_8080", invalidBackendCluster}; !slices.Equal(got, want) {
+		t.Errorf("clusters of a rule with an unresolved backend = %v, want %v", got, want)
+	}
+
+	rewritten := routes[2]
+	if h := rewritten.GetRequestHeadersToAdd(); len(h) != 1 || h[0].GetHeader().GetKey() != "x-env" ||
+		h[0].GetAppendAction() != envoy_config_core_v3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD {
+		t.Errorf("request headers to add = %v, want x-env overwritten", h)
+	}
+	if got := rewritten.GetRequestHeadersToRemove(); !slices.Equal(got, []string{"x-debug"}) {
+		t.Errorf("request headers to remove = %v, want [x-debug]", got)
+	}
+	action := rewritten.GetRoute()
+	if action.GetCluster() != "kube_default_web_8080" {
+		t.Errorf("cluster = %q, want the only backend of non-zero weight", action.GetCluster())
+	}
+	if action.GetPrefixRewrite() != "/v2" {
+		t.Errorf("prefix rewrite = %q, want /v2", action.GetPrefixRewrite())
+	}
+	if mirrors := action.GetRequestMirrorPolicies(); len(mirrors) != 1 || mirrors[0].GetCluster() != "kube_default_canary_8080" {
+		t.Errorf("mirror policies = %v, want a mirror to the canary", mirrors)
+	}
+
+	clusters := routes[3].GetRoute().GetWeightedClusters().GetClusters()
+	if len(clusters) != 2 || clusters[0].GetWeight().GetValue() != 3 || clusters[1].GetWeight().GetValue() != 1 {
+		t.Errorf("weighted clusters = %v, want web weighted 3 and canary 1", clusters)
+	}
+}
+
+func TestHTTPRouteFilterFallbacks(t *testing.T) {
+	pathMatch := func(value string) []api.HTTPRouteMatch {
+		return []api.HTTPRouteMatch{{Path: &api.HTTPPathMatch{Type: ptr.To(api.PathMatchExact), Value: ptr.To(value)}}}
+	}
+	route := testHTTPRoute("route", "gw", nil)
+	route.Spec.Rules = []api.HTTPRouteRule{
+		{
+			Matches: pathMatch("/extension"),
+			Filters: []api.HTTPRouteFilter{{
+				Type:         api.HTTPRouteFilterExtensionRef,
+				ExtensionRef: &api.LocalObjectReference{Group: "example.com", Kind: "Auth", Name: "auth"},
+			}},
+			BackendRefs: []api.HTTPBackendRef{backendRef("web", 8080)},
+		},
+		{
+			Matches:     pathMatch("/unsupported"),
+			Filters:     []api.HTTPRouteFilter{{Type: "CORS"}},
+			BackendRefs: []api.HTTPBackendRef{backendRef("web", 8080)},
+		},
+		{
+			Matches: pathMatch("/mirrored"),
+			Filters: []api.HTTPRouteFilter{{
+				Type:          api.HTTPRouteFilterRequestMirror,
+				RequestMirror: &api.HTTPRequestMirrorFilter{BackendRef: backendRef("missing", 8080).BackendObjectReference},
+			}},
+			BackendRefs: []api.HTTPBackendRef{backendRef("web", 8080)},
+		},
+	}
+
+	gateways := translateGateways(t,
+		testGateway("gw", api.Listener{Name: "http", Port: 8080, Protocol: api.HTTPProtocolType}),
+		testService("default", "web", 8080),
+		route,
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+	if len(out.Routes) != 1 || len(out.Routes[0].GetVirtualHosts()) != 1 {
+		t.Fatalf("route configurations = %v, want a single virtual host", out.Routes)
+	}
+	routes := make(map[string]*envoy_config_route_v3.Route)
+	for _, r := range out.Routes[0].GetVirtualHosts()[0].GetRoutes() {
+		routes[r.GetMatch().GetPath()] = r
+	}
+	for _, path := range []string{"/extension", "/unsupported"} {
+		if status := routes[path].GetDirectResponse().GetStatus(); status != 500 {
+			t.Errorf("%s responds with %d, want a 500 for a filter that can not be applied", path, status)
+		}
+	}
+	action := routes["/mirrored"].GetRoute()
+	if action.GetCluster() != "kube_default_web_8080" {
+		t.Errorf("cluster = %q, want the backend of the rule", action.GetCluster())
+	}
+	if mirrors := action.GetRequestMirrorPolicies(); len(mirrors) != 1 || mirrors[0].GetCluster() != invalidBackendCluster {
+		t.Errorf("mirror policies = %v, want a mirror to %s", mirrors, invalidBackendCluster)
+	}
+}
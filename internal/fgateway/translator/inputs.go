@@ -0,0 +1,111 @@
+package translator
+
+import (
+	"context"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"istio.io/istio/pkg/config/schema/gvr"
+	"istio.io/istio/pkg/config/schema/kubeclient"
+	istiokube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/kube/krt"
+	"istio.io/istio/pkg/kube/kubetypes"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	apiv1alpha3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// Inputs are the krt collections of the kubernetes objects the translator reads from
+type Inputs struct {
+	GatewayClasses krt.Collection[*api.GatewayClass]
+	Gateways       krt.Collection[*api.Gateway]
+	HTTPRoutes     krt.Collection[*api.HTTPRoute]
+	GRPCRoutes     krt.Collection[*api.GRPCRoute]
+	TCPRoutes      krt.Collection[*apiv1alpha2.TCPRoute]
+	TLSRoutes      krt.Collection[*apiv1alpha2.TLSRoute]
+	UDPRoutes      krt.Collection[*apiv1alpha2.UDPRoute]
+	Namespaces     krt.Collection[*corev1.Namespace]
+	Services       krt.Collection[*corev1.Service]
+	EndpointSlices krt.Collection[*discoveryv1.EndpointSlice]
+	// Secrets hold the certificates of the listeners, and the CA certificates of the backends
+	Secrets            krt.Collection[*corev1.Secret]
+	ConfigMaps         krt.Collection[*corev1.ConfigMap]
+	ReferenceGrants    krt.Collection[*apiv1beta1.ReferenceGrant]
+	BackendTLSPolicies krt.Collection[*apiv1alpha3.BackendTLSPolicy]
+}
+
+// backendTLSPolicyGVR is the resource of the BackendTLSPolicies, which the istio client does not know of
+var backendTLSPolicyGVR = apiv1alpha3.SchemeGroupVersion.WithResource("backendtlspolicies")
+
+func init() {
+	kubeclient.Register[*apiv1alpha3.BackendTLSPolicy](
+		backendTLSPolicyGVR,
+		apiv1alpha3.SchemeGroupVersion.WithKind("BackendTLSPolicy"),
+		func(c kubeclient.ClientGetter, namespace string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.GatewayAPI().GatewayV1alpha3().BackendTLSPolicies(namespace).List(context.Background(), o)
+		},
+		func(c kubeclient.ClientGetter, namespace string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.GatewayAPI().GatewayV1alpha3().BackendTLSPolicies(namespace).Watch(context.Background(), o)
+		},
+	)
+}
+
+// NewInputs creates the translator input collections. The Gateway API objects are served by the istio client
+// in their v1beta1 version, which share their schema with the v1 types and are converted to them, except for
+// GRPCRoutes which are served in v1. The experimental routes and the BackendTLSPolicies are watched only once
+// their CRD is installed.
+func NewInputs(istioClient istiokube.Client, krtOpts krtutil.KrtOptions) Inputs {
+	return Inputs{
+		GatewayClasses: asV1(
+			krt.WrapClient(kclient.New[*apiv1beta1.GatewayClass](istioClient), krtOpts.ApplyTo("GatewayClassesV1beta1")...),
+			func(o *apiv1beta1.GatewayClass) *api.GatewayClass { return (*api.GatewayClass)(o) },
+			krtOpts.ApplyTo("GatewayClasses"),
+		),
+		Gateways: asV1(
+			krt.WrapClient(kclient.New[*apiv1beta1.Gateway](istioClient), krtOpts.ApplyTo("GatewaysV1beta1")...),
+			func(o *apiv1beta1.Gateway) *api.Gateway { return (*api.Gateway)(o) },
+			krtOpts.ApplyTo("Gateways"),
+		),
+		HTTPRoutes: asV1(
+			krt.WrapClient(kclient.New[*apiv1beta1.HTTPRoute](istioClient), krtOpts.ApplyTo("HTTPRoutesV1beta1")...),
+			func(o *apiv1beta1.HTTPRoute) *api.HTTPRoute { return (*api.HTTPRoute)(o) },
+			krtOpts.ApplyTo("HTTPRoutes"),
+		),
+		GRPCRoutes: krt.WrapClient(kclient.New[*api.GRPCRoute](istioClient), krtOpts.ApplyTo("GRPCRoutes")...),
+		TCPRoutes: krt.WrapClient(
+			kclient.NewDelayedInformer[*apiv1alpha2.TCPRoute](istioClient, gvr.TCPRoute, kubetypes.StandardInformer, kclient.Filter{}),
+			krtOpts.ApplyTo("TCPRoutes")...,
+		),
+		TLSRoutes: krt.WrapClient(
+			kclient.NewDelayedInformer[*apiv1alpha2.TLSRoute](istioClient, gvr.TLSRoute, kubetypes.StandardInformer, kclient.Filter{}),
+			krtOpts.ApplyTo("TLSRoutes")...,
+		),
+		UDPRoutes: krt.WrapClient(
+			kclient.NewDelayedInformer[*apiv1alpha2.UDPRoute](istioClient, gvr.UDPRoute, kubetypes.StandardInformer, kclient.Filter{}),
+			krtOpts.ApplyTo("UDPRoutes")...,
+		),
+		Namespaces:      krt.WrapClient(kclient.New[*corev1.Namespace](istioClient), krtOpts.ApplyTo("Namespaces")...),
+		Services:        krt.WrapClient(kclient.New[*corev1.Service](istioClient), krtOpts.ApplyTo("Services")...),
+		EndpointSlices:  krt.WrapClient(kclient.New[*discoveryv1.EndpointSlice](istioClient), krtOpts.ApplyTo("EndpointSlices")...),
+		Secrets:         krt.WrapClient(kclient.New[*corev1.Secret](istioClient), krtOpts.ApplyToUndebugged("Secrets")...),
+		ConfigMaps:      krt.WrapClient(kclient.New[*corev1.ConfigMap](istioClient), krtOpts.ApplyToUndebugged("ConfigMaps")...),
+		ReferenceGrants: krt.WrapClient(kclient.New[*apiv1beta1.ReferenceGrant](istioClient), krtOpts.ApplyTo("ReferenceGrants")...),
+		BackendTLSPolicies: krt.WrapClient(
+			kclient.NewDelayedInformer[*apiv1alpha3.BackendTLSPolicy](istioClient, backendTLSPolicyGVR, kubetypes.StandardInformer, kclient.Filter{}),
+			krtOpts.ApplyTo("BackendTLSPolicies")...,
+		),
+	}
+}
+
+func asV1[I, O any](c krt.Collection[I], convert func(I) O, opts []krt.CollectionOption) krt.Collection[O] {
+	return krt.NewCollection(c, func(kctx krt.HandlerContext, o I) *O {
+		return ptr.To(convert(o))
+	}, opts...)
+}
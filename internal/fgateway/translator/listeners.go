@@ -0,0 +1,312 @@
+package translator
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/ports"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// HTTPRouteKind is the route group kind of HTTPRoutes
+var HTTPRouteKind = api.RouteGroupKind{Group: ptr.To(api.Group(api.GroupName)), Kind: "HTTPRoute"}
+
+// GRPCRouteKind is the route group kind of GRPCRoutes
+var GRPCRouteKind = api.RouteGroupKind{Group: ptr.To(api.Group(api.GroupName)), Kind: "GRPCRoute"}
+
+// TCPRouteKind is the route group kind of TCPRoutes
+var TCPRouteKind = api.RouteGroupKind{Group: ptr.To(api.Group(api.GroupName)), Kind: "TCPRoute"}
+
+// TLSRouteKind is the route group kind of TLSRoutes
+var TLSRouteKind = api.RouteGroupKind{Group: ptr.To(api.Group(api.GroupName)), Kind: "TLSRoute"}
+
+// UDPRouteKind is the route group kind of UDPRoutes
+var UDPRouteKind = api.RouteGroupKind{Group: ptr.To(api.Group(api.GroupName)), Kind: "UDPRoute"}
+
+// supportedRouteKinds are the route kinds that can attach to a listener, by listener protocol.
+// A listener with a protocol missing from this map is not accepted.
+var supportedRouteKinds = map[api.ProtocolType][]api.RouteGroupKind{
+	api.HTTPProtocolType:  {HTTPRouteKind, GRPCRouteKind},
+	api.HTTPSProtocolType: {HTTPRouteKind, GRPCRouteKind},
+	api.TCPProtocolType:   {TCPRouteKind},
+	// TLS listeners pass the connections through to the backends
+	api.TLSProtocolType: {TLSRouteKind},
+	api.UDPProtocolType: {UDPRouteKind},
+}
+
+// ListenerReport is the result of validating a Gateway listener
+type ListenerReport struct {
+	Name           api.SectionName
+	SupportedKinds []api.RouteGroupKind
+	Conditions     []metav1.Condition
+	// Valid is false when the listener can not be programmed on the proxy
+	Valid bool
+	// Certificates are the Secrets of the certificateRefs of a listener terminating TLS that resolve
+	Certificates []*corev1.Secret
+}
+
+// ValidateListeners checks every listener of the Gateway for an unsupported protocol, a port the proxy can not bind,
+// unsupported route kinds, invalid certificateRefs and conflicts with the other listeners on the same port. The
+// reports are returned in the order of the listeners.
+func ValidateListeners(gw *api.Gateway, certs CertificateResolver) []ListenerReport {
+	conflicts := listenerConflicts(gw.Spec.Listeners)
+	// the ports of the listeners by the protocol the proxy binds them with
+	listenerPorts := map[corev1.Protocol][]uint16{}
+	for _, l := range gw.Spec.Listeners {
+		protocol := ports.Protocol(l.Protocol)
+		listenerPorts[protocol] = append(listenerPorts[protocol], uint16(l.Port))
+	}
+	reports := make([]ListenerReport, 0, len(gw.Spec.Listeners))
+	for _, l := range gw.Spec.Listeners {
+		report := ListenerReport{Name: l.Name, Valid: true, SupportedKinds: []api.RouteGroupKind{}}
+		setCondition := func(t api.ListenerConditionType, status metav1.ConditionStatus, reason api.ListenerConditionReason, msg string) {
+			report.Conditions = append(report.Conditions, metav1.Condition{
+				Type:               string(t),
+				Status:             status,
+				Reason:             string(reason),
+				Message:            msg,
+				ObservedGeneration: gw.Generation,
+			})
+		}
+
+		// accepted
+		kinds, protocolSupported := supportedRouteKinds[l.Protocol]
+		switch {
+		case !protocolSupported:
+			report.Valid = false
+			setCondition(api.ListenerConditionAccepted, metav1.ConditionFalse, api.ListenerReasonUnsupportedProtocol,
+				fmt.Sprintf("protocol %s is not supported", l.Protocol))
+		case l.Protocol == api.TLSProtocolType && (l.TLS == nil || ptr.Deref(l.TLS.Mode, api.TLSModeTerminate) != api.TLSModePassthrough):
+			report.Valid = false
+			setCondition(api.ListenerConditionAccepted, metav1.ConditionFalse, api.ListenerReasonUnsupportedProtocol,
+				"TLS listeners only support the Passthrough mode")
+		case l.Protocol == api.HTTPSProtocolType && (l.TLS == nil || ptr.Deref(l.TLS.Mode, api.TLSModeTerminate) != api.TLSModeTerminate):
+			report.Valid = false
+			setCondition(api.ListenerConditionAccepted, metav1.ConditionFalse, api.ListenerReasonUnsupportedProtocol,
+				"HTTPS listeners only support the Terminate mode")
+		case ports.Shadowed(uint16(l.Port), listenerPorts[ports.Protocol(l.Protocol)]):
+			report.Valid = false
+			setCondition(api.ListenerConditionAccepted, metav1.ConditionFalse, api.ListenerReasonPortUnavailable,
+				fmt.Sprintf("port %d is bound on the proxy as port %d, which is the port of another listener",
+					l.Port, ports.TranslatePort(uint16(l.Port))))
+		default:
+			setCondition(api.ListenerConditionAccepted, metav1.ConditionTrue, api.ListenerReasonAccepted, "Listener is accepted")
+		}
+
+		// resolved refs
+		var invalidKinds []string
+		report.SupportedKinds, invalidKinds = listenerKinds(l, kinds)
+		var certErr error
+		if report.Valid && l.Protocol == api.HTTPSProtocolType {
+			report.Certificates, certErr = listenerCertificates(l, gw.Namespace, certs)
+			if len(report.Certificates) == 0 {
+				report.Valid = false
+			}
+		}
+		var refErr *CertificateRefError
+		switch {
+		case len(invalidKinds) > 0:
+			setCondition(api.ListenerConditionResolvedRefs, metav1.ConditionFalse, api.ListenerReasonInvalidRouteKinds,
+				fmt.Sprintf("route kinds %v are not supported on %s listeners", invalidKinds, l.Protocol))
+		case errors.As(certErr, &refErr):
+			setCondition(api.ListenerConditionResolvedRefs, metav1.ConditionFalse, refErr.Reason, refErr.Message)
+		default:
+			setCondition(api.ListenerConditionResolvedRefs, metav1.ConditionTrue, api.ListenerReasonResolvedRefs, "Listener references are resolved")
+		}
+
+		// conflicted
+		if reason, ok := conflicts[l.Name]; ok {
+			report.Valid = false
+			setCondition(api.ListenerConditionConflicted, metav1.ConditionTrue, reason,
+				fmt.Sprintf("listener conflicts with another listener on port %d", l.Port))
+		} else {
+			setCondition(api.ListenerConditionConflicted, metav1.ConditionFalse, api.ListenerReasonNoConflicts, "Listener has no conflicts")
+		}
+
+		// programmed
+		if report.Valid {
+			setCondition(api.ListenerConditionProgrammed, metav1.ConditionTrue, api.ListenerReasonProgrammed, "Listener is programmed")
+		} else {
+			setCondition(api.ListenerConditionProgrammed, metav1.ConditionFalse, api.ListenerReasonInvalid, "Listener is invalid")
+		}
+
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// listenerCertificates resolves the certificateRefs of a listener terminating TLS. The error is the one of the first
+// certificateRef that does not resolve, the other certificates are still served.
+func listenerCertificates(l api.Listener, gatewayNamespace string, certs CertificateResolver) ([]*corev1.Secret, error) {
+	if l.TLS == nil || len(l.TLS.CertificateRefs) == 0 {
+		return nil, &CertificateRefError{
+			Reason:  api.ListenerReasonInvalidCertificateRef,
+			Message: "listener has no certificateRefs",
+		}
+	}
+	var ret []*corev1.Secret
+	var firstErr error
+	for _, ref := range l.TLS.CertificateRefs {
+		secret, err := certs.Resolve(ref, gatewayNamespace)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ret = append(ret, secret)
+	}
+	return ret, firstErr
+}
+
+// listenerKinds returns the route kinds allowed on the listener that are supported for its protocol,
+// and the allowed kinds that are not
+func listenerKinds(l api.Listener, supported []api.RouteGroupKind) ([]api.RouteGroupKind, []string) {
+	if l.AllowedRoutes == nil || len(l.AllowedRoutes.Kinds) == 0 {
+		return slices.Clone(supported), nil
+	}
+	var valid []api.RouteGroupKind
+	var invalid []string
+	for _, k := range l.AllowedRoutes.Kinds {
+		if slices.ContainsFunc(supported, func(s api.RouteGroupKind) bool { return sameKind(s, k) }) {
+			valid = append(valid, api.RouteGroupKind{Group: ptr.To(ptr.Deref(k.Group, api.GroupName)), Kind: k.Kind})
+		} else {
+			invalid = append(invalid, string(k.Kind))
+		}
+	}
+	if valid == nil {
+		valid = []api.RouteGroupKind{}
+	}
+	return valid, invalid
+}
+
+// listenerConflicts finds the listeners sharing a port with a listener of a different protocol, or with a listener
+// of the same protocol and hostname. UDP listeners only conflict with the other UDP listeners of their port, as
+// the others are bound over TCP.
+func listenerConflicts(listeners []api.Listener) map[api.SectionName]api.ListenerConditionReason {
+	conflicts := map[api.SectionName]api.ListenerConditionReason{}
+	byPort := lo.GroupBy(listeners, func(l api.Listener) listenerPort {
+		return listenerPort{port: l.Port, protocol: ports.Protocol(l.Protocol)}
+	})
+	for _, ls := range byPort {
+		protocols := lo.Uniq(lo.Map(ls, func(l api.Listener, _ int) api.ProtocolType { return l.Protocol }))
+		if len(protocols) > 1 {
+			for _, l := range ls {
+				conflicts[l.Name] = api.ListenerReasonProtocolConflict
+			}
+			continue
+		}
+		byHostname := lo.GroupBy(ls, func(l api.Listener) api.Hostname { return ptr.Deref(l.Hostname, "") })
+		for _, same := range byHostname {
+			if len(same) < 2 {
+				continue
+			}
+			for _, l := range same {
+				conflicts[l.Name] = api.ListenerReasonHostnameConflict
+			}
+		}
+	}
+	return conflicts
+}
+
+// listenerPort is a port the proxy binds, with the protocol it binds it with
+type listenerPort struct {
+	port     api.PortNumber
+	protocol corev1.Protocol
+}
+
+// ParentRefSelectsGateway reports whether a parentRef of a route in routeNamespace refers to the Gateway
+func ParentRefSelectsGateway(ref api.ParentReference, routeNamespace string, gw *api.Gateway) bool {
+	if ptr.Deref(ref.Group, api.GroupName) != api.GroupName || ptr.Deref(ref.Kind, "Gateway") != "Gateway" {
+		return false
+	}
+	return string(ref.Name) == gw.Name && string(ptr.Deref(ref.Namespace, api.Namespace(routeNamespace))) == gw.Namespace
+}
+
+// ParentRefSelectsListener reports whether a parentRef that selects the Gateway also selects the listener,
+// either by its section name or port, or by selecting the whole Gateway
+func ParentRefSelectsListener(ref api.ParentReference, l api.Listener) bool {
+	if ref.SectionName != nil && *ref.SectionName != l.Name {
+		return false
+	}
+	if ref.Port != nil && *ref.Port != l.Port {
+		return false
+	}
+	return true
+}
+
+// ListenerAllowsRoute reports whether the listener's allowedRoutes admit a route of the given kind from
+// routeNamespace, whose labels are used for the Selector namespace policy
+func ListenerAllowsRoute(report ListenerReport, l api.Listener, gwNamespace string, kind api.RouteGroupKind, routeNamespace string, routeNamespaceLabels map[string]string) bool {
+	if !slices.ContainsFunc(report.SupportedKinds, func(s api.RouteGroupKind) bool { return sameKind(s, kind) }) {
+		return false
+	}
+
+	from := api.NamespacesFromSame
+	var selector *metav1.LabelSelector
+	if l.AllowedRoutes != nil && l.AllowedRoutes.Namespaces != nil {
+		from = ptr.Deref(l.AllowedRoutes.Namespaces.From, api.NamespacesFromSame)
+		selector = l.AllowedRoutes.Namespaces.Selector
+	}
+	switch from {
+	case api.NamespacesFromAll:
+		return true
+	case api.NamespacesFromSelector:
+		if selector == nil {
+			return false
+		}
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return false
+		}
+		return s.Matches(labels.Set(routeNamespaceLabels))
+	default:
+		return routeNamespace == gwNamespace
+	}
+}
+
+// ParentRefListeners returns the indexes of the Gateway listeners a route of the given kind attaches to through a
+// parentRef selecting the Gateway. reports are the validation reports of the listeners. When the route attaches to
+// no listener, the returned reason tells why.
+func ParentRefListeners(
+	gw *api.Gateway,
+	reports []ListenerReport,
+	ref api.ParentReference,
+	kind api.RouteGroupKind,
+	routeNamespace string,
+	routeNamespaceLabels map[string]string,
+	hostnames []api.Hostname,
+) ([]int, api.RouteConditionReason) {
+	reason := api.RouteReasonNoMatchingParent
+	var attached []int
+	for i, l := range gw.Spec.Listeners {
+		if !ParentRefSelectsListener(ref, l) {
+			continue
+		}
+		if !ListenerAllowsRoute(reports[i], l, gw.Namespace, kind, routeNamespace, routeNamespaceLabels) {
+			if reason == api.RouteReasonNoMatchingParent {
+				reason = api.RouteReasonNotAllowedByListeners
+			}
+			continue
+		}
+		if routeHostnames(l.Hostname, hostnames) == nil {
+			reason = api.RouteReasonNoMatchingListenerHostname
+			continue
+		}
+		attached = append(attached, i)
+	}
+	if len(attached) > 0 {
+		return attached, api.RouteReasonAccepted
+	}
+	return nil, reason
+}
+
+func sameKind(a, b api.RouteGroupKind) bool {
+	return a.Kind == b.Kind && ptr.Deref(a.Group, api.GroupName) == ptr.Deref(b.Group, api.GroupName)
+}
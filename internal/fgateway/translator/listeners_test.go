@@ -0,0 +1,531 @@
+package translator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// tlsSecret returns a TLS Secret holding a self-signed certificate for hostname
+func tlsSecret(t *testing.T, namespace, name, hostname string) *corev1.Secret {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hostname},
+		DNSNames:     []string{hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		},
+	}
+}
+
+// staticCertificates resolves certificateRefs to the given Secrets
+func staticCertificates(secrets []*corev1.Secret) CertificateResolver {
+	return CertificateResolver{
+		GetSecret: func(namespace, name string) *corev1.Secret {
+			for _, s := range secrets {
+				if s.Namespace == namespace && s.Name == name {
+					return s
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// conditionReasons returns the reason of each condition of a report, by type
+func conditionReasons(report ListenerReport) map[api.ListenerConditionType]string {
+	ret := map[api.ListenerConditionType]string{}
+	for _, c := range report.Conditions {
+		ret[api.ListenerConditionType(c.Type)] = c.Reason
+	}
+	return ret
+}
+
+func httpsListener(name string, port api.PortNumber, refs ...api.SecretObjectReference) api.Listener {
+	return api.Listener{
+		Name:     api.SectionName(name),
+		Port:     port,
+		Protocol: api.HTTPSProtocolType,
+		TLS:      &api.GatewayTLSConfig{CertificateRefs: refs},
+	}
+}
+
+func TestValidateListenersCertificates(t *testing.T) {
+	valid := tlsSecret(t, "default", "cert", "example.com")
+	other := tlsSecret(t, "certs", "cert", "example.com")
+	invalid := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "invalid"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("garbage"), corev1.TLSPrivateKeyKey: []byte("garbage")},
+	}
+	opaque := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "opaque"}, Type: corev1.SecretTypeOpaque}
+
+	tests := []struct {
+		name         string
+		listener     api.Listener
+		wantValid    bool
+		wantResolved string
+		wantCerts    int
+	}{
+		{
+			name:         "valid certificate",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Name: "cert"}),
+			wantValid:    true,
+			wantResolved: string(api.ListenerReasonResolvedRefs),
+			wantCerts:    1,
+		},
+		{
+			name:         "no certificateRefs",
+			listener:     httpsListener("https", 443),
+			wantResolved: string(api.ListenerReasonInvalidCertificateRef),
+		},
+		{
+			name:         "missing secret",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Name: "missing"}),
+			wantResolved: string(api.ListenerReasonInvalidCertificateRef),
+		},
+		{
+			name:         "secret that is not of the TLS type",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Name: "opaque"}),
+			wantResolved: string(api.ListenerReasonInvalidCertificateRef),
+		},
+		{
+			name:         "secret without a valid key pair",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Name: "invalid"}),
+			wantResolved: string(api.ListenerReasonInvalidCertificateRef),
+		},
+		{
+			name:         "unsupported kind",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Kind: ptr.To(api.Kind("ConfigMap")), Name: "cert"}),
+			wantResolved: string(api.ListenerReasonInvalidCertificateRef),
+		},
+		{
+			name:         "one invalid ref among valid ones keeps the listener programmed",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Name: "missing"}, api.SecretObjectReference{Name: "cert"}),
+			wantValid:    true,
+			wantResolved: string(api.ListenerReasonInvalidCertificateRef),
+			wantCerts:    1,
+		},
+		{
+			name:         "other namespace",
+			listener:     httpsListener("https", 443, api.SecretObjectReference{Namespace: ptr.To(api.Namespace("certs")), Name: "cert"}),
+			wantResolved: string(api.ListenerReasonRefNotPermitted),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := &api.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"},
+				Spec:       api.GatewaySpec{Listeners: []api.Listener{tt.listener}},
+			}
+			certs := staticCertificates([]*corev1.Secret{valid, other, invalid, opaque})
+			report := ValidateListeners(gw, certs)[0]
+			if report.Valid != tt.wantValid {
+				t.Errorf("valid = %v, want %v", report.Valid, tt.wantValid)
+			}
+			if got := conditionReasons(report)[api.ListenerConditionResolvedRefs]; got != tt.wantResolved {
+				t.Errorf("ResolvedRefs reason = %s, want %s", got, tt.wantResolved)
+			}
+			if len(report.Certificates) != tt.wantCerts {
+				t.Errorf("got %d certificates, want %d", len(report.Certificates), tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestValidateListenersTranslatedPorts(t *testing.T) {
+	gw := &api.Gateway{Spec: api.GatewaySpec{Listeners: []api.Listener{
+		{Name: "http", Port: 80, Protocol: api.HTTPProtocolType},
+		{Name: "alt", Port: 8080, Protocol: api.HTTPProtocolType},
+		{Name: "other", Port: 81, Protocol: api.HTTPProtocolType},
+	}}}
+	reports := ValidateListeners(gw, CertificateResolver{})
+	want := []struct {
+		valid    bool
+		accepted string
+	}{
+		{valid: false, accepted: string(api.ListenerReasonPortUnavailable)},
+		{valid: true, accepted: string(api.ListenerReasonAccepted)},
+		{valid: true, accepted: string(api.ListenerReasonAccepted)},
+	}
+	for i, report := range reports {
+		if report.Valid != want[i].valid {
+			t.Errorf("listener %s valid = %v, want %v", report.Name, report.Valid, want[i].valid)
+		}
+		if got := conditionReasons(report)[api.ListenerConditionAccepted]; got != want[i].accepted {
+			t.Errorf("listener %s Accepted reason = %s, want %s", report.Name, got, want[i].accepted)
+		}
+	}
+}
+
+func TestValidateListeners(t *testing.T) {
+	hostname := func(h string) *api.Hostname { return ptr.To(api.Hostname(h)) }
+	tests := []struct {
+		name      string
+		listeners []api.Listener
+		// want are the Valid field and the reasons of each listener, by condition type
+		wantValid []bool
+		want      []map[api.ListenerConditionType]string
+	}{
+		{
+			name: "http listener",
+			listeners: []api.Listener{
+				{Name: "http", Port: 80, Protocol: api.HTTPProtocolType},
+			},
+			wantValid: []bool{true},
+			want: []map[api.ListenerConditionType]string{{
+				api.ListenerConditionAccepted:     string(api.ListenerReasonAccepted),
+				api.ListenerConditionResolvedRefs: string(api.ListenerReasonResolvedRefs),
+				api.ListenerConditionConflicted:   string(api.ListenerReasonNoConflicts),
+				api.ListenerConditionProgrammed:   string(api.ListenerReasonProgrammed),
+			}},
+		},
+		{
+			name: "unsupported protocol",
+			listeners: []api.Listener{
+				{Name: "sctp", Port: 80, Protocol: "SCTP"},
+			},
+			wantValid: []bool{false},
+			want: []map[api.ListenerConditionType]string{{
+				api.ListenerConditionAccepted:   string(api.ListenerReasonUnsupportedProtocol),
+				api.ListenerConditionProgrammed: string(api.ListenerReasonInvalid),
+			}},
+		},
+		{
+			name: "tls listener terminating",
+			listeners: []api.Listener{
+				{Name: "tls", Port: 443, Protocol: api.TLSProtocolType, TLS: &api.GatewayTLSConfig{Mode: ptr.To(api.TLSModeTerminate)}},
+			},
+			wantValid: []bool{false},
+			want: []map[api.ListenerConditionType]string{{
+				api.ListenerConditionAccepted: string(api.ListenerReasonUnsupportedProtocol),
+			}},
+		},
+		{
+			name: "tls listener passing through",
+			listeners: []api.Listener{
+				{Name: "tls", Port: 443, Protocol: api.TLSProtocolType, TLS: &api.GatewayTLSConfig{Mode: ptr.To(api.TLSModePassthrough)}},
+			},
+			wantValid: []bool{true},
+			want: []map[api.ListenerConditionType]string{{
+				api.ListenerConditionAccepted: string(api.ListenerReasonAccepted),
+			}},
+		},
+		{
+			name: "https listener passing through",
+			listeners: []api.Listener{
+				{Name: "https", Port: 443, Protocol: api.HTTPSProtocolType, TLS: &api.GatewayTLSConfig{Mode: ptr.To(api.TLSModePassthrough)}},
+			},
+			wantValid: []bool{false},
+			want: []map[api.ListenerConditionType]string{{
+				api.ListenerConditionAccepted: string(api.ListenerReasonUnsupportedProtocol),
+			}},
+		},
+		{
+			name: "route kinds not supported by the protocol",
+			listeners: []api.Listener{{
+				Name:          "http",
+				Port:          80,
+				Protocol:      api.HTTPProtocolType,
+				AllowedRoutes: &api.AllowedRoutes{Kinds: []api.RouteGroupKind{{Kind: "HTTPRoute"}, {Kind: "TCPRoute"}}},
+			}},
+			wantValid: []bool{true},
+			want: []map[api.ListenerConditionType]string{{
+				api.ListenerConditionResolvedRefs: string(api.ListenerReasonInvalidRouteKinds),
+				api.ListenerConditionProgrammed:   string(api.ListenerReasonProgrammed),
+			}},
+		},
+		{
+			name: "protocols conflicting on a port",
+			listeners: []api.Listener{
+				{Name: "http", Port: 8000, Protocol: api.HTTPProtocolType},
+				{Name: "tcp", Port: 8000, Protocol: api.TCPProtocolType},
+				{Name: "other", Port: 8001, Protocol: api.TCPProtocolType},
+			},
+			wantValid: []bool{false, false, true},
+			want: []map[api.ListenerConditionType]string{
+				{api.ListenerConditionConflicted: string(api.ListenerReasonProtocolConflict)},
+				{api.ListenerConditionConflicted: string(api.ListenerReasonProtocolConflict)},
+				{api.ListenerConditionConflicted: string(api.ListenerReasonNoConflicts)},
+			},
+		},
+		{
+			name: "tcp and udp sharing a port",
+			listeners: []api.Listener{
+				{Name: "dns-tcp", Port: 53, Protocol: api.TCPProtocolType},
+				{Name: "dns-udp", Port: 53, Protocol: api.UDPProtocolType},
+			},
+			wantValid: []bool{true, true},
+			want: []map[api.ListenerConditionType]string{
+				{api.ListenerConditionConflicted: string(api.ListenerReasonNoConflicts)},
+				{api.ListenerConditionConflicted: string(api.ListenerReasonNoConflicts)},
+			},
+		},
+		{
+			name: "hostnames conflicting on a port",
+			listeners: []api.Listener{
+				{Name: "a", Port: 80, Protocol: api.HTTPProtocolType, Hostname: hostname("a.example.com")},
+				{Name: "a-again", Port: 80, Protocol: api.HTTPProtocolType, Hostname: hostname("a.example.com")},
+				{Name: "b", Port: 80, Protocol: api.HTTPProtocolType, Hostname: hostname("b.example.com")},
+			},
+			wantValid: []bool{false, false, true},
+			want: []map[api.ListenerConditionType]string{
+				{api.ListenerConditionConflicted: string(api.ListenerReasonHostnameConflict)},
+				{api.ListenerConditionConflicted: string(api.ListenerReasonHostnameConflict)},
+				{api.ListenerConditionConflicted: string(api.ListenerReasonNoConflicts)},
+			},
+		},
+		{
+			name: "listeners without hostname conflict",
+			listeners: []api.Listener{
+				{Name: "a", Port: 80, Protocol: api.HTTPProtocolType},
+				{Name: "b", Port: 80, Protocol: api.HTTPProtocolType},
+			},
+			wantValid: []bool{false, false},
+			want: []map[api.ListenerConditionType]string{
+				{api.ListenerConditionConflicted: string(api.ListenerReasonHostnameConflict)},
+				{api.ListenerConditionConflicted: string(api.ListenerReasonHostnameConflict)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := &api.Gateway{Spec: api.GatewaySpec{Listeners: tt.listeners}}
+			reports := ValidateListeners(gw, CertificateResolver{})
+			if len(reports) != len(tt.listeners) {
+				t.Fatalf("got %d reports, want %d", len(reports), len(tt.listeners))
+			}
+			for i, report := range reports {
+				if report.Name != tt.listeners[i].Name {
+					t.Errorf("report %d is of listener %s, want %s", i, report.Name, tt.listeners[i].Name)
+				}
+				if report.Valid != tt.wantValid[i] {
+					t.Errorf("listener %s valid = %v, want %v", report.Name, report.Valid, tt.wantValid[i])
+				}
+				got := conditionReasons(report)
+				for typ, reason := range tt.want[i] {
+					if got[typ] != reason {
+						t.Errorf("listener %s %s reason = %s, want %s", report.Name, typ, got[typ], reason)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParentRefSelectsListener(t *testing.T) {
+	gw := &api.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "gw"}}
+	l := api.Listener{Name: "http", Port: 80, Protocol: api.HTTPProtocolType}
+	tests := []struct {
+		name      string
+		ref       api.ParentReference
+		namespace string
+		want      bool
+	}{
+		{name: "whole gateway", ref: api.ParentReference{Name: "gw"}, namespace: "infra", want: true},
+		{name: "gateway of another namespace", ref: api.ParentReference{Name: "gw"}, namespace: "apps"},
+		{name: "explicit namespace", ref: api.ParentReference{Name: "gw", Namespace: ptr.To[api.Namespace]("infra")}, namespace: "apps", want: true},
+		{name: "another kind", ref: api.ParentReference{Name: "gw", Kind: ptr.To[api.Kind]("Service")}, namespace: "infra"},
+		{name: "section name", ref: api.ParentReference{Name: "gw", SectionName: ptr.To[api.SectionName]("http")}, namespace: "infra", want: true},
+		{name: "another section", ref: api.ParentReference{Name: "gw", SectionName: ptr.To[api.SectionName]("https")}, namespace: "infra"},
+		{name: "port", ref: api.ParentReference{Name: "gw", Port: ptr.To[api.PortNumber](80)}, namespace: "infra", want: true},
+		{name: "another port", ref: api.ParentReference{Name: "gw", Port: ptr.To[api.PortNumber](443)}, namespace: "infra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParentRefSelectsGateway(tt.ref, tt.namespace, gw) && ParentRefSelectsListener(tt.ref, l)
+			if got != tt.want {
+				t.Errorf("parentRef selects the listener = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListenerAllowsRoute(t *testing.T) {
+	httpRoute := api.RouteGroupKind{Kind: "HTTPRoute"}
+	listener := func(from api.FromNamespaces, selector *metav1.LabelSelector) api.Listener {
+		return api.Listener{
+			Name:     "http",
+			Port:     80,
+			Protocol: api.HTTPProtocolType,
+			AllowedRoutes: &api.AllowedRoutes{Namespaces: &api.RouteNamespaces{
+				From:     ptr.To(from),
+				Selector: selector,
+			}},
+		}
+	}
+	teamA := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	tests := []struct {
+		name      string
+		listener  api.Listener
+		kind      api.RouteGroupKind
+		namespace string
+		nsLabels  map[string]string
+		want      bool
+	}{
+		{name: "same namespace by default", listener: api.Listener{Name: "http", Port: 80, Protocol: api.HTTPProtocolType}, kind: httpRoute, namespace: "infra", want: true},
+		{name: "other namespace by default", listener: api.Listener{Name: "http", Port: 80, Protocol: api.HTTPProtocolType}, kind: httpRoute, namespace: "apps"},
+		{name: "all namespaces", listener: listener(api.NamespacesFromAll, nil), kind: httpRoute, namespace: "apps", want: true},
+		{name: "selected namespace", listener: listener(api.NamespacesFromSelector, teamA), kind: httpRoute, namespace: "apps", nsLabels: map[string]string{"team": "a"}, want: true},
+		{name: "namespace not selected", listener: listener(api.NamespacesFromSelector, teamA), kind: httpRoute, namespace: "apps", nsLabels: map[string]string{"team": "b"}},
+		{name: "selector missing", listener: listener(api.NamespacesFromSelector, nil), kind: httpRoute, namespace: "apps"},
+		{name: "unsupported kind", listener: listener(api.NamespacesFromAll, nil), kind: api.RouteGroupKind{Kind: "TCPRoute"}, namespace: "apps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := &api.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "gw"},
+				Spec:       api.GatewaySpec{Listeners: []api.Listener{tt.listener}},
+			}
+			report := ValidateListeners(gw, CertificateResolver{})[0]
+			if got := ListenerAllowsRoute(report, tt.listener, "infra", tt.kind, tt.namespace, tt.nsLabels); got != tt.want {
+				t.Errorf("ListenerAllowsRoute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentRefListeners(t *testing.T) {
+	gw := &api.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "gw"},
+		Spec: api.GatewaySpec{Listeners: []api.Listener{
+			{Name: "same", Port: 80, Protocol: api.HTTPProtocolType, Hostname: ptr.To(api.Hostname("*.example.com"))},
+			{
+				Name:     "all",
+				Port:     8080,
+				Protocol: api.HTTPProtocolType,
+				AllowedRoutes: &api.AllowedRoutes{Namespaces: &api.RouteNamespaces{
+					From: ptr.To(api.NamespacesFromAll),
+				}},
+			},
+			{
+				Name:     "selected",
+				Port:     8081,
+				Protocol: api.HTTPProtocolType,
+				AllowedRoutes: &api.AllowedRoutes{Namespaces: &api.RouteNamespaces{
+					From:     ptr.To(api.NamespacesFromSelector),
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				}},
+			},
+			{Name: "tcp", Port: 9000, Protocol: api.TCPProtocolType},
+		}},
+	}
+	reports := ValidateListeners(gw, CertificateResolver{})
+	tests := []struct {
+		name       string
+		ref        api.ParentReference
+		kind       api.RouteGroupKind
+		namespace  string
+		nsLabels   map[string]string
+		hostnames  []api.Hostname
+		want       []int
+		wantReason api.RouteConditionReason
+	}{
+		{
+			name:       "whole gateway from its namespace",
+			ref:        api.ParentReference{Name: "gw"},
+			kind:       HTTPRouteKind,
+			namespace:  "infra",
+			want:       []int{0, 1},
+			wantReason: api.RouteReasonAccepted,
+		},
+		{
+			name:       "whole gateway from a selected namespace",
+			ref:        api.ParentReference{Name: "gw"},
+			kind:       HTTPRouteKind,
+			namespace:  "apps",
+			nsLabels:   map[string]string{"team": "a"},
+			want:       []int{1, 2},
+			wantReason: api.RouteReasonAccepted,
+		},
+		{
+			name:       "section name",
+			ref:        api.ParentReference{Name: "gw", SectionName: ptr.To(api.SectionName("all"))},
+			kind:       HTTPRouteKind,
+			namespace:  "apps",
+			want:       []int{1},
+			wantReason: api.RouteReasonAccepted,
+		},
+		{
+			name:       "port",
+			ref:        api.ParentReference{Name: "gw", Port: ptr.To(api.PortNumber(8081))},
+			kind:       HTTPRouteKind,
+			namespace:  "apps",
+			nsLabels:   map[string]string{"team": "a"},
+			want:       []int{2},
+			wantReason: api.RouteReasonAccepted,
+		},
+		{
+			name:       "namespace not allowed",
+			ref:        api.ParentReference{Name: "gw", SectionName: ptr.To(api.SectionName("same"))},
+			kind:       HTTPRouteKind,
+			namespace:  "apps",
+			wantReason: api.RouteReasonNotAllowedByListeners,
+		},
+		{
+			name:       "kind not allowed",
+			ref:        api.ParentReference{Name: "gw", SectionName: ptr.To(api.SectionName("tcp"))},
+			kind:       HTTPRouteKind,
+			namespace:  "infra",
+			wantReason: api.RouteReasonNotAllowedByListeners,
+		},
+		{
+			name:       "hostname not matching the listener",
+			ref:        api.ParentReference{Name: "gw", SectionName: ptr.To(api.SectionName("same"))},
+			kind:       HTTPRouteKind,
+			namespace:  "infra",
+			hostnames:  []api.Hostname{"other.com"},
+			wantReason: api.RouteReasonNoMatchingListenerHostname,
+		},
+		{
+			name:       "unknown section",
+			ref:        api.ParentReference{Name: "gw", SectionName: ptr.To(api.SectionName("missing"))},
+			kind:       HTTPRouteKind,
+			namespace:  "infra",
+			wantReason: api.RouteReasonNoMatchingParent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := ParentRefListeners(gw, reports, tt.ref, tt.kind, tt.namespace, tt.nsLabels, tt.hostnames)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("listeners = %v, want %v", got, tt.want)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %s, want %s", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package translator
+
+import (
+	"slices"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxProxySelectors bounds the distinct proxy selectors of the routes of a Gateway, as its configuration is
+// translated once for every combination of them
+const maxProxySelectors = 4
+
+// ProxySelector returns the selector of the proxies a route applies to, which is every proxy when the route
+// has no proxy selector annotation. Policies are not scoped to proxies: a cluster is translated the same way in
+// every variant of the configuration of a Gateway, whichever routes of the variant refer to it.
+func ProxySelector(route metav1.Object) (labels.Selector, error) {
+	value, ok := route.GetAnnotations()[wellknown.ProxySelectorAnnotation]
+	if !ok {
+		return labels.Everything(), nil
+	}
+	selector, err := labels.Parse(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s annotation", wellknown.ProxySelectorAnnotation)
+	}
+	return selector, nil
+}
+
+// ProxySelectorError returns why a route is served by none of the proxies of a Gateway whose proxy selectors, as
+// translated in its IR, are selectors: its proxy selector is invalid, or it is not one of them, having been left out
+// of the first maxProxySelectors distinct selectors of the oldest routes of the Gateway
+func ProxySelectorError(route metav1.Object, selectors []string) error {
+	selector, err := ProxySelector(route)
+	if err != nil || selector.Empty() {
+		return err
+	}
+	if !slices.Contains(selectors, selector.String()) {
+		return errors.Errorf("proxy selector %q is not served, a Gateway supports at most %d distinct proxy selectors",
+			selector.String(), maxProxySelectors)
+	}
+	return nil
+}
+
+// proxySelectors returns the distinct proxy selectors of the routes, in the order of the oldest route using each
+// of them, keeping the first maxProxySelectors: a route bringing a new selector beyond the limit is left out rather
+// than the selector of an existing route, and the selectors keep their index, hence the variants their name, as
+// routes are added. The ones left out are returned apart, along with the invalid ones.
+func proxySelectors(routes []metav1.Object) (selectors []string, dropped []string) {
+	for _, route := range slices.SortedStableFunc(slices.Values(routes), compareRoutes) {
+		selector, err := ProxySelector(route)
+		if err != nil {
+			dropped = append(dropped, route.GetAnnotations()[wellknown.ProxySelectorAnnotation])
+			continue
+		}
+		if selector.Empty() || slices.Contains(selectors, selector.String()) || slices.Contains(dropped, selector.String()) {
+			continue
+		}
+		if len(selectors) == maxProxySelectors {
+			dropped = append(dropped, selector.String())
+			continue
+		}
+		selectors = append(selectors, selector.String())
+	}
+	return selectors, dropped
+}
+
+// routeInVariant reports whether a route applies to the proxies of a variant of the configuration of its Gateway.
+// The routes with an invalid selector, or one left out of the selectors of the Gateway, apply to no proxy.
+func routeInVariant(route metav1.Object, selectors []string, variant uint32) bool {
+	selector, err := ProxySelector(route)
+	if err != nil {
+		return false
+	}
+	if selector.Empty() {
+		return true
+	}
+	idx := slices.Index(selectors, selector.String())
+	return idx != -1 && variant&(1<<idx) != 0
+}
+
+// selectRoutes returns the routes applying to the proxies of a variant
+func selectRoutes[T metav1.Object](routes []T, selectors []string, variant uint32) []T {
+	return slices.DeleteFunc(slices.Clone(routes), func(r T) bool {
+		return !routeInVariant(r, selectors, variant)
+	})
+}
+
+// ClientVariant returns the variant of the configuration of a Gateway served to a proxy with the given pod labels:
+// the set of the proxy selectors of the Gateway matching them
+func ClientVariant(gw ir.GatewayIR, podLabels map[string]string) uint32 {
+	var variant uint32
+	for i, s := range gw.ProxySelectors {
+		// the selectors of the IR are the canonical forms of valid selectors
+		selector, err := labels.Parse(s)
+		if err == nil && selector.Matches(labels.Set(podLabels)) {
+			variant |= 1 << i
+		}
+	}
+	return variant
+}
@@ -0,0 +1,111 @@
+package translator
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// withProxySelector restricts a route to the proxies matching selector
+func withProxySelector(route *api.HTTPRoute, selector string) *api.HTTPRoute {
+	route.Annotations = map[string]string{wellknown.ProxySelectorAnnotation: selector}
+	return route
+}
+
+func TestGatewayCollectionProxySelectors(t *testing.T) {
+	gw := testGateway("gw", api.Listener{Name: "http", Port: 8080, Protocol: api.HTTPProtocolType})
+	gateways := translateGateways(t,
+		gw,
+		testService("default", "web", 80),
+		testService("default", "canary", 80),
+		testHTTPRoute("stable", "gw", []api.Hostname{"stable.example.com"}, backendRef("web", 80)),
+		withProxySelector(testHTTPRoute("canary", "gw", []api.Hostname{"canary.example.com"}, backendRef("canary", 80)), "track=canary"),
+		withProxySelector(testHTTPRoute("east", "gw", []api.Hostname{"east.example.com"}, backendRef("web", 80)), "topology.kubernetes.io/region in (us-east1)"),
+		withProxySelector(testHTTPRoute("invalid", "gw", []api.Hostname{"invalid.example.com"}, backendRef("web", 80)), "track in canary"),
+	)
+	role := xds.GatewayProxyRole("default", "gw")
+	domains := func(variant uint32) []string {
+		t.Helper()
+		out, ok := gateways[ir.GatewayVariantName(role, variant)]
+		if !ok {
+			t.Fatalf("variant %d is not translated", variant)
+		}
+		return slices.Sorted(maps.Keys(vhostDomains(out.Routes[0])))
+	}
+
+	base := gateways[role]
+	// the selectors are in the order of the routes using them, by age then name
+	if want := []string{"track=canary", "topology.kubernetes.io/region in (us-east1)"}; !slices.Equal(base.ProxySelectors, want) {
+		t.Errorf("proxy selectors = %v, want %v", base.ProxySelectors, want)
+	}
+	if len(gateways) != 4 {
+		t.Errorf("gateway is translated %d times, want one per combination of its proxy selectors", len(gateways))
+	}
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		variant uint32
+		want    []string
+	}{
+		{name: "no labels", want: []string{"stable.example.com"}},
+		{name: "canary", labels: map[string]string{"track": "canary"}, variant: 1, want: []string{"canary.example.com", "stable.example.com"}},
+		{
+			name:    "canary in us-east1",
+			labels:  map[string]string{"track": "canary", "topology.kubernetes.io/region": "us-east1"},
+			variant: 3,
+			want:    []string{"canary.example.com", "east.example.com", "stable.example.com"},
+		},
+		{name: "stable in another region", labels: map[string]string{"track": "stable", "topology.kubernetes.io/region": "eu-west1"}, want: []string{"stable.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant := ClientVariant(base, tt.labels)
+			if variant != tt.variant {
+				t.Errorf("ClientVariant() = %d, want %d", variant, tt.variant)
+			}
+			if got := domains(variant); !slices.Equal(got, tt.want) {
+				t.Errorf("domains = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxySelectorsLimit(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var routes []*api.HTTPRoute
+	// the route of the newest selector sorts first by name, but comes last by age
+	for i, track := range []string{"e", "d", "c", "b", "a"} {
+		route := withProxySelector(testHTTPRoute(track, "gw", nil), "track="+track)
+		route.CreationTimestamp = metav1.NewTime(created.Add(time.Duration(i) * time.Minute))
+		routes = append(routes, route)
+	}
+	// a newer route sharing the selector of an older one is served
+	shared := withProxySelector(testHTTPRoute("b2", "gw", nil), "track=b")
+	shared.CreationTimestamp = metav1.NewTime(created.Add(time.Hour))
+	routes = append(routes, shared, testHTTPRoute("all", "gw", nil))
+	selectors, dropped := proxySelectors(appendObjects(nil, routes))
+	if want := []string{"track=e", "track=d", "track=c", "track=b"}; !slices.Equal(selectors, want) {
+		t.Errorf("selectors = %v, want %v", selectors, want)
+	}
+	if want := []string{"track=a"}; !slices.Equal(dropped, want) {
+		t.Errorf("dropped = %v, want %v", dropped, want)
+	}
+	// the routes of a dropped selector apply to no proxy
+	if got := resourceNames(selectRoutes(routes, selectors, 1<<maxProxySelectors-1)); slices.Contains(got, "a") {
+		t.Errorf("routes = %v, want the route of the dropped selector left out", got)
+	}
+	// and report it
+	for _, route := range routes {
+		err := ProxySelectorError(route, selectors)
+		if (err != nil) != (route.Name == "a") {
+			t.Errorf("ProxySelectorError(%s) = %v, want an error for the route of the dropped selector only", route.Name, err)
+		}
+	}
+}
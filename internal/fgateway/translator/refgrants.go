@@ -0,0 +1,52 @@
+package translator
+
+import (
+	"istio.io/istio/pkg/kube/krt"
+	"k8s.io/utils/ptr"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// ReferenceGrants checks the references of Gateways and routes to objects of another namespace against the
+// ReferenceGrants of that namespace
+type ReferenceGrants struct {
+	// List returns the ReferenceGrants of a namespace
+	List func(namespace string) []*apiv1beta1.ReferenceGrant
+}
+
+// krtReferenceGrants looks ReferenceGrants up in the collection, recording them as dependencies of the handler context
+func krtReferenceGrants(kctx krt.HandlerContext, grants krt.Collection[*apiv1beta1.ReferenceGrant], byNamespace krt.Index[string, *apiv1beta1.ReferenceGrant]) ReferenceGrants {
+	return ReferenceGrants{
+		List: func(namespace string) []*apiv1beta1.ReferenceGrant {
+			return krt.Fetch(kctx, grants, krt.FilterIndex(byNamespace, namespace))
+		},
+	}
+}
+
+// Allowed reports whether an object described by from may refer to the object described by to in toNamespace.
+// References within a namespace are always allowed, the others need a ReferenceGrant in the namespace they refer to.
+func (g ReferenceGrants) Allowed(from apiv1beta1.ReferenceGrantFrom, toNamespace string, to apiv1beta1.ReferenceGrantTo) bool {
+	if string(from.Namespace) == toNamespace {
+		return true
+	}
+	if g.List == nil {
+		return false
+	}
+	for _, grant := range g.List(toNamespace) {
+		fromAllowed := false
+		for _, f := range grant.Spec.From {
+			if f.Group == from.Group && f.Kind == from.Kind && f.Namespace == from.Namespace {
+				fromAllowed = true
+				break
+			}
+		}
+		if !fromAllowed {
+			continue
+		}
+		for _, t := range grant.Spec.To {
+			if t.Group == to.Group && t.Kind == to.Kind && (t.Name == nil || ptr.Deref(to.Name, "") == *t.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package translator
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// serviceGrant allows the HTTPRoutes of fromNamespace to refer to the Services of namespace, or only to the one
+// named name when set
+func serviceGrant(namespace, fromNamespace string, name *string) *apiv1beta1.ReferenceGrant {
+	return &apiv1beta1.ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "from-" + fromNamespace},
+		Spec: apiv1beta1.ReferenceGrantSpec{
+			From: []apiv1beta1.ReferenceGrantFrom{{Group: api.GroupName, Kind: "HTTPRoute", Namespace: api.Namespace(fromNamespace)}},
+			To:   []apiv1beta1.ReferenceGrantTo{{Kind: "Service", Name: (*api.ObjectName)(name)}},
+		},
+	}
+}
+
+// staticGrants lists the given ReferenceGrants by namespace
+func staticGrants(grants []*apiv1beta1.ReferenceGrant) ReferenceGrants {
+	return ReferenceGrants{
+		List: func(namespace string) []*apiv1beta1.ReferenceGrant {
+			var ret []*apiv1beta1.ReferenceGrant
+			for _, g := range grants {
+				if g.Namespace == namespace {
+					ret = append(ret, g)
+				}
+			}
+			return ret
+		},
+	}
+}
+
+func TestReferenceGrantsAllowed(t *testing.T) {
+	routeFrom := func(ns string) apiv1beta1.ReferenceGrantFrom {
+		return apiv1beta1.ReferenceGrantFrom{Group: api.GroupName, Kind: "HTTPRoute", Namespace: api.Namespace(ns)}
+	}
+	service := func(name string) apiv1beta1.ReferenceGrantTo {
+		return apiv1beta1.ReferenceGrantTo{Kind: "Service", Name: ptr.To(api.ObjectName(name))}
+	}
+	tests := []struct {
+		name   string
+		grants []*apiv1beta1.ReferenceGrant
+		from   apiv1beta1.ReferenceGrantFrom
+		toNs   string
+		to     apiv1beta1.ReferenceGrantTo
+		want   bool
+	}{
+		{name: "same namespace", from: routeFrom("apps"), toNs: "apps", to: service("web"), want: true},
+		{name: "no grant", from: routeFrom("apps"), toNs: "backends", to: service("web")},
+		{
+			name:   "grant of every service",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("backends", "apps", nil)},
+			from:   routeFrom("apps"), toNs: "backends", to: service("web"), want: true,
+		},
+		{
+			name:   "grant of the service",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("backends", "apps", ptr.To("web"))},
+			from:   routeFrom("apps"), toNs: "backends", to: service("web"), want: true,
+		},
+		{
+			name:   "grant of another service",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("backends", "apps", ptr.To("db"))},
+			from:   routeFrom("apps"), toNs: "backends", to: service("web"),
+		},
+		{
+			name:   "grant to another namespace",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("backends", "other", nil)},
+			from:   routeFrom("apps"), toNs: "backends", to: service("web"),
+		},
+		{
+			name:   "grant in another namespace",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("other", "apps", nil)},
+			from:   routeFrom("apps"), toNs: "backends", to: service("web"),
+		},
+		{
+			name:   "grant of another kind",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("backends", "apps", nil)},
+			from:   apiv1beta1.ReferenceGrantFrom{Group: api.GroupName, Kind: "GRPCRoute", Namespace: "apps"},
+			toNs:   "backends", to: service("web"),
+		},
+		{
+			name:   "grant of services does not allow secrets",
+			grants: []*apiv1beta1.ReferenceGrant{serviceGrant("backends", "apps", nil)},
+			from:   routeFrom("apps"), toNs: "backends", to: apiv1beta1.ReferenceGrantTo{Kind: "Secret", Name: ptr.To[api.ObjectName]("web")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staticGrants(tt.grants).Allowed(tt.from, tt.toNs, tt.to); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (ReferenceGrants{}).Allowed(routeFrom("apps"), "backends", service("web")) {
+		t.Errorf("references to another namespace are allowed without a grant lister")
+	}
+}
+
+func TestBackendResolverReasons(t *testing.T) {
+	services := []*corev1.Service{testService("backends", "web", 8080)}
+	resolver := BackendResolver{
+		GetService: func(namespace, name string) *corev1.Service {
+			for _, s := range services {
+				if s.Namespace == namespace && s.Name == name {
+					return s
+				}
+			}
+			return nil
+		},
+		Grants: staticGrants([]*apiv1beta1.ReferenceGrant{serviceGrant("backends", "granted", nil)}),
+	}
+	ref := func(name string, port *api.PortNumber) api.BackendObjectReference {
+		return api.BackendObjectReference{Name: api.ObjectName(name), Namespace: ptr.To[api.Namespace]("backends"), Port: port}
+	}
+	tests := []struct {
+		name        string
+		ref         api.BackendObjectReference
+		namespace   string
+		wantCluster string
+		wantReason  api.RouteConditionReason
+	}{
+		{name: "granted", ref: ref("web", ptr.To[api.PortNumber](8080)), namespace: "granted", wantCluster: "kube_backends_web_8080"},
+		{name: "not granted", ref: ref("web", ptr.To[api.PortNumber](8080)), namespace: "apps", wantReason: api.RouteReasonRefNotPermitted},
+		{name: "no port", ref: ref("web", nil), namespace: "backends", wantReason: api.RouteReasonBackendNotFound},
+		{name: "missing service", ref: ref("db", ptr.To[api.PortNumber](5432)), namespace: "backends", wantReason: api.RouteReasonBackendNotFound},
+		{name: "missing port", ref: ref("web", ptr.To[api.PortNumber](80)), namespace: "backends", wantReason: api.RouteReasonBackendNotFound},
+		{
+			name:       "unsupported kind",
+			ref:        api.BackendObjectReference{Kind: ptr.To[api.Kind]("Backend"), Group: ptr.To[api.Group]("example.com"), Name: "web"},
+			namespace:  "backends",
+			wantReason: api.RouteReasonInvalidKind,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, err := resolver.Resolve(tt.ref, HTTPRouteKind, tt.namespace)
+			if cluster != tt.wantCluster {
+				t.Errorf("cluster = %q, want %q", cluster, tt.wantCluster)
+			}
+			var refErr *BackendRefError
+			switch {
+			case tt.wantReason == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantReason != "" && (!errors.As(err, &refErr) || refErr.Reason != tt.wantReason):
+				t.Errorf("error = %v, want reason %s", err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestGatewayCollectionReferenceGrants(t *testing.T) {
+	gw := testGateway("gw",
+		api.Listener{Name: "same", Port: 8080, Protocol: api.HTTPProtocolType, Hostname: ptr.To[api.Hostname]("same.example.com")},
+		api.Listener{
+			Name: "all", Port: 8080, Protocol: api.HTTPProtocolType, Hostname: ptr.To[api.Hostname]("all.example.com"),
+			AllowedRoutes: &api.AllowedRoutes{Namespaces: &api.RouteNamespaces{From: ptr.To(api.NamespacesFromAll)}},
+		},
+	)
+	crossNamespace := func(name string, hostname api.Hostname, backend string) *api.HTTPRoute {
+		route := testHTTPRoute(name, "gw", []api.Hostname{hostname}, api.HTTPBackendRef{BackendRef: api.BackendRef{
+			BackendObjectReference: api.BackendObjectReference{
+				Name:      api.ObjectName(backend),
+				Namespace: ptr.To[api.Namespace]("backends"),
+				Port:      ptr.To[api.PortNumber](8080),
+			},
+		}})
+		route.Namespace = "apps"
+		route.Spec.ParentRefs[0].Namespace = ptr.To[api.Namespace]("default")
+		return route
+	}
+	gateways := translateGateways(t,
+		gw,
+		testService("backends", "web", 8080),
+		testService("backends", "db", 8080),
+		serviceGrant("backends", "apps", ptr.To("web")),
+		crossNamespace("granted", "all.example.com", "web"),
+		crossNamespace("not-granted", "all.example.com", "db"),
+		// the listener of the hostname only allows the routes of the namespace of the Gateway
+		crossNamespace("not-allowed", "same.example.com", "web"),
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+	if len(out.Routes) != 1 {
+		t.Fatalf("route configurations = %v, want one", out.Routes)
+	}
+	if got, want := resourceNames(out.Clusters), []string{"kube_backends_web_8080"}; !slices.Equal(got, want) {
+		t.Errorf("clusters = %v, want %v", got, want)
+	}
+	domains := vhostDomains(out.Routes[0])
+	if got, want := domains["all.example.com"], []string{"apps/granted~0~0", "apps/not-granted~0~0"}; !slices.Equal(got, want) {
+		t.Errorf("routes of all.example.com = %v, want %v", got, want)
+	}
+	if got, ok := domains["same.example.com"]; ok {
+		t.Errorf("routes of same.example.com = %v, want no route from another namespace", got)
+	}
+	for _, vh := range out.Routes[0].GetVirtualHosts() {
+		for _, r := range vh.GetRoutes() {
+			if r.GetName() == "apps/not-granted~0~0" && r.GetDirectResponse().GetStatus() != 500 {
+				t.Errorf("route to a Service without grant = %v, want a 500 direct response", r.GetAction())
+			}
+		}
+	}
+}
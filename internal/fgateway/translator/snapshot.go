@@ -0,0 +1,225 @@
+package translator
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoytypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/hashutil"
+	"github.com/fleezesd/fgateway/internal/fgateway/utils/krtutil"
+	"github.com/pkg/errors"
+	"github.com/solo-io/go-utils/contextutils"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"istio.io/istio/pkg/kube/krt"
+)
+
+// ClientSnapshot is the xds snapshot served to a uniquely connected client
+type ClientSnapshot struct {
+	// ClientName is the resource name of the client, which is the key of the snapshot in the cache
+	ClientName string
+	Snapshot   *envoycache.Snapshot
+}
+
+func (c ClientSnapshot) ResourceName() string {
+	return c.ClientName
+}
+
+// MarshalJSON lists the secrets of the snapshot by name only, as they hold private keys
+func (c ClientSnapshot) MarshalJSON() ([]byte, error) {
+	type clientSnapshot ClientSnapshot
+	if c.Snapshot != nil {
+		snapshot := *c.Snapshot
+		secrets := snapshot.Resources[envoytypes.Secret]
+		redacted := make(map[string]envoytypes.ResourceWithTTL, len(secrets.Items))
+		for name := range secrets.Items {
+			redacted[name] = envoytypes.ResourceWithTTL{}
+		}
+		snapshot.Resources[envoytypes.Secret] = envoycache.Resources{Version: secrets.Version, Items: redacted}
+		c.Snapshot = &snapshot
+	}
+	return json.Marshal(clientSnapshot(c))
+}
+
+// Equals compares the snapshots by the versions of their resources, which are hashes of their content
+func (c ClientSnapshot) Equals(in ClientSnapshot) bool {
+	if c.ClientName != in.ClientName {
+		return false
+	}
+	for i := range c.Snapshot.Resources {
+		if c.Snapshot.Resources[i].Version != in.Snapshot.Resources[i].Version {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClientSnapshots builds the snapshot of every uniquely connected client from the IR of the Gateway it proxies,
+// which is found by the role of the client and the variant matching its labels, and the endpoints of the EDS clusters of the Gateway prioritized by
+// their locality relative to the one of the client
+func NewClientSnapshots(
+	ctx context.Context,
+	gateways krt.Collection[ir.GatewayIR],
+	endpoints krt.Collection[ir.BackendEndpoints],
+	clients krt.Collection[ir.UniqlyConnectedClient],
+	krtOpts krtutil.KrtOptions,
+) krt.Collection[ClientSnapshot] {
+	logger := contextutils.LoggerFrom(ctx).Desugar()
+	return krt.NewCollection(clients, func(kctx krt.HandlerContext, client ir.UniqlyConnectedClient) *ClientSnapshot {
+		gw := krt.FetchOne(kctx, gateways, krt.FilterKey(client.Role))
+		if gw == nil {
+			return nil
+		}
+		if variant := ClientVariant(*gw, client.Labels); variant != 0 {
+			gw = krt.FetchOne(kctx, gateways, krt.FilterKey(ir.GatewayVariantName(client.Role, variant)))
+			if gw == nil {
+				return nil
+			}
+		}
+		var backends []ir.BackendEndpoints
+		if names := edsClusterNames(gw.Clusters); len(names) > 0 {
+			backends = krt.Fetch(kctx, endpoints, krt.FilterKeys(names...))
+		}
+		snapshot, err := BuildSnapshot(*gw, backends, client.Locality)
+		if err != nil {
+			logger.Error("failed to build xds snapshot", zap.String("client", client.ResourceName()), zap.Error(err))
+			return nil
+		}
+		return &ClientSnapshot{ClientName: client.ResourceName(), Snapshot: snapshot}
+	}, krtOpts.ApplyTo("ClientSnapshots")...)
+}
+
+// BuildSnapshot assembles the xds snapshot of a Gateway proxy in the given locality. Every EDS cluster gets a load
+// assignment, which is empty when the backend has no ready endpoints, so that the proxy does not wait for it.
+// The version of every resource type is the hash of its resources.
+func BuildSnapshot(gw ir.GatewayIR, backends []ir.BackendEndpoints, locality ir.LocalityPod) (*envoycache.Snapshot, error) {
+	endpointsByCluster := make(map[string][]ir.Endpoint, len(backends))
+	for _, b := range backends {
+		endpointsByCluster[b.ClusterName] = b.Endpoints
+	}
+	var assignments []*envoy_config_endpoint_v3.ClusterLoadAssignment
+	for _, name := range edsClusterNames(gw.Clusters) {
+		assignments = append(assignments, loadAssignment(name, endpointsByCluster[name], locality))
+	}
+
+	snapshot := &envoycache.Snapshot{}
+	for typ, resources := range map[envoytypes.ResponseType][]envoytypes.Resource{
+		envoytypes.Listener: asResources(gw.Listeners),
+		envoytypes.Route:    asResources(gw.Routes),
+		envoytypes.Cluster:  asResources(gw.Clusters),
+		envoytypes.Endpoint: asResources(assignments),
+		envoytypes.Secret:   asResources(gw.Secrets),
+	} {
+		version, err := hashutil.HashProtos(resources)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to hash xds resources")
+		}
+		snapshot.Resources[typ] = envoycache.NewResources(strconv.FormatUint(version, 10), resources)
+	}
+	return snapshot, nil
+}
+
+// loadAssignment groups the endpoints of a cluster by locality. Every locality is weighted by its number of
+// endpoints, and prioritized relative to the locality of the client, so that its proxy only sends requests to
+// the endpoints of other zones, then of other regions, when the ones closer to it are unhealthy. The endpoints
+// that only have a zone are grouped with the other endpoints of the zone, whose region they take.
+func loadAssignment(clusterName string, endpoints []ir.Endpoint, clientLocality ir.LocalityPod) *envoy_config_endpoint_v3.ClusterLoadAssignment {
+	cla := &envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: clusterName}
+	byLocality := map[ir.LocalityPod]*envoy_config_endpoint_v3.LocalityLbEndpoints{}
+	regions := zoneRegions(endpoints, clientLocality)
+	for _, ep := range endpoints {
+		locality := withZoneRegion(ep.Locality, regions)
+		group, ok := byLocality[locality]
+		if !ok {
+			group = &envoy_config_endpoint_v3.LocalityLbEndpoints{
+				Locality: &envoy_config_core_v3.Locality{
+					Region:  locality.Region,
+					Zone:    locality.Zone,
+					SubZone: locality.Subzone,
+				},
+				LoadBalancingWeight: wrapperspb.UInt32(0),
+				Priority:            localityPriority(clientLocality, locality),
+			}
+			byLocality[locality] = group
+			cla.Endpoints = append(cla.Endpoints, group)
+		}
+		group.LoadBalancingWeight.Value++
+		group.LbEndpoints = append(group.LbEndpoints, &envoy_config_endpoint_v3.LbEndpoint{
+			HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{
+				Endpoint: &envoy_config_endpoint_v3.Endpoint{
+					Address: &envoy_config_core_v3.Address{
+						Address: &envoy_config_core_v3.Address_SocketAddress{
+							SocketAddress: &envoy_config_core_v3.SocketAddress{
+								Address:       ep.Address,
+								PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{PortValue: ep.Port},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	compactPriorities(cla.Endpoints)
+	return cla
+}
+
+// localityPriority ranks the locality of an endpoint from the one of the client: the endpoints of its zone come
+// first, then the ones of its region, then all the others. Zone names are unique across regions, and the endpoints
+// without a known pod may only have a zone, so the region does not need to match for the zone to. A client of an
+// unknown locality has all the endpoints at the same priority.
+func localityPriority(client, ep ir.LocalityPod) uint32 {
+	switch {
+	case client == (ir.LocalityPod{}):
+		return 0
+	case client.Zone != "" && ep.Zone == client.Zone:
+		return 0
+	case client.Region != "" && ep.Region == client.Region:
+		return 1
+	}
+	return 2
+}
+
+// compactPriorities renumbers the priorities of the localities so that they are contiguous from 0, which envoy
+// requires, and sorts the localities by priority
+func compactPriorities(groups []*envoy_config_endpoint_v3.LocalityLbEndpoints) {
+	var priorities []uint32
+	for _, g := range groups {
+		priorities = append(priorities, g.GetPriority())
+	}
+	slices.Sort(priorities)
+	priorities = slices.Compact(priorities)
+	for _, g := range groups {
+		idx, _ := slices.BinarySearch(priorities, g.GetPriority())
+		g.Priority = uint32(idx)
+	}
+	slices.SortStableFunc(groups, func(a, b *envoy_config_endpoint_v3.LocalityLbEndpoints) int {
+		return cmp.Compare(a.GetPriority(), b.GetPriority())
+	})
+}
+
+func edsClusterNames(clusters []*envoy_config_cluster_v3.Cluster) []string {
+	var names []string
+	for _, c := range clusters {
+		if c.GetType() == envoy_config_cluster_v3.Cluster_EDS {
+			names = append(names, c.GetName())
+		}
+	}
+	return names
+}
+
+func asResources[T proto.Message](msgs []T) []envoytypes.Resource {
+	ret := make([]envoytypes.Resource, 0, len(msgs))
+	for _, m := range msgs {
+		ret = append(ret, m)
+	}
+	return ret
+}
@@ -0,0 +1,275 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/ir"
+	"github.com/fleezesd/fgateway/pkg/xds"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLoadAssignment(t *testing.T) {
+	zoneA := ir.LocalityPod{Region: "us-east1", Zone: "us-east1-a"}
+	zoneB := ir.LocalityPod{Region: "us-east1", Zone: "us-east1-b"}
+	otherRegion := ir.LocalityPod{Region: "eu-west1", Zone: "eu-west1-a"}
+	endpoints := []ir.Endpoint{
+		{Address: "10.0.0.1", Port: 8080, Locality: otherRegion},
+		{Address: "10.0.0.2", Port: 8080, Locality: zoneB},
+		{Address: "10.0.0.3", Port: 8080, Locality: zoneA},
+		{Address: "10.0.0.4", Port: 8080, Locality: zoneB},
+		// the zone of an endpoint without a known pod comes from its EndpointSlice
+		{Address: "10.0.0.5", Port: 8080, Locality: ir.LocalityPod{Zone: "us-east1-a"}},
+	}
+	type group struct {
+		zone     string
+		priority uint32
+		weight   uint32
+	}
+	tests := []struct {
+		name      string
+		endpoints []ir.Endpoint
+		client    ir.LocalityPod
+		want      []group
+	}{
+		{
+			name:      "unknown client locality",
+			endpoints: endpoints,
+			want:      []group{{"eu-west1-a", 0, 1}, {"us-east1-b", 0, 2}, {"us-east1-a", 0, 2}},
+		},
+		{
+			name:      "zone then region then others",
+			endpoints: endpoints,
+			client:    zoneA,
+			want:      []group{{"us-east1-a", 0, 2}, {"us-east1-b", 1, 2}, {"eu-west1-a", 2, 1}},
+		},
+		{
+			name:      "region of a zone from the client",
+			endpoints: []ir.Endpoint{endpoints[1], {Address: "10.0.0.6", Port: 8080, Locality: ir.LocalityPod{Zone: "us-east1-c"}}},
+			client:    ir.LocalityPod{Region: "us-east1", Zone: "us-east1-c"},
+			want:      []group{{"us-east1-c", 0, 1}, {"us-east1-b", 1, 1}},
+		},
+		{
+			name:      "priorities are contiguous",
+			endpoints: []ir.Endpoint{endpoints[0], endpoints[1]},
+			client:    ir.LocalityPod{Region: "us-central1", Zone: "us-central1-a"},
+			want:      []group{{"eu-west1-a", 0, 1}, {"us-east1-b", 0, 1}},
+		},
+		{
+			name:      "failover to another region",
+			endpoints: []ir.Endpoint{endpoints[0], endpoints[2]},
+			client:    zoneB,
+			want:      []group{{"us-east1-a", 0, 1}, {"eu-west1-a", 1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []group
+			for _, g := range loadAssignment("cluster", tt.endpoints, tt.client).GetEndpoints() {
+				got = append(got, group{g.GetLocality().GetZone(), g.GetPriority(), g.GetLoadBalancingWeight().GetValue()})
+				if g.GetLocality().GetRegion() == "" {
+					t.Errorf("locality %v has no region, want the one of its zone", g.GetLocality())
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("localities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const churnNode = "proxy"
+
+// endpointChurn is a gateway with many EDS clusters, the endpoints of one of which change at every step
+type endpointChurn struct {
+	gw       ir.GatewayIR
+	backends []ir.BackendEndpoints
+	step     int
+}
+
+func newEndpointChurn(clusters, endpointsPerCluster int) *endpointChurn {
+	c := &endpointChurn{}
+	for i := range clusters {
+		name := fmt.Sprintf("kube_default_svc%d_80", i)
+		c.gw.Clusters = append(c.gw.Clusters, &envoy_config_cluster_v3.Cluster{
+			Name:                 name,
+			ClusterDiscoveryType: &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_EDS},
+		})
+		backend := ir.BackendEndpoints{ClusterName: name}
+		for j := range endpointsPerCluster {
+			backend.Endpoints = append(backend.Endpoints, ir.Endpoint{Address: fmt.Sprintf("10.%d.%d.%d", i/256, i%256, j), Port: 8080})
+		}
+		c.backends = append(c.backends, backend)
+	}
+	return c
+}
+
+// next rolls a pod of the next cluster and returns the snapshot of the gateway
+func (c *endpointChurn) next(tb testing.TB) *envoycache.Snapshot {
+	tb.Helper()
+	backend := &c.backends[c.step%len(c.backends)]
+	backend.Endpoints = append([]ir.Endpoint(nil), backend.Endpoints...)
+	backend.Endpoints[0].Port++
+	c.step++
+	snapshot, err := BuildSnapshot(c.gw, c.backends, ir.LocalityPod{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return snapshot
+}
+
+func (c *endpointChurn) clusterNames() []string {
+	return edsClusterNames(c.gw.Clusters)
+}
+
+// sotwEndpointWatch plays a proxy fetching its endpoints with state of the world xds
+type sotwEndpointWatch struct {
+	cache   envoycache.SnapshotCache
+	names   []string
+	version string
+}
+
+// push sets the snapshot and returns the endpoints response sent to the proxy
+func (w *sotwEndpointWatch) push(tb testing.TB, snapshot *envoycache.Snapshot) *envoy_service_discovery_v3.DiscoveryResponse {
+	tb.Helper()
+	responses := make(chan envoycache.Response, 1)
+	cancel := w.cache.CreateWatch(&envoycache.Request{
+		Node:          &envoy_config_core_v3.Node{Id: churnNode},
+		TypeUrl:       resource.EndpointType,
+		ResourceNames: w.names,
+		VersionInfo:   w.version,
+	}, stream.NewStreamState(false, nil), responses)
+	defer cancel()
+	if err := w.cache.SetSnapshot(context.Background(), churnNode, snapshot); err != nil {
+		tb.Fatal(err)
+	}
+	resp := receive(tb, responses)
+	out, err := resp.GetDiscoveryResponse()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	w.version = out.GetVersionInfo()
+	return out
+}
+
+// deltaEndpointWatch plays a proxy fetching its endpoints with delta xds
+type deltaEndpointWatch struct {
+	cache envoycache.SnapshotCache
+	state stream.StreamState
+}
+
+// push sets the snapshot and returns the endpoints response sent to the proxy
+func (w *deltaEndpointWatch) push(tb testing.TB, snapshot *envoycache.Snapshot) *envoy_service_discovery_v3.DeltaDiscoveryResponse {
+	tb.Helper()
+	responses := make(chan envoycache.DeltaResponse, 1)
+	cancel := w.cache.CreateDeltaWatch(&envoycache.DeltaRequest{
+		Node:    &envoy_config_core_v3.Node{Id: churnNode},
+		TypeUrl: resource.EndpointType,
+	}, w.state, responses)
+	defer cancel()
+	if err := w.cache.SetSnapshot(context.Background(), churnNode, snapshot); err != nil {
+		tb.Fatal(err)
+	}
+	resp := receive(tb, responses)
+	out, err := resp.GetDeltaDiscoveryResponse()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// the server acks the versions the proxy now has, which the next watch is diffed against
+	w.state.SetResourceVersions(resp.GetNextVersionMap())
+	return out
+}
+
+func receive[T any](tb testing.TB, responses chan T) T {
+	tb.Helper()
+	select {
+	case resp := <-responses:
+		return resp
+	case <-time.After(5 * time.Second):
+		tb.Fatal("timed out waiting for the xds response")
+		var zero T
+		return zero
+	}
+}
+
+// churnCaches are the caches serving the churn: the snapshot cache sends every requested resource of a type to state of
+// the world streams, while the client cache the control plane serves proxies from only sends the changed ones
+var churnCaches = []struct {
+	name string
+	new  func() envoycache.SnapshotCache
+	// sotwUpdated is the number of load assignments sent to a state of the world stream when one of them changes
+	sotwUpdated int
+}{
+	{name: "SnapshotCache", new: func() envoycache.SnapshotCache { return envoycache.NewSnapshotCache(false, envoycache.IDHash{}, nil) }, sotwUpdated: 10},
+	{name: "ClientCache", new: func() envoycache.SnapshotCache { return xds.NewClientCache(envoycache.IDHash{}, nil) }, sotwUpdated: 1},
+}
+
+func TestDeltaEndpointChurn(t *testing.T) {
+	for _, c := range churnCaches {
+		t.Run(c.name, func(t *testing.T) {
+			churn := newEndpointChurn(10, 3)
+			sotw := &sotwEndpointWatch{cache: c.new(), names: churn.clusterNames()}
+			delta := &deltaEndpointWatch{cache: c.new(), state: stream.NewStreamState(true, nil)}
+
+			initial := churn.next(t)
+			if got := len(sotw.push(t, initial).GetResources()); got != 10 {
+				t.Errorf("initial state of the world response has %d resources, want 10", got)
+			}
+			if got := len(delta.push(t, initial).GetResources()); got != 10 {
+				t.Errorf("initial delta response has %d resources, want 10", got)
+			}
+
+			updated := churn.next(t)
+			if got := len(sotw.push(t, updated).GetResources()); got != c.sotwUpdated {
+				t.Errorf("state of the world response has %d resources, want %d", got, c.sotwUpdated)
+			}
+			resp := delta.push(t, updated)
+			if len(resp.GetResources()) != 1 || resp.GetResources()[0].GetName() != churn.backends[1].ClusterName {
+				t.Errorf("delta response = %v, want only the load assignment of the updated cluster", resp.GetResources())
+			}
+			if len(resp.GetRemovedResources()) != 0 {
+				t.Errorf("delta response removes %v, want nothing removed", resp.GetRemovedResources())
+			}
+		})
+	}
+}
+
+// BenchmarkEndpointChurn compares the bytes sent to a proxy of a gateway with many backends while their pods roll
+// one at a time, when the proxy uses state of the world and delta xds, served from each cache
+func BenchmarkEndpointChurn(b *testing.B) {
+	const clusters, endpointsPerCluster = 1000, 10
+
+	for _, c := range churnCaches {
+		b.Run(c.name+"/StateOfTheWorld", func(b *testing.B) {
+			churn := newEndpointChurn(clusters, endpointsPerCluster)
+			w := &sotwEndpointWatch{cache: c.new(), names: churn.clusterNames()}
+			w.push(b, churn.next(b))
+			b.ResetTimer()
+			sent := 0
+			for range b.N {
+				sent += proto.Size(w.push(b, churn.next(b)))
+			}
+			b.ReportMetric(float64(sent)/float64(b.N), "sent-bytes/op")
+		})
+		b.Run(c.name+"/Delta", func(b *testing.B) {
+			churn := newEndpointChurn(clusters, endpointsPerCluster)
+			w := &deltaEndpointWatch{cache: c.new(), state: stream.NewStreamState(true, nil)}
+			w.push(b, churn.next(b))
+			b.ResetTimer()
+			sent := 0
+			for range b.N {
+				sent += proto.Size(w.push(b, churn.next(b)))
+			}
+			b.ReportMetric(float64(sent)/float64(b.N), "sent-bytes/op")
+		})
+	}
+}
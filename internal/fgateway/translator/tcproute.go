@@ -0,0 +1,147 @@
+package translator
+
+import (
+	"slices"
+
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_extensions_filters_listener_tls_inspector_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/tls_inspector/v3"
+	envoy_extensions_filters_network_tcp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// translateTCPListener builds the envoy listener forwarding the connections of a TCP listener to the backends of
+// the oldest TCPRoute attached to it. It returns nil when no route with a resolved backend is attached.
+func (t *gatewayTranslator) translateTCPListener(group *listenerGroup) (*envoy_config_listener_v3.Listener, error) {
+	name := listenerName(group.port)
+	for _, route := range t.tcpRoutes {
+		attached := t.attachedListeners(TCPRouteKind, route.Namespace, route.Spec.ParentRefs, nil)
+		if !attachedToGroup(attached, group) || len(route.Spec.Rules) == 0 {
+			continue
+		}
+		filter, err := t.tcpProxyFilter(name, TCPRouteKind, route.Namespace, route.Spec.Rules[0].BackendRefs)
+		if err != nil || filter == nil {
+			return nil, err
+		}
+		return &envoy_config_listener_v3.Listener{
+			Name:    name,
+			Address: listenerAddress(group.port),
+			FilterChains: []*envoy_config_listener_v3.FilterChain{{
+				Filters: []*envoy_config_listener_v3.Filter{filter},
+			}},
+		}, nil
+	}
+	return nil, nil
+}
+
+// translateTLSListener builds the envoy listener passing the TLS connections of the listeners through to the
+// backends of the TLSRoutes attached to them, with a filter chain per SNI hostname. When several routes serve
+// a hostname, the oldest one wins. It returns nil when no route with a resolved backend is attached.
+func (t *gatewayTranslator) translateTLSListener(group *listenerGroup) (*envoy_config_listener_v3.Listener, error) {
+	name := listenerName(group.port)
+	var chains []*envoy_config_listener_v3.FilterChain
+	served := map[string]bool{}
+	for _, route := range t.tlsRoutes {
+		if len(route.Spec.Rules) == 0 {
+			continue
+		}
+		attached := t.attachedListeners(TLSRouteKind, route.Namespace, route.Spec.ParentRefs, route.Spec.Hostnames)
+		var hosts []string
+		for _, idx := range group.listeners {
+			if attached.Has(idx) {
+				hosts = append(hosts, routeHostnames(t.gw.Spec.Listeners[idx].Hostname, route.Spec.Hostnames)...)
+			}
+		}
+		for _, host := range hosts {
+			if served[host] {
+				continue
+			}
+			served[host] = true
+			filter, err := t.tcpProxyFilter(name+"~"+host, TLSRouteKind, route.Namespace, route.Spec.Rules[0].BackendRefs)
+			if err != nil {
+				return nil, err
+			}
+			if filter == nil {
+				continue
+			}
+			chain := &envoy_config_listener_v3.FilterChain{
+				Name:    host,
+				Filters: []*envoy_config_listener_v3.Filter{filter},
+			}
+			// the chain without server names serves the connections matching no other chain
+			if host != anyHost {
+				chain.FilterChainMatch = &envoy_config_listener_v3.FilterChainMatch{ServerNames: []string{host}}
+			}
+			chains = append(chains, chain)
+		}
+	}
+	if len(chains) == 0 {
+		return nil, nil
+	}
+
+	tlsInspector, err := anypb.New(&envoy_extensions_filters_listener_tls_inspector_v3.TlsInspector{})
+	if err != nil {
+		return nil, err
+	}
+	return &envoy_config_listener_v3.Listener{
+		Name:    name,
+		Address: listenerAddress(group.port),
+		ListenerFilters: []*envoy_config_listener_v3.ListenerFilter{{
+			Name:       wellknown.TlsInspector,
+			ConfigType: &envoy_config_listener_v3.ListenerFilter_TypedConfig{TypedConfig: tlsInspector},
+		}},
+		FilterChains: chains,
+	}, nil
+}
+
+// tcpProxyFilter forwards connections to the backends of a rule, weighted by their backendRef weight. The share
+// of the backends that can not be resolved is closed. It returns nil when no backend is resolved.
+func (t *gatewayTranslator) tcpProxyFilter(statPrefix string, routeKind api.RouteGroupKind, routeNamespace string, refs []api.BackendRef) (*envoy_config_listener_v3.Filter, error) {
+	var weighted []*envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy_WeightedCluster_ClusterWeight
+	resolved := false
+	for _, ref := range refs {
+		weight := ptr.Deref(ref.Weight, 1)
+		if weight == 0 {
+			continue
+		}
+		cluster, err := t.backends.Resolve(ref.BackendObjectReference, routeKind, routeNamespace)
+		if err != nil {
+			cluster = invalidBackendCluster
+		} else {
+			resolved = true
+			t.addCluster(cluster)
+		}
+		weighted = append(weighted, &envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy_WeightedCluster_ClusterWeight{
+			Name:   cluster,
+			Weight: uint32(weight),
+		})
+	}
+	if !resolved {
+		return nil, nil
+	}
+
+	proxy := &envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy{StatPrefix: statPrefix}
+	if len(weighted) == 1 {
+		proxy.ClusterSpecifier = &envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy_Cluster{Cluster: weighted[0].GetName()}
+	} else {
+		proxy.ClusterSpecifier = &envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy_WeightedClusters{
+			WeightedClusters: &envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy_WeightedCluster{Clusters: weighted},
+		}
+	}
+	typed, err := anypb.New(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &envoy_config_listener_v3.Filter{
+		Name:       wellknown.TCPProxy,
+		ConfigType: &envoy_config_listener_v3.Filter_TypedConfig{TypedConfig: typed},
+	}, nil
+}
+
+// attachedToGroup reports whether any of the attached listener indexes belongs to the group
+func attachedToGroup(attached sets.Set[int], group *listenerGroup) bool {
+	return slices.ContainsFunc(group.listeners, attached.Has)
+}
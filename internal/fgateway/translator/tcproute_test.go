@@ -0,0 +1,131 @@
+package translator
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_extensions_filters_network_tcp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// l4BackendRef refers to the port of a Service of the default namespace, with a weight when it is not zero
+func l4BackendRef(name string, port api.PortNumber, weight int32) api.BackendRef {
+	ref := api.BackendRef{BackendObjectReference: api.BackendObjectReference{Name: api.ObjectName(name), Port: ptr.To(port)}}
+	if weight != 0 {
+		ref.Weight = ptr.To(weight)
+	}
+	return ref
+}
+
+// parentRef attaches a route to a listener of a Gateway of the default namespace
+func parentRef(gw, sectionName string) []api.ParentReference {
+	return []api.ParentReference{{Name: api.ObjectName(gw), SectionName: ptr.To(api.SectionName(sectionName))}}
+}
+
+func testTCPRoute(name string, created int64, listener string, refs ...api.BackendRef) *apiv1alpha2.TCPRoute {
+	return &apiv1alpha2.TCPRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, CreationTimestamp: metav1.NewTime(time.Unix(created, 0))},
+		Spec: apiv1alpha2.TCPRouteSpec{
+			CommonRouteSpec: api.CommonRouteSpec{ParentRefs: parentRef("gw", listener)},
+			Rules:           []apiv1alpha2.TCPRouteRule{{BackendRefs: refs}},
+		},
+	}
+}
+
+func testTLSRoute(name string, created int64, hostnames []apiv1alpha2.Hostname, refs ...api.BackendRef) *apiv1alpha2.TLSRoute {
+	return &apiv1alpha2.TLSRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, CreationTimestamp: metav1.NewTime(time.Unix(created, 0))},
+		Spec: apiv1alpha2.TLSRouteSpec{
+			CommonRouteSpec: api.CommonRouteSpec{ParentRefs: parentRef("gw", "tls")},
+			Hostnames:       hostnames,
+			Rules:           []apiv1alpha2.TLSRouteRule{{BackendRefs: refs}},
+		},
+	}
+}
+
+// tcpProxy returns the tcp_proxy filter of a filter chain
+func tcpProxy(t *testing.T, chain *envoy_config_listener_v3.FilterChain) *envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy {
+	t.Helper()
+	if len(chain.GetFilters()) != 1 {
+		t.Fatalf("filters = %v, want the tcp proxy", chain.GetFilters())
+	}
+	ret := &envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy{}
+	if err := chain.GetFilters()[0].GetTypedConfig().UnmarshalTo(ret); err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestGatewayCollectionTCPAndTLSRoutes(t *testing.T) {
+	gw := testGateway("gw",
+		api.Listener{Name: "db", Port: 5432, Protocol: api.TCPProtocolType},
+		api.Listener{
+			Name: "tls", Port: 8443, Protocol: api.TLSProtocolType, Hostname: ptr.To[api.Hostname]("*.example.com"),
+			TLS: &api.GatewayTLSConfig{Mode: ptr.To(api.TLSModePassthrough)},
+		},
+		api.Listener{Name: "unresolved", Port: 6000, Protocol: api.TCPProtocolType},
+	)
+	gateways := translateGateways(t,
+		gw,
+		testService("default", "db", 5432),
+		testService("default", "replica", 5432),
+		testService("default", "a", 443),
+		testService("default", "b", 443),
+		// the oldest TCPRoute of a listener wins
+		testTCPRoute("newer", 200, "db", l4BackendRef("replica", 5432, 0)),
+		testTCPRoute("db", 100, "db", l4BackendRef("db", 5432, 3), l4BackendRef("missing", 5432, 1)),
+		testTCPRoute("unresolved", 100, "unresolved", l4BackendRef("missing", 5432, 0)),
+		// the oldest TLSRoute of a hostname wins
+		testTLSRoute("a", 100, []apiv1alpha2.Hostname{"a.example.com"}, l4BackendRef("a", 443, 0)),
+		testTLSRoute("later", 200, []apiv1alpha2.Hostname{"a.example.com", "b.example.com"}, l4BackendRef("b", 443, 0)),
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+
+	// a listener without resolved backend is left out
+	if got, want := resourceNames(out.Listeners), []string{"listener~5432", "listener~8443"}; !slices.Equal(got, want) {
+		t.Fatalf("listeners = %v, want %v", got, want)
+	}
+	if got, want := resourceNames(out.Clusters), []string{"kube_default_a_443", "kube_default_b_443", "kube_default_db_5432"}; !slices.Equal(got, want) {
+		t.Errorf("clusters = %v, want %v", got, want)
+	}
+
+	tcp := out.Listeners[0]
+	if len(tcp.GetFilterChains()) != 1 {
+		t.Fatalf("filter chains of the TCP listener = %v, want one", tcp.GetFilterChains())
+	}
+	type clusterWeight struct {
+		name   string
+		weight uint32
+	}
+	var weighted []clusterWeight
+	for _, c := range tcpProxy(t, tcp.GetFilterChains()[0]).GetWeightedClusters().GetClusters() {
+		weighted = append(weighted, clusterWeight{c.GetName(), c.GetWeight()})
+	}
+	// the share of the missing backend is closed
+	if want := []clusterWeight{{"kube_default_db_5432", 3}, {invalidBackendCluster, 1}}; !slices.Equal(weighted, want) {
+		t.Errorf("weighted clusters = %v, want %v", weighted, want)
+	}
+
+	tls := out.Listeners[1]
+	if filters := tls.GetListenerFilters(); len(filters) != 1 || filters[0].GetName() != "envoy.filters.listener.tls_inspector" {
+		t.Errorf("listener filters = %v, want the TLS inspector", filters)
+	}
+	chains := map[string]string{}
+	for _, chain := range tls.GetFilterChains() {
+		if names := chain.GetFilterChainMatch().GetServerNames(); len(names) != 1 || names[0] != chain.GetName() {
+			t.Errorf("server names of chain %s = %v", chain.GetName(), names)
+		}
+		chains[chain.GetName()] = tcpProxy(t, chain).GetCluster()
+	}
+	want := map[string]string{"a.example.com": "kube_default_a_443", "b.example.com": "kube_default_b_443"}
+	if !maps.Equal(chains, want) {
+		t.Errorf("clusters of the server names = %v, want %v", chains, want)
+	}
+}
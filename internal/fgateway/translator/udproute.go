@@ -0,0 +1,96 @@
+package translator
+
+import (
+	xds_core_v3 "github.com/cncf/xds/go/xds/core/v3"
+	xds_type_matcher_v3 "github.com/cncf/xds/go/xds/type/matcher/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_extensions_filters_udp_udp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/udp/udp_proxy/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	udpProxyFilterName = "envoy.filters.udp_listener.udp_proxy"
+	// udpProxyRouteAction is the name of the matcher action routing datagrams to a cluster
+	udpProxyRouteAction = "route"
+)
+
+// translateUDPListener builds the envoy listener forwarding the datagrams of a UDP listener to the backend of the
+// oldest UDPRoute attached to it. The datagrams of a client are hashed by source IP, so that its session sticks
+// to one endpoint. As the udp_proxy filter can not split traffic, only the first backendRef with a weight is
+// used. It returns nil when no route with a resolved backend is attached.
+func (t *gatewayTranslator) translateUDPListener(group *listenerGroup) (*envoy_config_listener_v3.Listener, error) {
+	name := udpListenerName(group.port)
+	for _, route := range t.udpRoutes {
+		attached := t.attachedListeners(UDPRouteKind, route.Namespace, route.Spec.ParentRefs, nil)
+		if !attachedToGroup(attached, group) || len(route.Spec.Rules) == 0 {
+			continue
+		}
+		cluster := t.udpBackendCluster(route.Namespace, route.Spec.Rules[0].BackendRefs)
+		if cluster == "" {
+			return nil, nil
+		}
+		filter, err := udpProxyFilter(name, cluster)
+		if err != nil {
+			return nil, err
+		}
+		address := listenerAddress(group.port)
+		address.GetSocketAddress().Protocol = envoy_config_core_v3.SocketAddress_UDP
+		return &envoy_config_listener_v3.Listener{
+			Name:            name,
+			Address:         address,
+			ListenerFilters: []*envoy_config_listener_v3.ListenerFilter{filter},
+		}, nil
+	}
+	return nil, nil
+}
+
+// udpBackendCluster returns the cluster of the first backendRef with a weight, or an empty string when it does
+// not resolve to a UDP Service port
+func (t *gatewayTranslator) udpBackendCluster(routeNamespace string, refs []api.BackendRef) string {
+	for _, ref := range refs {
+		if ptr.Deref(ref.Weight, 1) == 0 {
+			continue
+		}
+		cluster, err := t.backends.ResolveProtocol(ref.BackendObjectReference, UDPRouteKind, routeNamespace, corev1.ProtocolUDP)
+		if err != nil {
+			return ""
+		}
+		t.addUDPCluster(cluster)
+		return cluster
+	}
+	return ""
+}
+
+// udpProxyFilter routes every datagram to the cluster, hashing them by source IP
+func udpProxyFilter(statPrefix, cluster string) (*envoy_config_listener_v3.ListenerFilter, error) {
+	route, err := anypb.New(&envoy_extensions_filters_udp_udp_proxy_v3.Route{Cluster: cluster})
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := anypb.New(&envoy_extensions_filters_udp_udp_proxy_v3.UdpProxyConfig{
+		StatPrefix: statPrefix,
+		RouteSpecifier: &envoy_extensions_filters_udp_udp_proxy_v3.UdpProxyConfig_Matcher{
+			Matcher: &xds_type_matcher_v3.Matcher{
+				OnNoMatch: &xds_type_matcher_v3.Matcher_OnMatch{
+					OnMatch: &xds_type_matcher_v3.Matcher_OnMatch_Action{
+						Action: &xds_core_v3.TypedExtensionConfig{Name: udpProxyRouteAction, TypedConfig: route},
+					},
+				},
+			},
+		},
+		HashPolicies: []*envoy_extensions_filters_udp_udp_proxy_v3.UdpProxyConfig_HashPolicy{{
+			PolicySpecifier: &envoy_extensions_filters_udp_udp_proxy_v3.UdpProxyConfig_HashPolicy_SourceIp{SourceIp: true},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &envoy_config_listener_v3.ListenerFilter{
+		Name:       udpProxyFilterName,
+		ConfigType: &envoy_config_listener_v3.ListenerFilter_TypedConfig{TypedConfig: proxy},
+	}, nil
+}
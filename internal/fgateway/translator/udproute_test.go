@@ -0,0 +1,105 @@
+package translator
+
+import (
+	"slices"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_filters_udp_udp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/udp/udp_proxy/v3"
+	"github.com/fleezesd/fgateway/internal/fgateway/xds"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	api "sigs.k8s.io/gateway-api/apis/v1"
+	apiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+func testUDPRoute(name, listener string, refs ...api.BackendRef) *apiv1alpha2.UDPRoute {
+	return &apiv1alpha2.UDPRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: apiv1alpha2.UDPRouteSpec{
+			CommonRouteSpec: api.CommonRouteSpec{ParentRefs: parentRef("gw", listener)},
+			Rules:           []apiv1alpha2.UDPRouteRule{{BackendRefs: refs}},
+		},
+	}
+}
+
+func TestGatewayCollectionUDPRoutes(t *testing.T) {
+	udpService := func(name string, port int32) *corev1.Service {
+		svc := testService("default", name, port)
+		svc.Spec.Ports[0].Protocol = corev1.ProtocolUDP
+		return svc
+	}
+	noWeight := l4BackendRef("dns-backup", 5353, 0)
+	noWeight.Weight = ptr.To[int32](0)
+	gw := testGateway("gw",
+		api.Listener{Name: "dns", Port: 5353, Protocol: api.UDPProtocolType},
+		api.Listener{Name: "syslog", Port: 5514, Protocol: api.UDPProtocolType},
+	)
+	gateways := translateGateways(t,
+		gw,
+		udpService("dns", 5353),
+		udpService("dns-backup", 5353),
+		// the port of the Service is TCP
+		testService("default", "syslog", 5514),
+		// the udp_proxy filter can not split traffic, the first backend with a weight wins
+		testUDPRoute("dns", "dns", noWeight, l4BackendRef("dns", 5353, 1), l4BackendRef("dns-backup", 5353, 1)),
+		testUDPRoute("syslog", "syslog", l4BackendRef("syslog", 5514, 0)),
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+
+	if got, want := resourceNames(out.Listeners), []string{"listener~5353~udp"}; !slices.Equal(got, want) {
+		t.Fatalf("listeners = %v, want %v", got, want)
+	}
+	if got, want := resourceNames(out.Clusters), []string{"kube_default_dns_5353_udp"}; !slices.Equal(got, want) {
+		t.Errorf("clusters = %v, want %v", got, want)
+	}
+
+	listener := out.Listeners[0]
+	if got := listener.GetAddress().GetSocketAddress().GetProtocol(); got != envoy_config_core_v3.SocketAddress_UDP {
+		t.Errorf("listener protocol = %s, want UDP", got)
+	}
+	if len(listener.GetListenerFilters()) != 1 {
+		t.Fatalf("listener filters = %v, want the udp proxy", listener.GetListenerFilters())
+	}
+	proxy := &envoy_extensions_filters_udp_udp_proxy_v3.UdpProxyConfig{}
+	if err := listener.GetListenerFilters()[0].GetTypedConfig().UnmarshalTo(proxy); err != nil {
+		t.Fatal(err)
+	}
+	route := &envoy_extensions_filters_udp_udp_proxy_v3.Route{}
+	if err := proxy.GetMatcher().GetOnNoMatch().GetAction().GetTypedConfig().UnmarshalTo(route); err != nil {
+		t.Fatal(err)
+	}
+	if route.GetCluster() != "kube_default_dns_5353_udp" {
+		t.Errorf("cluster = %q, want the one of the first backend with a weight", route.GetCluster())
+	}
+	if policies := proxy.GetHashPolicies(); len(policies) != 1 || !policies[0].GetSourceIp() {
+		t.Errorf("hash policies = %v, want the source IP", policies)
+	}
+}
+
+func TestGatewayCollectionTCPAndUDPSharingAPort(t *testing.T) {
+	udpService := testService("default", "dns-udp", 53)
+	udpService.Spec.Ports[0].Protocol = corev1.ProtocolUDP
+	gateways := translateGateways(t,
+		testGateway("gw",
+			api.Listener{Name: "dns-tcp", Port: 53, Protocol: api.TCPProtocolType},
+			api.Listener{Name: "dns-udp", Port: 53, Protocol: api.UDPProtocolType},
+		),
+		testService("default", "dns-tcp", 53),
+		udpService,
+		testTCPRoute("dns-tcp", 0, "dns-tcp", l4BackendRef("dns-tcp", 53, 1)),
+		testUDPRoute("dns-udp", "dns-udp", l4BackendRef("dns-udp", 53, 1)),
+	)
+	out := gateways[xds.GatewayProxyRole("default", "gw")]
+
+	if got, want := resourceNames(out.Listeners), []string{"listener~53", "listener~53~udp"}; !slices.Equal(got, want) {
+		t.Fatalf("listeners = %v, want %v", got, want)
+	}
+	for i, want := range []envoy_config_core_v3.SocketAddress_Protocol{envoy_config_core_v3.SocketAddress_TCP, envoy_config_core_v3.SocketAddress_UDP} {
+		address := out.Listeners[i].GetAddress().GetSocketAddress()
+		if address.GetProtocol() != want || address.GetPortValue() != 8053 {
+			t.Errorf("listener %s address = %v, want port 8053 over %s", out.Listeners[i].GetName(), address, want)
+		}
+	}
+}
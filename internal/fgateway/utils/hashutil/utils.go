@@ -1,6 +1,10 @@
 package hashutil
 
-import "hash/fnv"
+import (
+	"hash/fnv"
+
+	"google.golang.org/protobuf/proto"
+)
 
 func HashLables(labels map[string]string) uint64 {
 	finalHash := uint64(0)
@@ -16,3 +20,18 @@ func HashLables(labels map[string]string) uint64 {
 	// make final hash
 	return finalHash
 }
+
+// HashProtos hashes the deterministic encoding of the messages, in order
+func HashProtos[T proto.Message](msgs []T) (uint64, error) {
+	hash := fnv.New64()
+	opts := proto.MarshalOptions{Deterministic: true}
+	for _, msg := range msgs {
+		b, err := opts.Marshal(msg)
+		if err != nil {
+			return 0, err
+		}
+		hash.Write(b)
+		hash.Write([]byte{0})
+	}
+	return hash.Sum64(), nil
+}
@@ -21,3 +21,11 @@ func (k *KrtOptions) ApplyTo(name string) []krt.CollectionOption {
 		krt.WithStop(k.Stop),
 	}
 }
+
+// ApplyToUndebugged names a collection that is not dumped by the debugger, as its objects may hold credentials
+func (k *KrtOptions) ApplyToUndebugged(name string) []krt.CollectionOption {
+	return []krt.CollectionOption{
+		krt.WithName(name),
+		krt.WithStop(k.Stop),
+	}
+}
@@ -0,0 +1,233 @@
+package logutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	istiolog "istio.io/istio/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The scopes of the components whose log level can be changed at runtime
+const (
+	// DefaultScope is the scope of the loggers of contextutils, used by everything else
+	DefaultScope = "default"
+	// ControllerRuntimeScope is the scope of the controllers and the manager
+	ControllerRuntimeScope = "controller-runtime"
+	// KrtScope is the scope of the istio krt collections, which log through the istio log package
+	KrtScope = "krt"
+	// XdsScope is the scope of the xds gRPC server and its snapshot cache
+	XdsScope = "xds"
+	// DeployerScope is the scope of the deployer of the proxies
+	DeployerScope = "deployer"
+)
+
+// Registry holds the log level of every scope. Its loggers share a single core, which every scope filters by its
+// own level, so that the level of a scope can be changed without affecting the others.
+type Registry struct {
+	base   *zap.Logger
+	lock   sync.RWMutex
+	scopes map[string]*scope
+}
+
+type scope struct {
+	level zap.AtomicLevel
+	// onLevel propagates the level to the loggers the registry does not build
+	onLevel func(zapcore.Level)
+}
+
+// NewRegistry returns a registry of every scope at the given level. The core of base must be enabled at every
+// level, the scopes filter the entries.
+func NewRegistry(base *zap.Logger, level zapcore.Level) *Registry {
+	r := &Registry{base: base, scopes: map[string]*scope{}}
+	for _, name := range []string{DefaultScope, ControllerRuntimeScope, XdsScope, DeployerScope} {
+		r.scopes[name] = &scope{level: zap.NewAtomicLevelAt(level)}
+	}
+	r.scopes[KrtScope] = &scope{level: zap.NewAtomicLevelAt(level), onLevel: func(l zapcore.Level) {
+		if s := istiolog.FindScope(KrtScope); s != nil {
+			s.SetOutputLevel(istioLevel(l))
+		}
+	}}
+	r.scopes[KrtScope].onLevel(level)
+	return r
+}
+
+// Logger returns the logger of a scope, or of the default scope when the scope is unknown
+func (r *Registry) Logger(name string) *zap.Logger {
+	s, ok := r.scopes[name]
+	if !ok {
+		name, s = DefaultScope, r.scopes[DefaultScope]
+	}
+	return r.base.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &scopeCore{Core: c, level: s.level}
+	})).Named(name)
+}
+
+// Logr returns the logger of a scope as a logr.Logger
+func (r *Registry) Logr(name string) logr.Logger {
+	return zapr.NewLogger(r.Logger(name))
+}
+
+// FromContext returns the logger of ctx, with its name and values, filtered by the level of a scope instead of
+// its own. It returns the logger of ctx as is when the registry is nil, and the logger of the scope when the
+// logger of ctx is not a zap logger.
+func (r *Registry) FromContext(ctx context.Context, name string) logr.Logger {
+	logger := log.FromContext(ctx)
+	if r == nil {
+		return logger
+	}
+	s, ok := r.scopes[name]
+	if !ok {
+		return logger
+	}
+	u, ok := logger.GetSink().(zapr.Underlier)
+	if !ok {
+		return r.Logr(name)
+	}
+	return zapr.NewLogger(u.GetUnderlying().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		if sc, ok := c.(*scopeCore); ok {
+			c = sc.Core
+		}
+		return &scopeCore{Core: c, level: s.level}
+	})))
+}
+
+// SetLevel changes the level of a scope
+func (r *Registry) SetLevel(name string, level zapcore.Level) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s, ok := r.scopes[name]
+	if !ok {
+		return errors.Errorf("unknown log scope %q, available scopes are %s", name, strings.Join(r.names(), ", "))
+	}
+	s.level.SetLevel(level)
+	if s.onLevel != nil {
+		s.onLevel(level)
+	}
+	return nil
+}
+
+// SetLevels changes the level of scopes from a comma separated list of scope=level pairs, such as
+// "xds=debug,deployer=warn". The levels are only changed when the whole list is valid.
+func (r *Registry) SetLevels(spec string) error {
+	levels := map[string]zapcore.Level{}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return errors.Errorf("invalid log level %q, want scope=level", pair)
+		}
+		level, err := zapcore.ParseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return errors.Wrapf(err, "invalid log level of scope %s", name)
+		}
+		levels[strings.TrimSpace(name)] = level
+	}
+	for name := range levels {
+		if _, ok := r.scopes[name]; !ok {
+			return errors.Errorf("unknown log scope %q, available scopes are %s", name, strings.Join(r.names(), ", "))
+		}
+	}
+	for name, level := range levels {
+		if err := r.SetLevel(name, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Levels returns the level of every scope
+func (r *Registry) Levels() map[string]string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	levels := make(map[string]string, len(r.scopes))
+	for name, s := range r.scopes {
+		levels[name] = s.level.Level().String()
+	}
+	return levels
+}
+
+// ServeHTTP returns the level of every scope on GET, and changes the levels of the scopes of a PUT of a JSON object
+// of levels by scope, such as {"xds": "debug"}
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var levels map[string]string
+		if err := json.NewDecoder(req.Body).Decode(&levels); err != nil {
+			http.Error(w, fmt.Sprintf("invalid levels: %v", err), http.StatusBadRequest)
+			return
+		}
+		pairs := make([]string, 0, len(levels))
+		for name, level := range levels {
+			pairs = append(pairs, name+"="+level)
+		}
+		if err := r.SetLevels(strings.Join(pairs, ",")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Levels())
+}
+
+// names returns the sorted names of the scopes
+func (r *Registry) names() []string {
+	return slices.Sorted(maps.Keys(r.scopes))
+}
+
+// scopeCore filters the entries of a core by the level of a scope
+type scopeCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c *scopeCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level)
+}
+
+func (c *scopeCore) Level() zapcore.Level {
+	return c.level.Level()
+}
+
+func (c *scopeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &scopeCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *scopeCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return ce
+	}
+	return c.Core.Check(entry, ce)
+}
+
+// istioLevel returns the istio log level closest to a zap level
+func istioLevel(level zapcore.Level) istiolog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return istiolog.DebugLevel
+	case level == zapcore.InfoLevel:
+		return istiolog.InfoLevel
+	case level == zapcore.WarnLevel:
+		return istiolog.WarnLevel
+	default:
+		return istiolog.ErrorLevel
+	}
+}
@@ -0,0 +1,94 @@
+package logutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// newTestRegistry returns a registry at the info level, and the entries logged by its scopes
+func newTestRegistry() (*Registry, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.LevelEnablerFunc(func(zapcore.Level) bool { return true }))
+	return NewRegistry(zap.New(core), zapcore.InfoLevel), logs
+}
+
+func TestSetLevels(t *testing.T) {
+	registry, logs := newTestRegistry()
+	if err := registry.SetLevels("xds=debug, deployer=error"); err != nil {
+		t.Fatal(err)
+	}
+	registry.Logger(XdsScope).Debug("xds")
+	registry.Logger(DeployerScope).Warn("deployer")
+	registry.Logger(DefaultScope).Debug("default")
+	if got := logs.TakeAll(); len(got) != 1 || got[0].Message != "xds" || got[0].LoggerName != XdsScope {
+		t.Errorf("entries = %v, want the debug entry of the xds scope only", got)
+	}
+
+	for _, spec := range []string{"xds", "xds=verbose", "unknown=debug,xds=info"} {
+		if err := registry.SetLevels(spec); err == nil {
+			t.Errorf("SetLevels(%q) succeeded, want an error", spec)
+		}
+	}
+	if got := registry.Levels()[XdsScope]; got != "debug" {
+		t.Errorf("xds level = %s after invalid levels, want it unchanged", got)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	registry, logs := newTestRegistry()
+	if err := registry.SetLevels("deployer=debug"); err != nil {
+		t.Fatal(err)
+	}
+	// the logger of a reconciler, at the level of the controller-runtime scope
+	ctx := log.IntoContext(context.Background(), registry.Logr(ControllerRuntimeScope).WithValues("gateway", "default/gw"))
+
+	log.FromContext(ctx).V(1).Info("controller-runtime")
+	registry.FromContext(ctx, DeployerScope).V(1).Info("deployer")
+	got := logs.TakeAll()
+	if len(got) != 1 || got[0].Message != "deployer" {
+		t.Fatalf("entries = %v, want the debug entry of the deployer scope only", got)
+	}
+	if got[0].ContextMap()["gateway"] != "default/gw" {
+		t.Errorf("fields = %v, want the values of the logger of the context", got[0].ContextMap())
+	}
+
+	// a logger that is not a zap logger is replaced by the one of the scope
+	ctx = log.IntoContext(context.Background(), logr.Discard())
+	registry.FromContext(ctx, DeployerScope).V(1).Info("deployer")
+	if got := logs.TakeAll(); len(got) != 1 || got[0].LoggerName != DeployerScope {
+		t.Errorf("entries = %v, want the entry logged by the deployer scope", got)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry, _ := newTestRegistry()
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		registry.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/logging", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := put(`{"krt": "debug"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", rec.Code, rec.Body)
+	}
+	var levels map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &levels); err != nil {
+		t.Fatal(err)
+	}
+	if levels[KrtScope] != "debug" || levels[XdsScope] != "info" {
+		t.Errorf("levels = %v, want krt at debug and the other scopes unchanged", levels)
+	}
+	if rec := put(`{"unknown": "debug"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT of an unknown scope = %d, want 400", rec.Code)
+	}
+}
@@ -17,4 +17,9 @@ const (
 	// DefaultGatewayParametersName is the name of the GatewayParameters which is attached by
 	// parametersRef to the GatewayClass.
 	DefaultGatewayParametersName = "fgateway"
+
+	// GatewayOwnerLabel is the label set on every object the deployer provisions for a Gateway.
+	// Its value is the UID of the owning Gateway, and it is used to find the objects that
+	// were previously deployed for the Gateway so that stale ones can be pruned.
+	GatewayOwnerLabel = "gateway.fgateway.dev/owner-uid"
 )
@@ -4,4 +4,10 @@ const (
 	// GatewayApiProxyValue is the label value for ProxyTypeKey applied to Proxy CRs
 	// that have been generated from Kubernetes Gateway API resources
 	GatewayApiProxyValue = "fleezesd-kube-gateway-api"
+
+	// ProxySelectorAnnotation is the route annotation restricting the route to the proxies of its Gateways whose
+	// pod labels match its value, a label selector such as "track=canary". Routes without it apply to every proxy.
+	// Only routes are scoped: policies, such as BackendTLSPolicies, apply to the clusters of every proxy of a
+	// Gateway, and the annotation is ignored on them.
+	ProxySelectorAnnotation = "gateway.fgateway.dev/proxy-selector"
 )
@@ -1,6 +1,7 @@
 package xds
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/fleezesd/fgateway/internal/fgateway/wellknown"
@@ -14,3 +15,18 @@ const (
 func IsKubeGatewayCacheKey(key string) bool {
 	return strings.HasPrefix(key, wellknown.GatewayApiProxyValue)
 }
+
+// GatewayProxyRole returns the role a proxy deployed for the given gateway puts in its node.metadata,
+// in the format `<GatewayApiProxyValue>~<namespace>~<name>`
+func GatewayProxyRole(namespace, name string) string {
+	return fmt.Sprintf("%s~%s~%s", wellknown.GatewayApiProxyValue, namespace, name)
+}
+
+// ParseGatewayProxyRole returns the namespace and name of the gateway of a role built by GatewayProxyRole
+func ParseGatewayProxyRole(role string) (namespace, name string, ok bool) {
+	parts := strings.Split(role, "~")
+	if len(parts) != 3 || parts[0] != wellknown.GatewayApiProxyValue || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
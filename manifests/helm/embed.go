@@ -4,5 +4,5 @@ import (
 	"embed"
 )
 
-//gp:embed all:fgateway
+//go:embed all:fgateway
 var FGatewayHelmChart embed.FS
@@ -1,62 +1,69 @@
 {{/*
 Expand the name of the chart.
 */}}
-{{- define "fgateway.name" -}}
-{{- default .Chart.Name .Values.nameOverride | trunc 63 | trimSuffix "-" }}
+{{- define "fgateway.gateway.name" -}}
+{{- default .Chart.Name .Values.gateway.nameOverride | trunc 63 | trimSuffix "-" }}
 {{- end }}
 
 {{/*
-Create a default fully qualified app name.
-We truncate at 63 chars because some Kubernetes name fields are limited to this (by the DNS naming spec).
-If release name contains chart name it will be used as a full name.
+Create a default fully qualified name for the proxy resources.
+The release name is the name of the Gateway.
 */}}
-{{- define "fgateway.fullname" -}}
-{{- if .Values.fullnameOverride }}
-{{- .Values.fullnameOverride | trunc 63 | trimSuffix "-" }}
+{{- define "fgateway.gateway.fullname" -}}
+{{- if .Values.gateway.fullnameOverride }}
+{{- .Values.gateway.fullnameOverride | trunc 63 | trimSuffix "-" }}
 {{- else }}
-{{- $name := default .Chart.Name .Values.nameOverride }}
-{{- if contains $name .Release.Name }}
 {{- .Release.Name | trunc 63 | trimSuffix "-" }}
-{{- else }}
-{{- printf "%s-%s" .Release.Name $name | trunc 63 | trimSuffix "-" }}
-{{- end }}
 {{- end }}
 {{- end }}
 
 {{/*
-Create chart name and version as used by the chart label.
+The name of the ServiceAccount the proxy runs as: the one of the chart unless serviceAccount.create is false,
+then serviceAccount.name or the default ServiceAccount of the namespace.
 */}}
-{{- define "fgateway.chart" -}}
-{{- printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
-{{- end }}
-
-{{/*
-Common labels
-*/}}
-{{- define "fgateway.labels" -}}
-helm.sh/chart: {{ include "fgateway.chart" . }}
-{{ include "fgateway.selectorLabels" . }}
-{{- if .Chart.AppVersion }}
-app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+{{- define "fgateway.gateway.serviceAccountName" -}}
+{{- $serviceAccount := .Values.gateway.serviceAccount | default dict }}
+{{- if or (not (hasKey $serviceAccount "create")) $serviceAccount.create }}
+{{- include "fgateway.gateway.fullname" . }}
+{{- else }}
+{{- $serviceAccount.name | default "default" }}
 {{- end }}
-app.kubernetes.io/managed-by: {{ .Release.Service }}
 {{- end }}
 
 {{/*
 Selector labels
 */}}
-{{- define "fgateway.selectorLabels" -}}
-app.kubernetes.io/name: {{ include "fgateway.name" . }}
+{{- define "fgateway.gateway.selectorLabels" -}}
+app.kubernetes.io/name: {{ include "fgateway.gateway.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
+gateway.networking.k8s.io/gateway-name: {{ .Release.Name }}
 {{- end }}
 
 {{/*
-Create the name of the service account to use
+Common labels
 */}}
-{{- define "fgateway.serviceAccountName" -}}
-{{- if .Values.serviceAccount.create }}
-{{- default (include "fgateway.fullname" .) .Values.serviceAccount.name }}
-{{- else }}
-{{- default "default" .Values.serviceAccount.name }}
+{{- define "fgateway.gateway.labels" -}}
+{{ include "fgateway.gateway.selectorLabels" . }}
+fgateway: fgateway-proxy
+{{- if .Chart.AppVersion }}
+app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
 {{- end }}
 {{- end }}
+
+{{/*
+Render a container image reference from an image value:
+[registry/]repository[:tag][@digest]
+*/}}
+{{- define "fgateway.gateway.image" -}}
+{{- $image := .repository -}}
+{{- if .registry -}}
+{{- $image = printf "%s/%s" .registry $image -}}
+{{- end -}}
+{{- if .tag -}}
+{{- $image = printf "%s:%s" $image .tag -}}
+{{- end -}}
+{{- if .digest -}}
+{{- $image = printf "%s@%s" $image .digest -}}
+{{- end -}}
+{{ $image }}
+{{- end -}}
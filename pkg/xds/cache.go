@@ -0,0 +1,318 @@
+package xds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoytypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/log"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ envoycache.SnapshotCache = new(ClientCache)
+
+// ClientCache serves every client from a mux of linear caches, one per type url, which version each resource on its
+// own: a snapshot set for a client only updates the resources that changed since its previous snapshot, so that
+// state of the world streams only get the named resources that changed, and delta streams only get the changed
+// resources of any type. The snapshots are kept as they were set, for GetSnapshot.
+type ClientCache struct {
+	hash envoycache.NodeHash
+	log  log.Logger
+	// instance makes the versions of the cache unique across restarts of the control plane, as a proxy reconnecting
+	// with the version of a previous instance must not be considered up to date
+	instance string
+
+	mu          sync.Mutex
+	clients     map[string]*clientCache
+	generations uint64
+}
+
+// NewClientCache returns a cache whose clients are the nodes of the same key
+func NewClientCache(hash envoycache.NodeHash, logger log.Logger) *ClientCache {
+	return &ClientCache{
+		hash:     hash,
+		log:      logger,
+		instance: strconv.FormatInt(time.Now().UnixNano(), 36),
+		clients:  map[string]*clientCache{},
+	}
+}
+
+// clientCache holds the linear caches of a client. The watches opened before its first snapshot are held until it is
+// set, as the linear caches would otherwise answer them with no resources at all.
+type clientCache struct {
+	prefix string
+	caches map[string]*envoycache.LinearCache
+	mux    envoycache.MuxCache
+
+	mu       sync.Mutex
+	snapshot envoycache.ResourceSnapshot
+	pending  []*pendingWatch
+	status   clientStatus
+	// versions are the versions of the linear caches, by type url, which are the versions of the responses of the
+	// state of the world streams, and names the names of the resources at that version
+	versions map[string]uint64
+	names    map[string][]string
+}
+
+// pendingWatch is a watch waiting for the first snapshot of its client
+type pendingWatch struct {
+	start    func() func()
+	cancel   func()
+	canceled bool
+}
+
+func (c *ClientCache) client(key string) *clientCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[key]; ok {
+		return client
+	}
+	c.generations++
+	prefix := fmt.Sprintf("%s-%d-", c.instance, c.generations)
+	client := &clientCache{
+		prefix:   prefix,
+		caches:   map[string]*envoycache.LinearCache{},
+		versions: map[string]uint64{},
+		names:    map[string][]string{},
+	}
+	for typ := envoytypes.ResponseType(0); typ < envoytypes.UnknownType; typ++ {
+		typeURL, err := envoycache.GetResponseTypeURL(typ)
+		if err != nil {
+			continue
+		}
+		client.caches[typeURL] = envoycache.NewLinearCache(typeURL,
+			envoycache.WithVersionPrefix(prefix), envoycache.WithLogger(c.log))
+	}
+	client.mux = envoycache.MuxCache{
+		Classify:      func(r *envoycache.Request) string { return r.GetTypeUrl() },
+		ClassifyDelta: func(r *envoycache.DeltaRequest) string { return r.GetTypeUrl() },
+		Caches:        make(map[string]envoycache.Cache, len(client.caches)),
+	}
+	for typeURL, cache := range client.caches {
+		client.mux.Caches[typeURL] = cache
+	}
+	c.clients[key] = client
+	return client
+}
+
+// SetSnapshot updates the resources of the client which changed in the snapshot, in the order of the types in which
+// proxies expect them, and starts the watches waiting for its first snapshot.
+func (c *ClientCache) SetSnapshot(_ context.Context, node string, snapshot envoycache.ResourceSnapshot) error {
+	client := c.client(node)
+	client.mu.Lock()
+	for typ := envoytypes.ResponseType(0); typ < envoytypes.UnknownType; typ++ {
+		typeURL, err := envoycache.GetResponseTypeURL(typ)
+		if err != nil {
+			continue
+		}
+		cache := client.caches[typeURL]
+		toUpdate, toDelete := diffResources(cache.GetResources(), snapshot.GetResources(typeURL))
+		if len(toUpdate) == 0 && len(toDelete) == 0 {
+			continue
+		}
+		if err := cache.UpdateResources(toUpdate, toDelete); err != nil {
+			client.mu.Unlock()
+			return err
+		}
+		// every update bumps the version of the linear cache
+		client.versions[typeURL]++
+		client.names[typeURL] = slices.Sorted(maps.Keys(snapshot.GetResources(typeURL)))
+	}
+	client.snapshot = snapshot
+	pending := client.pending
+	client.pending = nil
+	client.mu.Unlock()
+
+	for _, w := range pending {
+		client.startPending(w)
+	}
+	return nil
+}
+
+// diffResources returns the resources of next which are not in prev or changed, and the names of the ones of prev
+// which are no longer in next
+func diffResources(prev, next map[string]envoytypes.Resource) (map[string]envoytypes.Resource, []string) {
+	toUpdate := map[string]envoytypes.Resource{}
+	for name, r := range next {
+		if old, ok := prev[name]; !ok || !proto.Equal(old, r) {
+			toUpdate[name] = r
+		}
+	}
+	var toDelete []string
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			toDelete = append(toDelete, name)
+		}
+	}
+	return toUpdate, toDelete
+}
+
+// ResourceNames returns the names of the resources of typeURL the client of node had at version, the version of the
+// responses of its state of the world streams, whose resources are only sent marshaled. It returns nil when the
+// resources changed since.
+func (c *ClientCache) ResourceNames(node, typeURL, version string) []string {
+	c.mu.Lock()
+	client, ok := c.clients[node]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if version != client.prefix+strconv.FormatUint(client.versions[typeURL], 10) {
+		return nil
+	}
+	return slices.Clone(client.names[typeURL])
+}
+
+func (c *ClientCache) GetSnapshot(node string) (envoycache.ResourceSnapshot, error) {
+	c.mu.Lock()
+	client, ok := c.clients[node]
+	c.mu.Unlock()
+	if ok {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		if client.snapshot != nil {
+			return client.snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot found for node %s", node)
+}
+
+// ClearSnapshot drops the caches of the client. A client connecting again gets new caches, whose versions differ
+// from the ones it had.
+func (c *ClientCache) ClearSnapshot(node string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients, node)
+}
+
+func (c *ClientCache) GetStatusInfo(node string) envoycache.StatusInfo {
+	c.mu.Lock()
+	client, ok := c.clients[node]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	status := client.status
+	return &status
+}
+
+func (c *ClientCache) GetStatusKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.clients))
+	for key := range c.clients {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *ClientCache) CreateWatch(request *envoycache.Request, state stream.StreamState, value chan envoycache.Response) func() {
+	client := c.client(c.hash.ID(request.GetNode()))
+	client.mu.Lock()
+	client.status.node = request.GetNode()
+	client.status.lastWatchRequestTime = time.Now()
+	client.status.numWatches++
+	client.mu.Unlock()
+	cancel := client.watch(func() func() { return client.mux.CreateWatch(request, state, value) })
+	return func() {
+		client.mu.Lock()
+		client.status.numWatches--
+		client.mu.Unlock()
+		cancel()
+	}
+}
+
+func (c *ClientCache) CreateDeltaWatch(request *envoycache.DeltaRequest, state stream.StreamState, value chan envoycache.DeltaResponse) func() {
+	client := c.client(c.hash.ID(request.GetNode()))
+	client.mu.Lock()
+	client.status.node = request.GetNode()
+	client.status.lastDeltaWatchRequestTime = time.Now()
+	client.status.numDeltaWatches++
+	client.mu.Unlock()
+	cancel := client.watch(func() func() { return client.mux.CreateDeltaWatch(request, state, value) })
+	return func() {
+		client.mu.Lock()
+		client.status.numDeltaWatches--
+		client.mu.Unlock()
+		cancel()
+	}
+}
+
+func (c *ClientCache) Fetch(context.Context, *envoycache.Request) (envoycache.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+// watch starts a watch with the linear caches of the client, or holds it until the first snapshot of the client
+func (c *clientCache) watch(start func() func()) func() {
+	c.mu.Lock()
+	if c.snapshot != nil {
+		c.mu.Unlock()
+		return orNop(start())
+	}
+	w := &pendingWatch{start: start}
+	c.pending = append(c.pending, w)
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		w.canceled = true
+		cancel := w.cancel
+		c.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+func (c *clientCache) startPending(w *pendingWatch) {
+	c.mu.Lock()
+	canceled := w.canceled
+	c.mu.Unlock()
+	if canceled {
+		return
+	}
+	cancel := orNop(w.start())
+	c.mu.Lock()
+	w.cancel = cancel
+	// the watch may have been canceled while it started
+	canceled = w.canceled
+	c.mu.Unlock()
+	if canceled {
+		cancel()
+	}
+}
+
+// orNop returns the cancel func of a watch, which is nil when the watch was answered right away
+func orNop(cancel func()) func() {
+	if cancel == nil {
+		return func() {}
+	}
+	return cancel
+}
+
+// clientStatus is the StatusInfo of a client
+type clientStatus struct {
+	node                      *envoy_config_core_v3.Node
+	numWatches                int
+	numDeltaWatches           int
+	lastWatchRequestTime      time.Time
+	lastDeltaWatchRequestTime time.Time
+}
+
+func (s *clientStatus) GetNode() *envoy_config_core_v3.Node     { return s.node }
+func (s *clientStatus) GetNumWatches() int                      { return s.numWatches }
+func (s *clientStatus) GetNumDeltaWatches() int                 { return s.numDeltaWatches }
+func (s *clientStatus) GetLastWatchRequestTime() time.Time      { return s.lastWatchRequestTime }
+func (s *clientStatus) GetLastDeltaWatchRequestTime() time.Time { return s.lastDeltaWatchRequestTime }
@@ -0,0 +1,186 @@
+package xds
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoytypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	envoycache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+)
+
+const testNode = "gw"
+
+// endpointsSnapshot returns a snapshot holding a load assignment per cluster, with an endpoint of the port
+func endpointsSnapshot(t *testing.T, ports map[string]uint32) envoycache.ResourceSnapshot {
+	t.Helper()
+	var assignments []envoytypes.Resource
+	for cluster, port := range ports {
+		assignments = append(assignments, &envoy_config_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: cluster,
+			Endpoints: []*envoy_config_endpoint_v3.LocalityLbEndpoints{{
+				LbEndpoints: []*envoy_config_endpoint_v3.LbEndpoint{{
+					HostIdentifier: &envoy_config_endpoint_v3.LbEndpoint_Endpoint{Endpoint: &envoy_config_endpoint_v3.Endpoint{
+						Address: &envoy_config_core_v3.Address{Address: &envoy_config_core_v3.Address_SocketAddress{
+							SocketAddress: &envoy_config_core_v3.SocketAddress{
+								Address:       "10.0.0.1",
+								PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{PortValue: port},
+							},
+						}},
+					}},
+				}},
+			}},
+		})
+	}
+	snapshot, err := envoycache.NewSnapshot("1", map[resource.Type][]envoytypes.Resource{resource.EndpointType: assignments})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func sotwRequest(version string, names ...string) *envoycache.Request {
+	return &envoycache.Request{
+		Node:          &envoy_config_core_v3.Node{Id: testNode},
+		TypeUrl:       resource.EndpointType,
+		ResourceNames: names,
+		VersionInfo:   version,
+	}
+}
+
+func receive[T any](t *testing.T, responses chan T) T {
+	t.Helper()
+	select {
+	case resp := <-responses:
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the xds response")
+		var zero T
+		return zero
+	}
+}
+
+func expectNone[T any](t *testing.T, responses chan T) {
+	t.Helper()
+	select {
+	case resp := <-responses:
+		t.Fatalf("unexpected response %v", resp)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func resourceNames(t *testing.T, resp envoycache.Response) (string, []string) {
+	t.Helper()
+	out, err := resp.GetDiscoveryResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range out.GetResources() {
+		var cla envoy_config_endpoint_v3.ClusterLoadAssignment
+		if err := r.UnmarshalTo(&cla); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, cla.GetClusterName())
+	}
+	slices.Sort(names)
+	return out.GetVersionInfo(), names
+}
+
+func TestClientCacheStateOfTheWorld(t *testing.T) {
+	cache := NewClientCache(envoycache.IDHash{}, nil)
+	responses := make(chan envoycache.Response, 1)
+
+	// a watch opened before the first snapshot is held until it is set
+	cancel := cache.CreateWatch(sotwRequest("", "a", "b"), stream.NewStreamState(false, nil), responses)
+	defer cancel()
+	expectNone(t, responses)
+	if err := cache.SetSnapshot(context.Background(), testNode, endpointsSnapshot(t, map[string]uint32{"a": 80, "b": 80})); err != nil {
+		t.Fatal(err)
+	}
+	version, names := resourceNames(t, receive(t, responses))
+	if !slices.Equal(names, []string{"a", "b"}) {
+		t.Fatalf("initial response has %v, want every resource", names)
+	}
+	// the resources of a response are known by its version until they change
+	if got := cache.ResourceNames(testNode, resource.EndpointType, version); !slices.Equal(got, names) {
+		t.Errorf("ResourceNames() = %v, want the resources of the response %v", got, names)
+	}
+	initial := version
+
+	// a snapshot with the same resources sends nothing, and one changing a resource only sends it
+	cancel = cache.CreateWatch(sotwRequest(version, "a", "b"), stream.NewStreamState(false, nil), responses)
+	defer cancel()
+	if err := cache.SetSnapshot(context.Background(), testNode, endpointsSnapshot(t, map[string]uint32{"a": 80, "b": 80})); err != nil {
+		t.Fatal(err)
+	}
+	expectNone(t, responses)
+	if err := cache.SetSnapshot(context.Background(), testNode, endpointsSnapshot(t, map[string]uint32{"a": 80, "b": 81})); err != nil {
+		t.Fatal(err)
+	}
+	version, names = resourceNames(t, receive(t, responses))
+	if !slices.Equal(names, []string{"b"}) {
+		t.Errorf("response has %v, want only the changed resource", names)
+	}
+	if got := cache.ResourceNames(testNode, resource.EndpointType, initial); got != nil {
+		t.Errorf("ResourceNames() of a previous version = %v, want none", got)
+	}
+
+	// a client connecting again after its snapshot was cleared gets every resource, whatever version it had
+	cache.ClearSnapshot(testNode)
+	if err := cache.SetSnapshot(context.Background(), testNode, endpointsSnapshot(t, map[string]uint32{"a": 80, "b": 81})); err != nil {
+		t.Fatal(err)
+	}
+	cancel = cache.CreateWatch(sotwRequest(version, "a", "b"), stream.NewStreamState(false, nil), responses)
+	defer cancel()
+	if _, names := resourceNames(t, receive(t, responses)); !slices.Equal(names, []string{"a", "b"}) {
+		t.Errorf("response after reconnecting has %v, want every resource", names)
+	}
+}
+
+func TestClientCacheDelta(t *testing.T) {
+	cache := NewClientCache(envoycache.IDHash{}, nil)
+	if err := cache.SetSnapshot(context.Background(), testNode, endpointsSnapshot(t, map[string]uint32{"a": 80, "b": 80})); err != nil {
+		t.Fatal(err)
+	}
+	state := stream.NewStreamState(true, nil)
+	push := func(ports map[string]uint32) envoycache.DeltaResponse {
+		t.Helper()
+		responses := make(chan envoycache.DeltaResponse, 1)
+		request := &envoycache.DeltaRequest{Node: &envoy_config_core_v3.Node{Id: testNode}, TypeUrl: resource.EndpointType}
+		cancel := cache.CreateDeltaWatch(request, state, responses)
+		defer cancel()
+		if ports != nil {
+			if err := cache.SetSnapshot(context.Background(), testNode, endpointsSnapshot(t, ports)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp := receive(t, responses)
+		state.SetResourceVersions(resp.GetNextVersionMap())
+		return resp
+	}
+
+	resp, err := push(nil).GetDeltaDiscoveryResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetResources()) != 2 {
+		t.Fatalf("initial response has %d resources, want 2", len(resp.GetResources()))
+	}
+
+	resp, err = push(map[string]uint32{"b": 81}).GetDeltaDiscoveryResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetResources()) != 1 || resp.GetResources()[0].GetName() != "b" {
+		t.Errorf("response = %v, want only the changed resource", resp.GetResources())
+	}
+	if !slices.Equal(resp.GetRemovedResources(), []string{"a"}) {
+		t.Errorf("removed resources = %v, want the resource no longer in the snapshot", resp.GetRemovedResources())
+	}
+}